@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const attachmentFormField = "attachment" // name of the multipart form field carrying the uploaded file
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.reader.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
+
+/*
+endpoint: /api/v1/attachments/upload
+
+the file is sent as multipart form file with the field name attachment and is streamed into the
+attachments store under attachments/{user id}/, the returned key is then sent as the key of the
+content of an attachment message
+*/
+func (apiConfig *ApiConfig) HandleUploadAttachment(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type response struct {
+		Key         string `json:"key"`
+		MimeType    string `json:"mime_type"`
+		Size        int64  `json:"size"`
+		AccessToken string `json:"access_token"`
+	}
+
+	// limiting the size of the request body, the file is streamed so it is never held in memory
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1024*1024)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		log.Printf("[/api/v1/attachments/upload]: error reading multipart form: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, "attachment must be sent as multipart form")
+		return
+	}
+
+	// skipping the other fields of the form until the file
+	part, err := reader.NextPart()
+	for err == nil && part.FormName() != attachmentFormField {
+		part.Close()
+		part, err = reader.NextPart()
+	}
+	if err != nil {
+		log.Printf("[/api/v1/attachments/upload]: error reading attachment from form: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, "attachment is required")
+		return
+	}
+	defer part.Close()
+
+	// detecting content type of the attachment
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(part, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		log.Printf("[/api/v1/attachments/upload]: error reading attachment: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if n == 0 {
+		utility.RespondWithError(w, http.StatusBadRequest, "attachment is empty")
+		return
+	}
+
+	// reading one byte more than allowed so that a larger file is noticed
+	attachment := &countingReader{
+		reader: io.LimitReader(io.MultiReader(bytes.NewReader(sniff[:n]), part), maxAttachmentSize+1),
+	}
+	attachmentKey := attachmentKeyPrefix + userID.String() + "/" + uuid.NewString()
+	if err = apiConfig.Attachments.Put(r.Context(), attachmentKey, attachment); err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			utility.RespondWithError(w, http.StatusRequestEntityTooLarge, "attachment can be at most 100MB")
+			return
+		}
+
+		log.Printf("[/api/v1/attachments/upload]: error saving attachment: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if attachment.count > maxAttachmentSize {
+		apiConfig.Attachments.Delete(r.Context(), attachmentKey)
+		utility.RespondWithError(w, http.StatusRequestEntityTooLarge, "attachment can be at most 100MB")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Key:         attachmentKey,
+		MimeType:    http.DetectContentType(sniff[:n]),
+		Size:        attachment.count,
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/attachments
+
+returns the file of the attachment message with message_id, the requesting user has to be able
+to see the message so the file is gone for everyone once the message is deleted or expires
+*/
+func (apiConfig *ApiConfig) HandleGetAttachment(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		MessageID uuid.UUID `json:"message_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/attachments]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	message, err := apiConfig.getVisibleMessage(r.Context(), userID, params.MessageID)
+	if errors.Is(err, errMessageNotVisible) || (err == nil && message.Kind != messageKindAttachment) {
+		utility.RespondWithError(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[/api/v1/attachments]: error fetching message %s: %v", params.MessageID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	attachment := attachmentContent{}
+	if err = json.Unmarshal(message.Content, &attachment); err != nil {
+		log.Printf("[/api/v1/attachments]: error decoding content of message %s: %v", params.MessageID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.serveAttachment(r.Context(), w, attachment, newAccessToken, "/api/v1/attachments")
+}
+
+/*
+serveAttachment streams the file of the attachment to the client. It is always sent as a download
+with the mime type given by the sender and without sniffing so that it is never rendered as a page
+*/
+func (apiConfig *ApiConfig) serveAttachment(ctx context.Context, w http.ResponseWriter, attachment attachmentContent, newAccessToken, endpoint string) {
+	file, err := apiConfig.Attachments.Get(ctx, attachment.Key)
+	if errors.Is(err, attachments.ErrNotFound) {
+		utility.RespondWithError(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[%s]: error reading attachment: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", attachment.MimeType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Access-Token", newAccessToken)
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, file); err != nil {
+		log.Printf("[%s]: error writing attachment to response: %v", endpoint, err)
+	}
+}
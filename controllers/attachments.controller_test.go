@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
+)
+
+func newTestAttachmentsConfig(t *testing.T) *ApiConfig {
+	t.Helper()
+
+	store, err := attachments.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("creating attachments store: %v", err)
+	}
+
+	return &ApiConfig{
+		Attachments: store,
+	}
+}
+
+// newUploadRequest builds a multipart request with content as the file of the given form field
+func newUploadRequest(t *testing.T, field string, content []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(field, "notes.txt")
+	if err != nil {
+		t.Fatalf("creating form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/attachments/upload", body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	return request
+}
+
+type uploadResponse struct {
+	Key      string `json:"key"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+func upload(t *testing.T, apiConfig *ApiConfig, userID uuid.UUID, content []byte) uploadResponse {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	apiConfig.HandleUploadAttachment(recorder, newUploadRequest(t, attachmentFormField, content), userID, "token")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("upload returned %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	response := uploadResponse{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decoding upload response: %v", err)
+	}
+	return response
+}
+
+func TestHandleUploadAttachment(t *testing.T) {
+	apiConfig := newTestAttachmentsConfig(t)
+	userID := uuid.New()
+	content := []byte("hello attachment")
+
+	response := upload(t, apiConfig, userID, content)
+	if !strings.HasPrefix(response.Key, attachmentKeyPrefix+userID.String()+"/") {
+		t.Errorf("key %q is not under the prefix of the uploader", response.Key)
+	}
+	if response.Size != int64(len(content)) {
+		t.Errorf("size = %d, want %d", response.Size, len(content))
+	}
+	if !strings.HasPrefix(response.MimeType, "text/plain") {
+		t.Errorf("mime type = %q, want text/plain", response.MimeType)
+	}
+
+	file, err := apiConfig.Attachments.Get(context.Background(), response.Key)
+	if err != nil {
+		t.Fatalf("reading uploaded attachment: %v", err)
+	}
+	defer file.Close()
+	stored, _ := io.ReadAll(file)
+	if !bytes.Equal(stored, content) {
+		t.Errorf("stored content = %q, want %q", stored, content)
+	}
+}
+
+func TestHandleUploadAttachmentRejectsInvalidUploads(t *testing.T) {
+	apiConfig := newTestAttachmentsConfig(t)
+
+	tests := []struct {
+		name    string
+		request *http.Request
+	}{
+		{name: "missing field", request: newUploadRequest(t, "file", []byte("hello"))},
+		{name: "empty file", request: newUploadRequest(t, attachmentFormField, nil)},
+		{name: "not multipart", request: httptest.NewRequest(http.MethodPost, "/api/v1/attachments/upload", strings.NewReader("hello"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			apiConfig.HandleUploadAttachment(recorder, test.request, uuid.New(), "token")
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestHandleUploadAttachmentRejectsLargeFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("streams more than 100MB")
+	}
+
+	apiConfig := newTestAttachmentsConfig(t)
+
+	// streaming the form so that the file is never held in memory by the test
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile(attachmentFormField, "large.bin")
+		if err == nil {
+			_, err = io.Copy(part, io.LimitReader(zeroReader{}, maxAttachmentSize+1))
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/attachments/upload", reader)
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	recorder := httptest.NewRecorder()
+	apiConfig.HandleUploadAttachment(recorder, request, uuid.New(), "token")
+	reader.Close()
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestUploadedAttachmentCanOnlyBeSentByUploader(t *testing.T) {
+	apiConfig := newTestAttachmentsConfig(t)
+	userID := uuid.New()
+	response := upload(t, apiConfig, userID, []byte("hello attachment"))
+
+	content, _ := json.Marshal(attachmentContent{
+		Key:      response.Key,
+		FileName: "notes.txt",
+		MimeType: response.MimeType,
+		Size:     response.Size,
+	})
+
+	if _, err := apiConfig.validateMessageContent(context.Background(), userID, messageKindAttachment, "", content); err != nil {
+		t.Errorf("uploader could not send the attachment: %v", err)
+	}
+	if _, err := apiConfig.validateMessageContent(context.Background(), uuid.New(), messageKindAttachment, "", content); err == nil {
+		t.Error("another user could send the attachment of the uploader")
+	}
+}
+
+func TestServeAttachment(t *testing.T) {
+	apiConfig := newTestAttachmentsConfig(t)
+	userID := uuid.New()
+	content := []byte("<html><script>alert(1)</script></html>")
+	response := upload(t, apiConfig, userID, content)
+
+	recorder := httptest.NewRecorder()
+	apiConfig.serveAttachment(context.Background(), recorder, attachmentContent{
+		Key:      response.Key,
+		FileName: "page.html",
+		MimeType: "text/html",
+		Size:     response.Size,
+	}, "token", "/api/v1/attachments")
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if got := recorder.Header().Get("Content-Disposition"); got != `attachment; filename=page.html` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := recorder.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+	if got := recorder.Header().Get("X-Access-Token"); got != "token" {
+		t.Errorf("X-Access-Token = %q, want token", got)
+	}
+	if !bytes.Equal(recorder.Body.Bytes(), content) {
+		t.Errorf("body = %q, want %q", recorder.Body.Bytes(), content)
+	}
+}
+
+func TestServeAttachmentNotFound(t *testing.T) {
+	apiConfig := newTestAttachmentsConfig(t)
+
+	recorder := httptest.NewRecorder()
+	apiConfig.serveAttachment(context.Background(), recorder, attachmentContent{
+		Key:      attachmentKeyPrefix + uuid.NewString() + "/" + uuid.NewString(),
+		FileName: "notes.txt",
+		MimeType: "text/plain",
+		Size:     1,
+	}, "token", "/api/v1/attachments")
+
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
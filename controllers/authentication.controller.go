@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"log"
@@ -15,6 +16,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	passwordResetTokenExpiresAfter = 10 * time.Minute // duration for which the reset token issued after otp verification stays valid
+)
+
 // endpoint: /api/v1/auth/otp/send
 func (apiConfig *ApiConfig) HandleSendOTP(w http.ResponseWriter, r *http.Request) {
 	// extracting phonenumber from request body
@@ -144,9 +149,10 @@ func (apiConfig *ApiConfig) HandleRegisterUser(w http.ResponseWriter, r *http.Re
 
 	// registering user
 	newUser, err := apiConfig.DB.CreateUser(r.Context(), database.CreateUserParams{
-		Phonenumber: params.Phonenumber,
-		Username:    params.Username,
-		Password:    string(hashedPassword),
+		Phonenumber:     params.Phonenumber,
+		PhonenumberHash: apiConfig.hashPhonenumber(params.Phonenumber),
+		Username:        params.Username,
+		Password:        string(hashedPassword),
 	})
 	if err != nil {
 		log.Printf("[/api/v1/auth/register]: error while creating new user %v", err)
@@ -278,6 +284,217 @@ func (apiConfig *ApiConfig) HandleLoginUser(w http.ResponseWriter, r *http.Reque
 	utility.RespondWithJson(w, http.StatusOK, newMessages)
 }
 
+// endpoint: /api/v1/auth/password/forgot/otp/send
+func (apiConfig *ApiConfig) HandleSendPasswordResetOTP(w http.ResponseWriter, r *http.Request) {
+	// extracting phonenumber from request body
+	decoder := json.NewDecoder(r.Body)
+	params := phonenumber{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/send]: error decoding request body %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating phonenumber
+	if err = apiConfig.DataValidator.Var(params.Phonenumber, "required,phonenumber"); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/send]: error validating phonenumber %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// password can only be reset for a registered phonenumber
+	if _, err = apiConfig.DB.DoesUserExist(r.Context(), params.Phonenumber); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/send]: no user registered with phonenumber %s", params.Phonenumber)
+		utility.RespondWithError(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+
+	// checking if requesting otp is allowed or not
+	if ok, err := apiConfig.TwilioConfig.IsResendAllowed(params.Phonenumber); !ok {
+		log.Printf("[/api/v1/auth/password/forgot/otp/send]: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// sending otp to registered phonenumber
+	if err = apiConfig.TwilioConfig.SendOTP(params.Phonenumber); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/send]: error sending otp %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, nil)
+}
+
+/*
+endpoint: /api/v1/auth/password/forgot/otp/verify
+This endpoint verifies the otp sent to the registered phonenumber and issues a
+single-use reset token which is required for setting the new password
+*/
+func (apiConfig *ApiConfig) HandleVerifyPasswordResetOTP(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		Phonenumber string `json:"phonenumber"`
+		OTP         string `json:"otp"`
+	}
+
+	type response struct {
+		ResetToken string `json:"reset_token"`
+		ExpiresAt  string `json:"expires_at"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error decoding request body %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating phonenumber
+	if err = apiConfig.DataValidator.Var(params.Phonenumber, "required,phonenumber"); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error validating phonenumber %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	// validating otp
+	if err = apiConfig.TwilioConfig.VerifyOTP(params.Phonenumber, params.OTP); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error while otp verification %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	// fetching the user to whom the phonenumber belongs
+	user, err := apiConfig.DB.GetUserByPhonenumber(r.Context(), params.Phonenumber)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error fetching user with phonenumber %s, %v", params.Phonenumber, err)
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// only the latest reset token is kept valid for the user
+	if err = apiConfig.DB.RemovePasswordResetTokens(r.Context(), user.ID); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error removing old reset tokens for user %s, %v", user.ID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// generating reset token, only its hash is saved in database
+	resetToken, err := generateRefreshToken()
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error generating reset token for user %s, %v", user.ID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(passwordResetTokenExpiresAfter)
+	if err = apiConfig.DB.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		Token:     hashToken(resetToken),
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		log.Printf("[/api/v1/auth/password/forgot/otp/verify]: error saving reset token for user %s, %v", user.ID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		ResetToken: resetToken,
+		ExpiresAt:  expiresAt.Format(time.RFC1123),
+	})
+}
+
+/*
+endpoint: /api/v1/auth/password/reset
+This endpoint sets the new password using the reset token. On success all the
+refresh tokens of the user are revoked and the live socket connection is closed
+*/
+func (apiConfig *ApiConfig) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	type request struct {
+		ResetToken string `json:"reset_token"`
+		Password   string `json:"password"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error decoding request body %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if len(params.ResetToken) == 0 {
+		log.Printf("[/api/v1/auth/password/reset]: empty reset token")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty reset token")
+		return
+	}
+
+	if err = apiConfig.DataValidator.Var(params.Password, "required,min=8,max=20,password"); err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error validating password %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	// hashing new password
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error hashing password %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// consuming the reset token and updating the password in a single transaction
+	// so that the token is not lost if updating the password fails
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error starting transaction %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	userID, err := qtx.ConsumePasswordResetToken(r.Context(), hashToken(params.ResetToken))
+	if err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: invalid or expired reset token %v", err)
+		utility.RespondWithError(w, http.StatusUnauthorized, "invalid or expired reset token")
+		return
+	}
+
+	if err = qtx.UpdatePassword(r.Context(), database.UpdatePasswordParams{
+		Password: string(hashedPassword),
+		ID:       userID,
+	}); err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error updating password for user %s, %v", userID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// revoking refresh tokens so that every existing session has to login again
+	if err = qtx.RemoveRefreshToken(r.Context(), userID); err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error deleting refresh token for user %s, %v", userID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/auth/password/reset]: error committing transaction %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// disconnecting live socket of the user
+	apiConfig.NotificationService.DisconnectUser(userID)
+
+	utility.RespondWithJson(w, http.StatusOK, nil)
+}
+
 func MakeJWT(userID string, jwtSecret string, expiresAfter time.Duration) (string, error) {
 	// creating the signing key to be used for signing token
 	signingKey := []byte(jwtSecret)
@@ -311,3 +528,9 @@ func generateRefreshToken() (string, error) {
 
 	return hex.EncodeToString(refreshToken), nil
 }
+
+// only the sha256 hash of single-use tokens is saved in database
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"slices"
+
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const maxAvatarSize = 5 << 20 // maximum size of the avatar in bytes
+
+// content types allowed for the avatar
+var avatarContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+/*
+readAvatarUpload reads the avatar sent as multipart form file with the field name avatar
+and checks its size and content type. The returned reader yields the whole image and the
+returned file has to be closed by the caller. On failure the error response is already sent
+*/
+func readAvatarUpload(w http.ResponseWriter, r *http.Request, endpoint string) (io.Reader, multipart.File, bool) {
+	// limiting the size of the request body
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarSize+1024*1024)
+	if err := r.ParseMultipartForm(maxAvatarSize); err != nil {
+		log.Printf("[%s]: error parsing multipart form: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusRequestEntityTooLarge, "avatar can be at most 5MB")
+		return nil, nil, false
+	}
+
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		log.Printf("[%s]: error reading avatar from form: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusBadRequest, "avatar is required")
+		return nil, nil, false
+	}
+
+	if header.Size > maxAvatarSize {
+		file.Close()
+		utility.RespondWithError(w, http.StatusRequestEntityTooLarge, "avatar can be at most 5MB")
+		return nil, nil, false
+	}
+
+	// checking if the uploaded file is an image
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		file.Close()
+		log.Printf("[%s]: error reading avatar: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, nil, false
+	}
+	if !slices.Contains(avatarContentTypes, http.DetectContentType(sniff[:n])) {
+		file.Close()
+		utility.RespondWithError(w, http.StatusUnsupportedMediaType, "avatar must be a jpeg, png or webp image")
+		return nil, nil, false
+	}
+
+	return io.MultiReader(bytes.NewReader(sniff[:n]), file), file, true
+}
+
+// serveAvatar streams the avatar saved in the attachments store under key to the client
+func (apiConfig *ApiConfig) serveAvatar(ctx context.Context, w http.ResponseWriter, key, newAccessToken, endpoint string) {
+	avatar, err := apiConfig.Attachments.Get(ctx, key)
+	if err != nil {
+		log.Printf("[%s]: error reading avatar: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+	defer avatar.Close()
+
+	// detecting content type of the avatar
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(avatar, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		log.Printf("[%s]: error reading avatar: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(sniff[:n]))
+	w.Header().Set("X-Access-Token", newAccessToken)
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, io.MultiReader(bytes.NewReader(sniff[:n]), avatar)); err != nil {
+		log.Printf("[%s]: error writing avatar to response: %v", endpoint, err)
+	}
+}
@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+/*
+Channels are groups of type channel in which only the owner and the admins post and the
+subscribers read. Subscribers are members with the role member, they can not see each other
+and join by the public handle of the channel or with an invite code. Messages of a channel are
+group messages so they reach the subscribers through the notification service like any group message
+*/
+
+/*
+endpoint: /api/v1/channel/create
+This endpoint creates a channel owned by the requesting user
+*/
+func (apiConfig *ApiConfig) HandleCreateChannel(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Name        string `json:"name"`
+		Handle      string `json:"handle"`
+		Description string `json:"description"`
+	}
+
+	type response struct {
+		ID          uuid.UUID `json:"id"`
+		Name        string    `json:"name"`
+		Handle      string    `json:"handle"`
+		Description string    `json:"description"`
+		CreatedAt   string    `json:"created_at"`
+		AccessToken string    `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/channel/create]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	params.Name = strings.TrimSpace(params.Name)
+	params.Description = strings.TrimSpace(params.Description)
+	if len(params.Name) == 0 || len(params.Name) > maxGroupNameLength {
+		log.Printf("[/api/v1/channel/create]: invalid name field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "name must have between 1 and 100 characters")
+		return
+	}
+
+	if len(params.Description) > maxGroupDescriptionLength {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "description can have at most 500 characters")
+		return
+	}
+
+	params.Handle = strings.ToLower(params.Handle)
+	if err = apiConfig.DataValidator.Var(params.Handle, "required,channelhandle"); err != nil {
+		log.Printf("[/api/v1/channel/create]: invalid handle: %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "handle must have 5 to 32 lowercase letters, digits or underscores and start with a letter")
+		return
+	}
+
+	// creating channel and making the requesting user its owner
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/channel/create]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	channel, err := qtx.CreateChannel(r.Context(), database.CreateChannelParams{
+		Name: params.Name,
+		Description: sql.NullString{
+			String: params.Description,
+			Valid:  params.Description != "",
+		},
+		Handle: sql.NullString{
+			String: params.Handle,
+			Valid:  true,
+		},
+	})
+	if err != nil {
+		// unique constraint on groups.handle fails if the handle is taken
+		log.Printf("[/api/v1/channel/create]: error creating channel: %v", err)
+		utility.RespondWithError(w, http.StatusConflict, "handle is already taken")
+		return
+	}
+
+	if err = qtx.AddUserToGroup(r.Context(), database.AddUserToGroupParams{
+		UserID:  userID,
+		GroupID: channel.ID,
+		Role:    roleOwner,
+	}); err != nil {
+		log.Printf("[/api/v1/channel/create]: error making the requesting user owner: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/channel/create]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		ID:          channel.ID,
+		Name:        channel.Name,
+		Handle:      channel.Handle.String,
+		Description: channel.Description.String,
+		CreatedAt:   channel.CreatedAt.Format(time.RFC1123),
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/channel/info
+
+returns the public information of the channel with the handle or channel_id,
+anyone can look up a channel before subscribing to it
+*/
+func (apiConfig *ApiConfig) HandleGetChannelInfo(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Handle    string    `json:"handle"`
+		ChannelID uuid.UUID `json:"channel_id"`
+	}
+
+	type response struct {
+		ID               uuid.UUID `json:"id"`
+		Name             string    `json:"name"`
+		Handle           string    `json:"handle"`
+		Description      string    `json:"description"`
+		HasAvatar        bool      `json:"has_avatar"`
+		SubscribersCount int64     `json:"subscribers_count"`
+		Subscribed       bool      `json:"subscribed"`
+		Role             string    `json:"role,omitempty"`
+		CreatedAt        string    `json:"created_at"`
+		AccessToken      string    `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/channel/info]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	channel, err := apiConfig.findChannel(r, params.Handle, params.ChannelID)
+	if err != nil {
+		log.Printf("[/api/v1/channel/info]: error finding channel: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	subscribersCount, err := apiConfig.DB.GroupMembersCount(r.Context(), channel.ID)
+	if err != nil {
+		log.Printf("[/api/v1/channel/info]: error counting subscribers: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	role, err := apiConfig.DB.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  userID,
+		GroupID: channel.ID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[/api/v1/channel/info]: error checking subscription: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		ID:               channel.ID,
+		Name:             channel.Name,
+		Handle:           channel.Handle.String,
+		Description:      channel.Description.String,
+		HasAvatar:        channel.AvatarKey.Valid,
+		SubscribersCount: subscribersCount,
+		Subscribed:       err == nil,
+		Role:             role,
+		CreatedAt:        channel.CreatedAt.Format(time.RFC1123),
+		AccessToken:      newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/channel/subscribe
+func (apiConfig *ApiConfig) HandleSubscribeChannel(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Handle string `json:"handle"`
+	}
+
+	type response struct {
+		ChannelID   uuid.UUID `json:"channel_id"`
+		AccessToken string    `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if len(params.Handle) == 0 {
+		log.Printf("[/api/v1/channel/subscribe]: empty handle field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty handle field")
+		return
+	}
+
+	channel, err := apiConfig.findChannel(r, params.Handle, uuid.Nil)
+	if err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error finding channel: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	// checking if the requesting user is already subscribed
+	if _, err = apiConfig.DB.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  userID,
+		GroupID: channel.ID,
+	}); err == nil {
+		utility.RespondWithError(w, http.StatusConflict, "you are already subscribed to this channel")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[/api/v1/channel/subscribe]: error checking subscription: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	if err = addGroupMember(r.Context(), apiConfig.DB.WithTx(tx), channel.ID, userID); err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error subscribing to channel: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// letting the admins of the channel know about the new subscriber
+	if err = apiConfig.emitGroupMemberEvent(r.Context(), eventhandlers.ADD_USER_TO_GROUP, channel.ID, userID, roleMember); err != nil {
+		log.Printf("[/api/v1/channel/subscribe]: error emitting %s event: %v", eventhandlers.ADD_USER_TO_GROUP, err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		ChannelID:   channel.ID,
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/channel/unsubscribe
+If the owner unsubscribes the channel gets a new owner like a group does when its owner leaves
+*/
+func (apiConfig *ApiConfig) HandleUnsubscribeChannel(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ChannelID uuid.UUID `json:"channel_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/channel/unsubscribe]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	channel, err := apiConfig.findChannel(r, "", params.ChannelID)
+	if err != nil {
+		log.Printf("[/api/v1/channel/unsubscribe]: error finding channel: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "channel not found")
+		return
+	}
+
+	apiConfig.leaveGroup(w, r, userID, newAccessToken, channel.ID, "/api/v1/channel/unsubscribe")
+}
+
+// findChannel finds the channel by its handle or else by its id, groups which are not channels are not found
+func (apiConfig *ApiConfig) findChannel(r *http.Request, handle string, channelID uuid.UUID) (database.Group, error) {
+	var channel database.Group
+	var err error
+	if handle != "" {
+		channel, err = apiConfig.DB.GetGroupByHandle(r.Context(), sql.NullString{
+			String: strings.ToLower(handle),
+			Valid:  true,
+		})
+	} else {
+		channel, err = apiConfig.DB.GetGroup(r.Context(), channelID)
+	}
+	if err != nil {
+		return database.Group{}, err
+	}
+
+	if channel.Type != groupTypeChannel {
+		return database.Group{}, sql.ErrNoRows
+	}
+
+	return channel, nil
+}
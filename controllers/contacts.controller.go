@@ -0,0 +1,373 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const maxDiscoverContactsHashes = 1000 // maximum number of hashed phonenumbers accepted in one discovery request
+
+/*
+the phonenumbers are matched by an hmac of their sha256 keyed with the pepper of the server,
+so the hashes uploaded by the clients can be looked up through the index on users.phonenumber_hash
+and a leaked table can not be reversed by hashing every possible phonenumber
+*/
+func (apiConfig *ApiConfig) pepperPhonenumberDigest(digest []byte) string {
+	mac := hmac.New(sha256.New, apiConfig.ContactDiscoveryPepper)
+	mac.Write(digest)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (apiConfig *ApiConfig) hashPhonenumber(phonenumber string) sql.NullString {
+	digest := sha256.Sum256([]byte(phonenumber))
+	return sql.NullString{
+		String: apiConfig.pepperPhonenumberDigest(digest[:]),
+		Valid:  true,
+	}
+}
+
+// BackfillPhonenumberHashes hashes the phonenumbers of the users registered before the hashes were stored
+func (apiConfig *ApiConfig) BackfillPhonenumberHashes(ctx context.Context) error {
+	users, err := apiConfig.DB.GetUsersWithoutPhonenumberHash(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err = apiConfig.DB.SetPhonenumberHash(ctx, database.SetPhonenumberHashParams{
+			PhonenumberHash: apiConfig.hashPhonenumber(user.Phonenumber),
+			ID:              user.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type contact struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Phonenumber string    `json:"phonenumber"`
+}
+
+// endpoint: /api/v1/contacts/add
+func (apiConfig *ApiConfig) HandleAddContact(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type response struct {
+		contact
+		AccessToken string `json:"access_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := phonenumber{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/contacts/add]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.DataValidator.Var(params.Phonenumber, "required,phonenumber"); err != nil {
+		log.Printf("[/api/v1/contacts/add]: invalid phonenumber: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// fetching the user to be added as contact
+	user, err := apiConfig.DB.GetUserByPhonenumber(r.Context(), params.Phonenumber)
+	if err != nil {
+		log.Printf("[/api/v1/contacts/add]: user with phonenumber %s not found: %v", params.Phonenumber, err)
+		utility.RespondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	if user.ID == userID {
+		utility.RespondWithError(w, http.StatusBadRequest, "you can not add yourself as contact")
+		return
+	}
+
+	if err = apiConfig.DB.AddContact(r.Context(), database.AddContactParams{
+		UserID:    userID,
+		ContactID: user.ID,
+	}); err != nil {
+		log.Printf("[/api/v1/contacts/add]: error adding contact: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		contact: contact{
+			ID:          user.ID,
+			Username:    user.Username,
+			Phonenumber: params.Phonenumber,
+		},
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/contacts/remove
+func (apiConfig *ApiConfig) HandleRemoveContact(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ContactID uuid.UUID `json:"contact_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/contacts/remove]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	removed, err := apiConfig.DB.RemoveContact(r.Context(), database.RemoveContactParams{
+		UserID:    userID,
+		ContactID: params.ContactID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/contacts/remove]: error removing contact: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if removed == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "contact not found")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/contacts
+func (apiConfig *ApiConfig) HandleGetContacts(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type response struct {
+		Contacts    []contact `json:"contacts"`
+		AccessToken string    `json:"access_token"`
+	}
+
+	userContacts, err := apiConfig.DB.GetContacts(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/contacts]: error fetching contacts: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	contacts := make([]contact, 0, len(userContacts))
+	for _, userContact := range userContacts {
+		contacts = append(contacts, contact{
+			ID:          userContact.ID,
+			Username:    userContact.Username,
+			DisplayName: userContact.DisplayName.String,
+			Phonenumber: userContact.Phonenumber,
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Contacts:    contacts,
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/contacts/discover
+
+the client uploads the sha256 hashes(hex encoded) of the phonenumbers in its address book
+and the users registered with any of those phonenumbers are returned. The phonenumbers are few
+enough to hash every one of them, so the uploaded hashes keep the address book out of the logs
+but do not hide it, the enumeration of the registered phonenumbers is only bounded by the rate
+limit shared with /api/v1/users/info. The pepper protects the hashes stored in users.phonenumber_hash
+which could otherwise be reversed the same way by anyone who reads the table
+*/
+func (apiConfig *ApiConfig) HandleDiscoverContacts(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Hashes []string `json:"hashes"`
+	}
+
+	type response struct {
+		Users       []contact `json:"users"`
+		AccessToken string    `json:"access_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/contacts/discover]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating hashes
+	if len(params.Hashes) == 0 || len(params.Hashes) > maxDiscoverContactsHashes {
+		utility.RespondWithError(w, http.StatusBadRequest, "between 1 and 1000 hashes are allowed")
+		return
+	}
+
+	for index, hash := range params.Hashes {
+		decoded, err := hex.DecodeString(strings.TrimSpace(hash))
+		if err != nil || len(decoded) != sha256.Size {
+			utility.RespondWithError(w, http.StatusBadRequest, "hashes must be hex encoded sha256 of phonenumbers")
+			return
+		}
+		params.Hashes[index] = apiConfig.pepperPhonenumberDigest(decoded)
+	}
+
+	users, err := apiConfig.DB.DiscoverContacts(r.Context(), database.DiscoverContactsParams{
+		Hashes: params.Hashes,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/contacts/discover]: error discovering contacts: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	discovered := make([]contact, 0, len(users))
+	for _, user := range users {
+		discovered = append(discovered, contact{
+			ID:          user.ID,
+			Username:    user.Username,
+			DisplayName: user.DisplayName.String,
+			Phonenumber: user.Phonenumber,
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Users:       discovered,
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/block
+func (apiConfig *ApiConfig) HandleBlockUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/block]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if params.UserID == uuid.Nil || params.UserID == userID {
+		utility.RespondWithError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	if _, err = apiConfig.DB.GetUserById(r.Context(), params.UserID); err != nil {
+		log.Printf("[/api/v1/users/block]: user with id %s does not exist", params.UserID.String())
+		utility.RespondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	if err = apiConfig.DB.BlockUser(r.Context(), database.BlockUserParams{
+		UserID:        userID,
+		BlockedUserID: params.UserID,
+	}); err != nil {
+		log.Printf("[/api/v1/users/block]: error blocking user: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// pending message request from the blocked user will not be shown anymore
+	if _, err = apiConfig.DB.UpdateMessageRequestStatus(r.Context(), database.UpdateMessageRequestStatusParams{
+		Status:     messageRequestDeclined,
+		SenderID:   params.UserID,
+		ReceiverID: userID,
+	}); err != nil {
+		log.Printf("[/api/v1/users/block]: error declining message request of blocked user: %v", err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/unblock
+func (apiConfig *ApiConfig) HandleUnblockUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/unblock]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	unblocked, err := apiConfig.DB.UnblockUser(r.Context(), database.UnblockUserParams{
+		UserID:        userID,
+		BlockedUserID: params.UserID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/users/unblock]: error unblocking user: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if unblocked == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "user is not blocked")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/blocked
+func (apiConfig *ApiConfig) HandleGetBlockedUsers(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type blockedUser struct {
+		ID        uuid.UUID `json:"id"`
+		Username  string    `json:"username"`
+		BlockedAt string    `json:"blocked_at"`
+	}
+
+	type response struct {
+		BlockedUsers []blockedUser `json:"blocked_users"`
+		AccessToken  string        `json:"access_token"`
+	}
+
+	users, err := apiConfig.DB.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/blocked]: error fetching blocked users: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	blockedUsers := make([]blockedUser, 0, len(users))
+	for _, user := range users {
+		blockedUsers = append(blockedUsers, blockedUser{
+			ID:        user.ID,
+			Username:  user.Username,
+			BlockedAt: user.CreatedAt.Format(time.RFC1123),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		BlockedUsers: blockedUsers,
+		AccessToken:  newAccessToken,
+	})
+}
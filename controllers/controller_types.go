@@ -1,22 +1,35 @@
 package controllers
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
 	"github.com/harshvardha/TerTerChat/internal/cache"
 	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/linkpreview"
 	"github.com/harshvardha/TerTerChat/internal/services"
 )
 
 type ApiConfig struct {
 	DB                              *database.Queries
+	DBConnection                    *sql.DB
 	JwtSecret                       string
 	TwilioConfig                    *services.TwilioConfig
 	NotificationService             *services.Notification
 	DataValidator                   *validator.Validate
 	MessageEventEmitterChannel      chan eventhandlers.MessageEvent
 	GroupActionsEventEmitterChannel chan eventhandlers.GroupEvent
+	UserEventEmitterChannel         chan eventhandlers.UserEvent
 	MessageCache                    *cache.DynamicShardedCache
+	Attachments                     attachments.Store
+	MessageEditWindow               time.Duration // duration after sending in which a message can be edited
+	DeleteForEveryoneWindow         time.Duration // duration after sending in which the sender can delete a message for everyone
+	LinkPreviews                    linkpreview.Fetcher
+	LinkPreviewQueue                chan database.Message // messages with a link waiting for the link preview worker
+	ContactDiscoveryPepper          []byte                // secret key of the phonenumber hashes matched by contact discovery
 }
 
 type EmptyResponse struct {
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const maxPinnedConversations = 5 // number of conversations a user can pin to the top of the inbox
+
+var (
+	errInvalidConversation = errors.New("conversation needs either a receiver id or a group id")
+	errUserNotFound        = errors.New("user not found")
+)
+
+// settings of a conversation for the requesting user sent to the client
+type conversationSettings struct {
+	MutedUntil string `json:"muted_until,omitempty"`
+	Archived   bool   `json:"archived"`
+	Pinned     bool   `json:"pinned"`
+}
+
+func newConversationSettings(settings database.GetConversationSettingsRow) conversationSettings {
+	conversationSettings := conversationSettings{
+		Archived: settings.Archived,
+		Pinned:   settings.PinnedAt.Valid,
+	}
+	if settings.MutedUntil.Valid && settings.MutedUntil.Time.After(time.Now()) {
+		conversationSettings.MutedUntil = settings.MutedUntil.Time.Format(time.RFC1123)
+	}
+
+	return conversationSettings
+}
+
+/*
+authorizeConversationSettings checks if the user can change its settings of the conversation. A user has
+settings for the one-to-one conversation with any other user and for the groups of which it is a member
+*/
+func (apiConfig *ApiConfig) authorizeConversationSettings(ctx context.Context, userID uuid.UUID, c conversation) error {
+	if (c.ReceiverID == uuid.Nil) == (c.GroupID == uuid.Nil) {
+		return errInvalidConversation
+	}
+
+	if c.GroupID != uuid.Nil {
+		_, err := apiConfig.authorizeGroupAction(ctx, c.GroupID, userID, permissionViewGroup)
+		return err
+	}
+
+	if c.ReceiverID == userID {
+		return errInvalidConversation
+	}
+
+	_, err := apiConfig.DB.GetUserById(ctx, c.ReceiverID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errUserNotFound
+	}
+
+	return err
+}
+
+// respondWithConversationSettingsError responds with the status code matching the error returned by authorizeConversationSettings
+func respondWithConversationSettingsError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errInvalidConversation):
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+	case errors.Is(err, errUserNotFound):
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+	default:
+		respondWithGroupAuthorizationError(w, err)
+	}
+}
+
+/*
+endpoint: /api/v1/message/conversation/mute
+
+mutes the conversation with receiver_id or the group with group_id until the given time, a zero or
+past until unmutes it. Messages from a muted conversation are pushed silently, mentions are not muted
+*/
+func (apiConfig *ApiConfig) HandleMuteConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+		Until      time.Time `json:"until"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/conversation/mute]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.authorizeConversationSettings(r.Context(), userID, conversation{
+		ReceiverID: params.ReceiverID,
+		GroupID:    params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/mute]: requesting user %s is not allowed: %v", userID, err)
+		respondWithConversationSettingsError(w, err)
+		return
+	}
+
+	if err = apiConfig.DB.SetConversationMute(r.Context(), database.SetConversationMuteParams{
+		UserID: userID,
+		OtherUserID: uuid.NullUUID{
+			UUID:  params.ReceiverID,
+			Valid: params.ReceiverID != uuid.Nil,
+		},
+		GroupID: uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: params.GroupID != uuid.Nil,
+		},
+		MutedUntil: sql.NullTime{
+			Time:  params.Until,
+			Valid: params.Until.After(time.Now()),
+		},
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/mute]: error muting conversation: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/message/conversation/archive
+
+archives or unarchives the conversation with receiver_id or the group with group_id.
+An archived conversation is unarchived when a new message arrives in it
+*/
+func (apiConfig *ApiConfig) HandleArchiveConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+		Archived   bool      `json:"archived"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/conversation/archive]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.authorizeConversationSettings(r.Context(), userID, conversation{
+		ReceiverID: params.ReceiverID,
+		GroupID:    params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/archive]: requesting user %s is not allowed: %v", userID, err)
+		respondWithConversationSettingsError(w, err)
+		return
+	}
+
+	if err = apiConfig.DB.SetConversationArchived(r.Context(), database.SetConversationArchivedParams{
+		UserID: userID,
+		OtherUserID: uuid.NullUUID{
+			UUID:  params.ReceiverID,
+			Valid: params.ReceiverID != uuid.Nil,
+		},
+		GroupID: uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: params.GroupID != uuid.Nil,
+		},
+		Archived: params.Archived,
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/archive]: error archiving conversation: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/message/conversation/pin
+func (apiConfig *ApiConfig) HandlePinConversation(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+		Pinned     bool      `json:"pinned"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/conversation/pin]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.authorizeConversationSettings(r.Context(), userID, conversation{
+		ReceiverID: params.ReceiverID,
+		GroupID:    params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/pin]: requesting user %s is not allowed: %v", userID, err)
+		respondWithConversationSettingsError(w, err)
+		return
+	}
+
+	if params.Pinned {
+		pinned, err := apiConfig.DB.CountPinnedConversations(r.Context(), userID)
+		if err != nil {
+			log.Printf("[/api/v1/message/conversation/pin]: error counting pinned conversations: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if pinned >= maxPinnedConversations {
+			utility.RespondWithError(w, http.StatusConflict, "you can pin at most 5 conversations")
+			return
+		}
+	}
+
+	if err = apiConfig.DB.SetConversationPinned(r.Context(), database.SetConversationPinnedParams{
+		UserID: userID,
+		OtherUserID: uuid.NullUUID{
+			UUID:  params.ReceiverID,
+			Valid: params.ReceiverID != uuid.Nil,
+		},
+		GroupID: uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: params.GroupID != uuid.Nil,
+		},
+		PinnedAt: sql.NullTime{
+			Time:  time.Now(),
+			Valid: params.Pinned,
+		},
+	}); err != nil {
+		log.Printf("[/api/v1/message/conversation/pin]: error pinning conversation: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
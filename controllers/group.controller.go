@@ -1,14 +1,18 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
 	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/services"
 	"github.com/harshvardha/TerTerChat/utility"
 )
 
@@ -42,23 +46,35 @@ func (apiConfig *ApiConfig) HandleCreateGroup(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// creating group and making the requesting user admin
-	newGroup, err := apiConfig.DB.CreateGroup(r.Context(), params.Name)
+	// creating group and making the requesting user its owner
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/group/create]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	newGroup, err := qtx.CreateGroup(r.Context(), params.Name)
 	if err != nil {
 		log.Printf("[/api/v1/group/create]: error creating new group: %v", err)
 		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err = apiConfig.DB.MakeUserAdmin(r.Context(), database.MakeUserAdminParams{
+	if err = qtx.AddUserToGroup(r.Context(), database.AddUserToGroupParams{
 		UserID:  userID,
 		GroupID: newGroup.ID,
+		Role:    roleOwner,
 	}); err != nil {
-		log.Printf("[/api/v1/group/create]: error making the requesting user admin: %v", err)
-		if removeGroupErr := apiConfig.DB.DeleteGroup(r.Context(), newGroup.ID); removeGroupErr != nil {
-			log.Printf("[/api/v1/group/create]: error removing group: %v", err)
-		}
+		log.Printf("[/api/v1/group/create]: error making the requesting user owner: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/group/create]: error committing transaction: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -74,12 +90,14 @@ func (apiConfig *ApiConfig) HandleCreateGroup(w http.ResponseWriter, r *http.Req
 // endpoint: /api/v1/group/update
 func (apiConfig *ApiConfig) HandleUpdateGroupName(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
-		GroupID uuid.UUID `json:"group_id"`
-		Name    string    `json:"name"`
+		GroupID     uuid.UUID `json:"group_id"`
+		Name        string    `json:"name"`
+		Description *string   `json:"description"`
 	}
 
 	type response struct {
 		Name        string `json:"name"`
+		Description string `json:"description"`
 		UpdatedAt   string `json:"updated_at"`
 		AccessToken string `json:"access_token"`
 	}
@@ -95,41 +113,79 @@ func (apiConfig *ApiConfig) HandleUpdateGroupName(w http.ResponseWriter, r *http
 	}
 
 	// validating request body
-	if len(params.Name) == 0 {
-		log.Printf("[/api/v1/group/update]: empty name field")
-		utility.RespondWithError(w, http.StatusNotAcceptable, "empty name field")
+	params.Name = strings.TrimSpace(params.Name)
+	if len(params.Name) == 0 && params.Description == nil {
+		log.Printf("[/api/v1/group/update]: empty name and description field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty name and description field")
 		return
 	}
 
+	if len(params.Name) > maxGroupNameLength {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "name can have at most 100 characters")
+		return
+	}
+
+	if params.Description != nil {
+		*params.Description = strings.TrimSpace(*params.Description)
+		if len(*params.Description) > maxGroupDescriptionLength {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "description can have at most 500 characters")
+			return
+		}
+	}
+
 	if params.GroupID == uuid.Nil {
 		log.Printf("[/api/v1/group/update]: empty group id")
 		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id")
 		return
 	}
 
-	// checking if the requesting user id admin of group or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
-		UserID:  userID,
-		GroupID: params.GroupID,
-	}); err != nil {
-		log.Printf("[/api/v1/group/update]: requesting user is not group admin: %v", err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/update]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
 		return
 	}
 
-	// updating group name
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeRestrictedGroupAction(r.Context(), params.GroupID, userID, permissionEditGroupInfo, group.OnlyAdminsCanEditInfo); err != nil {
+		log.Printf("[/api/v1/group/update]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	// fields which are not sent remain unchanged
+	changes := map[string]any{}
+	if len(params.Name) > 0 && params.Name != group.Name {
+		group.Name = params.Name
+		changes["name"] = params.Name
+	}
+	if params.Description != nil && *params.Description != group.Description.String {
+		group.Description = sql.NullString{
+			String: *params.Description,
+			Valid:  *params.Description != "",
+		}
+		changes["description"] = *params.Description
+	}
+
+	// updating group name and description
 	updatedGroup, err := apiConfig.DB.UpdateGroup(r.Context(), database.UpdateGroupParams{
-		Name: params.Name,
-		ID:   params.GroupID,
+		Name:        group.Name,
+		Description: group.Description,
+		ID:          params.GroupID,
 	})
 	if err != nil {
-		log.Printf("[/api/v1/group/update]: error updating group name: %v", err)
+		log.Printf("[/api/v1/group/update]: error updating group: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if err = apiConfig.emitGroupUpdated(r.Context(), params.GroupID, userID, changes); err != nil {
+		log.Printf("[/api/v1/group/update]: error emitting %s event: %v", eventhandlers.GROUP_UPDATED, err)
+	}
+
 	utility.RespondWithJson(w, http.StatusOK, response{
 		Name:        updatedGroup.Name,
+		Description: updatedGroup.Description.String,
 		UpdatedAt:   updatedGroup.UpdatedAt.Format(time.RFC1123),
 		AccessToken: newAccessToken,
 	})
@@ -161,13 +217,17 @@ func (apiConfig *ApiConfig) HandleRemoveGroup(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// checking if the requesting user id admin or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
-		UserID:  userID,
-		GroupID: params.GroupID,
-	}); err != nil {
-		log.Printf("[/api/v1/group/remove]: requesting user %s is not admin: %v", userID, err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionDeleteGroup); err != nil {
+		log.Printf("[/api/v1/group/remove]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/remove]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -178,6 +238,16 @@ func (apiConfig *ApiConfig) HandleRemoveGroup(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// the history of the group was removed along with it
+	apiConfig.MessageCache.Remove(params.GroupID.String())
+
+	// removing avatar of the group
+	if group.AvatarKey.Valid {
+		if err = apiConfig.Attachments.Delete(r.Context(), group.AvatarKey.String); err != nil {
+			log.Printf("[/api/v1/group/remove]: error removing avatar: %v", err)
+		}
+	}
+
 	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
 		AccessToken: newAccessToken,
 	})
@@ -215,6 +285,24 @@ func (apiConfig *ApiConfig) HandleGetAllMembersOfGroup(w http.ResponseWriter, r
 		return
 	}
 
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/members]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	// checking if the requesting user is member of the group, subscribers of a channel are only visible to its admins
+	permission := permissionViewGroup
+	if group.Type == groupTypeChannel {
+		permission = permissionViewSubscribers
+	}
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permission); err != nil {
+		log.Printf("[/api/v1/group/members]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
 	// fetching all the group members
 	groupMembers, err := apiConfig.DB.GetGroupMembers(r.Context(), database.GetGroupMembersParams{
 		GroupID: params.GroupID,
@@ -274,31 +362,53 @@ func (apiConfig *ApiConfig) HandleAddUserToGroup(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// checking if the requesting user id admin or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
-		UserID:  userID,
-		GroupID: params.GroupID,
-	}); err != nil {
-		log.Printf("[/api/v1/group/user/add]: requesting user is not admin: %v", err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[/api/v1/group/user/add]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
 		return
 	}
 
-	// adding user to group
-	if err = apiConfig.DB.AddUserToGroup(r.Context(), database.AddUserToGroupParams{
-		UserID:  user.ID,
-		GroupID: params.GroupID,
-	}); err != nil {
+	// user who blocked the requesting user can not be added to group by them
+	blocked, err := apiConfig.DB.IsUserBlocked(r.Context(), database.IsUserBlockedParams{
+		UserID:        user.ID,
+		BlockedUserID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/group/user/add]: error checking blocked users: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if blocked {
+		utility.RespondWithError(w, http.StatusForbidden, "you can not add this user to group")
+		return
+	}
+
+	// adding user to group, the transaction keeps the group locked while its member limit is checked
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/group/user/add]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+
+	err = addGroupMember(r.Context(), apiConfig.DB.WithTx(tx), params.GroupID, user.ID)
+	if errors.Is(err, errGroupFull) {
+		utility.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
 		log.Printf("[/api/v1/group/user/add]: error adding user to group: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// if user was previously part of the group then mark is_receiver_allowed_to_see = true
-	apiConfig.DB.MarkIsAllowedToSeeAsTrueForSpecificGroupMember(r.Context(), database.MarkIsAllowedToSeeAsTrueForSpecificGroupMemberParams{
-		MemberID: user.ID,
-		GroupID:  params.GroupID,
-	})
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/group/user/add]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	// emit group event ADD_USER_TO_GROUP
 	groupEvent := eventhandlers.GroupEvent{}
@@ -307,18 +417,19 @@ func (apiConfig *ApiConfig) HandleAddUserToGroup(w http.ResponseWriter, r *http.
 	// creating group actions
 	groupEvent.Group = eventhandlers.Group{
 		ID:          params.GroupID,
+		UserID:      user.ID,
 		Username:    user.Username,
 		Phonenumber: params.MemberPhonenumber,
 	}
 
-	// fetching group members phonenumbers
-	phonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
+	// fetching group members who will receive the event
+	recipients, err := apiConfig.memberEventRecipients(r.Context(), params.GroupID)
 	if err != nil {
-		log.Printf("[/api/v1/group/user/remove]: error fetching phonenumbers of group members: %v", err)
+		log.Printf("[/api/v1/group/user/remove]: error fetching group members: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	groupEvent.Phonenumbers = phonenumbers
+	groupEvent.UserIDs = recipients
 
 	// adding notification service
 	groupEvent.NotificationService = apiConfig.NotificationService
@@ -329,6 +440,11 @@ func (apiConfig *ApiConfig) HandleAddUserToGroup(w http.ResponseWriter, r *http.
 	// emitting event
 	apiConfig.GroupActionsEventEmitterChannel <- groupEvent
 
+	// adding the notice to the group history
+	if err = apiConfig.postSystemMessage(r.Context(), params.GroupID, systemActionMemberAdded, userID, user.ID); err != nil {
+		log.Printf("[/api/v1/group/user/add]: error posting system message: %v", err)
+	}
+
 	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
 		AccessToken: newAccessToken,
 	})
@@ -367,13 +483,27 @@ func (apiConfig *ApiConfig) HandleRemoveUserFromGroup(w http.ResponseWriter, r *
 		return
 	}
 
-	// checking if the requesting user id admin or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
-		UserID:  userID,
+	// checking if the role of requesting user allows this action
+	role, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionRemoveMembers)
+	if err != nil {
+		log.Printf("[/api/v1/group/user/remove]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	// members can only be removed by members having higher role than them
+	memberRole, err := apiConfig.DB.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  params.UserID,
 		GroupID: params.GroupID,
-	}); err != nil {
-		log.Printf("[/api/v1/group/user/remove]: requesting user is not admin: %v", err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	})
+	if err != nil {
+		log.Printf("[/api/v1/group/user/remove]: user %s is not member of group: %v", params.UserID, err)
+		utility.RespondWithError(w, http.StatusNotFound, "user is not a member of this group")
+		return
+	}
+
+	if !outranks(role, memberRole) {
+		utility.RespondWithError(w, http.StatusForbidden, "you can not remove a member with same or higher role")
 		return
 	}
 
@@ -400,18 +530,19 @@ func (apiConfig *ApiConfig) HandleRemoveUserFromGroup(w http.ResponseWriter, r *
 	}
 	groupEvent.Group = eventhandlers.Group{
 		ID:          params.GroupID,
+		UserID:      params.UserID,
 		Username:    user.Username,
 		Phonenumber: user.Phonenumber,
 	}
 
-	// fetching group members phonenumbers
-	phonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
+	// fetching group members who will receive the event
+	recipients, err := apiConfig.memberEventRecipients(r.Context(), params.GroupID)
 	if err != nil {
-		log.Printf("[/api/v1/group/user/remove]: error fetching phonenumbers of group members: %v", err)
+		log.Printf("[/api/v1/group/user/remove]: error fetching group members: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	groupEvent.Phonenumbers = phonenumbers
+	groupEvent.UserIDs = recipients
 
 	// adding notification service
 	groupEvent.NotificationService = apiConfig.NotificationService
@@ -422,16 +553,25 @@ func (apiConfig *ApiConfig) HandleRemoveUserFromGroup(w http.ResponseWriter, r *
 	// emitting event
 	apiConfig.GroupActionsEventEmitterChannel <- groupEvent
 
+	// adding the notice to the group history
+	if err = apiConfig.postSystemMessage(r.Context(), params.GroupID, systemActionMemberRemoved, userID, params.UserID); err != nil {
+		log.Printf("[/api/v1/group/user/remove]: error posting system message: %v", err)
+	}
+
 	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
 		AccessToken: newAccessToken,
 	})
 }
 
-// endpoint: /api/v1/group/make/user/admin
-func (apiConfig *ApiConfig) HandleMakeUserAdmin(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+/*
+endpoint: /api/v1/group/leave
+This endpoint lets the requesting user leave the group. If the owner leaves, the member
+with the highest role who has been in the group the longest becomes the new owner and
+if the requesting user was the last member the group is dissolved
+*/
+func (apiConfig *ApiConfig) HandleLeaveGroup(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
 		GroupID uuid.UUID `json:"group_id"`
-		UserID  uuid.UUID `json:"user_id"`
 	}
 
 	// extracting request body
@@ -439,84 +579,236 @@ func (apiConfig *ApiConfig) HandleMakeUserAdmin(w http.ResponseWriter, r *http.R
 	params := request{}
 	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("[/api/v1/group/admin/make]: error decoding request body: %v", err)
+		log.Printf("[/api/v1/group/leave]: error decoding request body: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// validating request body
 	if params.GroupID == uuid.Nil {
-		log.Printf("[/api/v1/group/admin/make]: empty group id field")
+		log.Printf("[/api/v1/group/leave]: empty group id field")
 		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
 		return
 	}
 
-	if params.UserID == uuid.Nil {
-		log.Printf("[/api/v1/group/admin/make]: empty user id field")
-		utility.RespondWithError(w, http.StatusNotAcceptable, "empty user id field")
+	apiConfig.leaveGroup(w, r, userID, newAccessToken, params.GroupID, "/api/v1/group/leave")
+}
+
+// leaveGroup removes the requesting user from the group and keeps the group owned
+func (apiConfig *ApiConfig) leaveGroup(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string, groupID uuid.UUID, endpoint string) {
+	type response struct {
+		Dissolved   bool   `json:"dissolved"`
+		AccessToken string `json:"access_token"`
+	}
+
+	// group is locked so that concurrent leaves and role changes can not leave it without an owner
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[%s]: error starting transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	if _, err = qtx.LockGroup(r.Context(), groupID); err != nil {
+		log.Printf("[%s]: error locking group: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
 		return
 	}
 
-	// checking if the requesting user is admin or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
+	if _, err = qtx.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
 		UserID:  userID,
-		GroupID: params.GroupID,
+		GroupID: groupID,
 	}); err != nil {
-		log.Printf("[/api/v1/group/admin/make]: requesting user is not admin: %v", err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		log.Printf("[%s]: user %s is not member of group: %v", endpoint, userID, err)
+		utility.RespondWithError(w, http.StatusNotFound, errNotGroupMember.Error())
 		return
 	}
 
-	// making user admin
-	if err = apiConfig.DB.MakeUserAdmin(r.Context(), database.MakeUserAdminParams{
-		GroupID: params.GroupID,
-		UserID:  params.UserID,
+	if err = qtx.RemoveUserFromGroup(r.Context(), database.RemoveUserFromGroupParams{
+		UserID:  userID,
+		GroupID: groupID,
 	}); err != nil {
-		log.Printf("[/api/v1/group/admin/make]: error making user admin: %v", err)
+		log.Printf("[%s]: error removing user from group: %v", endpoint, err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// emit group event MADE_ADMIN
-	groupEvent := eventhandlers.GroupEvent{}
-	groupEvent.Name = eventhandlers.MADE_ADMIN
+	if err = qtx.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeberWhoLeaves(r.Context(), database.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeberWhoLeavesParams{
+		GroupID:  groupID,
+		MemberID: userID,
+	}); err != nil {
+		log.Printf("[%s]: error hiding group messages from user: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// creating group action
-	user, err := apiConfig.DB.GetUserById(r.Context(), params.UserID)
+	successorID, dissolved, err := services.KeepGroupOwned(r.Context(), qtx, groupID)
 	if err != nil {
-		log.Printf("[/api/v1/group/admin/make]: error fetching requested user information: %v", err)
+		log.Printf("[%s]: error finding new owner of group: %v", endpoint, err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	groupEvent.Group = eventhandlers.Group{
-		ID:          params.GroupID,
-		Username:    user.Username,
-		Phonenumber: user.Phonenumber,
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[%s]: error committing transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the history of a dissolved group was removed along with it
+	if dissolved {
+		apiConfig.MessageCache.Remove(groupID.String())
+	}
+
+	// remaining members are told about the leave and the new owner
+	if !dissolved {
+		if err = apiConfig.emitGroupMemberEvent(r.Context(), eventhandlers.LEFT_GROUP, groupID, userID, ""); err != nil {
+			log.Printf("[%s]: error emitting %s event: %v", endpoint, eventhandlers.LEFT_GROUP, err)
+		}
+
+		if err = apiConfig.postSystemMessage(r.Context(), groupID, systemActionMemberLeft, userID, userID); err != nil {
+			log.Printf("[%s]: error posting system message: %v", endpoint, err)
+		}
+
+		if successorID != uuid.Nil {
+			if err = apiConfig.emitGroupMemberEvent(r.Context(), eventhandlers.OWNERSHIP_TRANSFERRED, groupID, successorID, roleOwner); err != nil {
+				log.Printf("[%s]: error emitting %s event: %v", endpoint, eventhandlers.OWNERSHIP_TRANSFERRED, err)
+			}
+		}
 	}
 
-	// fetching phonenumbers of group members
-	phonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Dissolved:   dissolved,
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/group/owner/transfer
+This endpoint makes another member the owner of the group, the previous owner becomes an admin
+*/
+func (apiConfig *ApiConfig) HandleTransferGroupOwnership(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+		UserID  uuid.UUID `json:"user_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
 	if err != nil {
-		log.Printf("[/api/v1/group/admin/make]: error fetching phonenumbers for group members: %v", err)
+		log.Printf("[/api/v1/group/owner/transfer]: error decoding request body: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	groupEvent.Phonenumbers = phonenumbers
 
-	// adding notification service
-	groupEvent.NotificationService = apiConfig.NotificationService
+	// validating request body
+	if params.GroupID == uuid.Nil || params.UserID == uuid.Nil {
+		log.Printf("[/api/v1/group/owner/transfer]: empty group id or user id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id or user id field")
+		return
+	}
 
-	// adding event emitting time
-	groupEvent.EmittedAt = time.Now()
+	if params.UserID == userID {
+		utility.RespondWithError(w, http.StatusConflict, "you already own this group")
+		return
+	}
 
-	// emitting event
-	apiConfig.GroupActionsEventEmitterChannel <- groupEvent
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	if _, err = qtx.LockGroup(r.Context(), params.GroupID); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error locking group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	// role is checked after locking the group so that two transfers can not both succeed
+	if _, err := authorizeGroupActionWith(r.Context(), qtx, params.GroupID, userID, permissionTransferOwnership); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	if _, err = qtx.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  params.UserID,
+		GroupID: params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: user %s is not member of group: %v", params.UserID, err)
+		utility.RespondWithError(w, http.StatusNotFound, "user is not a member of this group")
+		return
+	}
+
+	if err = qtx.UpdateGroupMemberRole(r.Context(), database.UpdateGroupMemberRoleParams{
+		Role:    roleAdmin,
+		UserID:  userID,
+		GroupID: params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error making requesting user admin: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = qtx.UpdateGroupMemberRole(r.Context(), database.UpdateGroupMemberRoleParams{
+		Role:    roleOwner,
+		UserID:  params.UserID,
+		GroupID: params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error making user owner: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.emitGroupMemberEvent(r.Context(), eventhandlers.OWNERSHIP_TRANSFERRED, params.GroupID, params.UserID, roleOwner); err != nil {
+		log.Printf("[/api/v1/group/owner/transfer]: error emitting %s event: %v", eventhandlers.OWNERSHIP_TRANSFERRED, err)
+	}
 
 	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
 		AccessToken: newAccessToken,
 	})
 }
 
+// endpoint: /api/v1/group/make/user/admin
+func (apiConfig *ApiConfig) HandleMakeUserAdmin(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+		UserID  uuid.UUID `json:"user_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/admin/make]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.changeGroupMemberRole(w, r, userID, newAccessToken, groupRoleChange{
+		GroupID:   params.GroupID,
+		UserID:    params.UserID,
+		Role:      roleAdmin,
+		EventName: eventhandlers.MADE_ADMIN,
+		Endpoint:  "/api/v1/group/admin/make",
+	})
+}
+
 // endpoint: /api/v1/group/remove/user/admin
 func (apiConfig *ApiConfig) HandleRemoveUserFromAdmin(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
@@ -534,65 +826,145 @@ func (apiConfig *ApiConfig) HandleRemoveUserFromAdmin(w http.ResponseWriter, r *
 		return
 	}
 
+	apiConfig.changeGroupMemberRole(w, r, userID, newAccessToken, groupRoleChange{
+		GroupID:   params.GroupID,
+		UserID:    params.UserID,
+		Role:      roleMember,
+		EventName: eventhandlers.REMOVE_ADMIN,
+		Endpoint:  "/api/v1/group/admin/remove",
+	})
+}
+
+/*
+endpoint: /api/v1/group/member/role
+This endpoint changes the role of a group member to admin, moderator or member
+*/
+func (apiConfig *ApiConfig) HandleChangeGroupMemberRole(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+		UserID  uuid.UUID `json:"user_id"`
+		Role    string    `json:"role"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/member/role]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.changeGroupMemberRole(w, r, userID, newAccessToken, groupRoleChange{
+		GroupID:   params.GroupID,
+		UserID:    params.UserID,
+		Role:      params.Role,
+		EventName: eventhandlers.ROLE_CHANGED,
+		Endpoint:  "/api/v1/group/member/role",
+	})
+}
+
+// information about the role change requested through one of the role change endpoints
+type groupRoleChange struct {
+	GroupID   uuid.UUID
+	UserID    uuid.UUID
+	Role      string
+	EventName string
+	Endpoint  string
+}
+
+/*
+changeGroupMemberRole changes the role of a member and emits the group event for it.
+The requesting user must outrank the member and can not grant a role higher than its own.
+Ownership can not be granted through role change
+*/
+func (apiConfig *ApiConfig) changeGroupMemberRole(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string, change groupRoleChange) {
 	// validating request body
-	if params.UserID == uuid.Nil {
-		log.Printf("[/api/v1/group/admin/remove]: empty user id field")
+	if change.GroupID == uuid.Nil {
+		log.Printf("[%s]: empty group id field", change.Endpoint)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+		return
+	}
+
+	if change.UserID == uuid.Nil {
+		log.Printf("[%s]: empty user id field", change.Endpoint)
 		utility.RespondWithError(w, http.StatusNotAcceptable, "empty user id field")
 		return
 	}
 
-	if params.GroupID == uuid.Nil {
-		log.Printf("[/api/v1/group/admin/remove]: empty group id field")
-		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+	if change.Role != roleAdmin && change.Role != roleModerator && change.Role != roleMember {
+		log.Printf("[%s]: invalid role %s", change.Endpoint, change.Role)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "role can be one of admin, moderator or member")
 		return
 	}
 
-	// checking if the requesting user is admin or not
-	if _, err := apiConfig.DB.IsUserAdmin(r.Context(), database.IsUserAdminParams{
-		UserID:  userID,
-		GroupID: params.GroupID,
-	}); err != nil {
-		log.Printf("[/api/v1/group/admin/remove]: requesting user is not admin: %v", err)
-		utility.RespondWithError(w, http.StatusUnauthorized, err.Error())
+	// checking if the role of requesting user allows this action
+	role, err := apiConfig.authorizeGroupAction(r.Context(), change.GroupID, userID, permissionChangeRoles)
+	if err != nil {
+		log.Printf("[%s]: requesting user %s is not allowed: %v", change.Endpoint, userID, err)
+		respondWithGroupAuthorizationError(w, err)
 		return
 	}
 
-	// removing requested user from admin
-	if err = apiConfig.DB.RemoveUserFromAdmin(r.Context(), database.RemoveUserFromAdminParams{
-		UserID:  params.UserID,
-		GroupID: params.GroupID,
+	memberRole, err := apiConfig.DB.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  change.UserID,
+		GroupID: change.GroupID,
+	})
+	if err != nil {
+		log.Printf("[%s]: user %s is not member of group: %v", change.Endpoint, change.UserID, err)
+		utility.RespondWithError(w, http.StatusNotFound, "user is not a member of this group")
+		return
+	}
+
+	if !outranks(role, memberRole) || outranks(change.Role, role) {
+		utility.RespondWithError(w, http.StatusForbidden, "you can not change the role of this member to "+change.Role)
+		return
+	}
+
+	if memberRole == change.Role {
+		utility.RespondWithError(w, http.StatusConflict, "member already has role "+change.Role)
+		return
+	}
+
+	// changing role of the member
+	if err = apiConfig.DB.UpdateGroupMemberRole(r.Context(), database.UpdateGroupMemberRoleParams{
+		Role:    change.Role,
+		UserID:  change.UserID,
+		GroupID: change.GroupID,
 	}); err != nil {
-		log.Printf("[/api/v1/group/admin/remove]: error removing requested user from admin: %v", err)
+		log.Printf("[%s]: error changing role of member: %v", change.Endpoint, err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// emit group event REMOVE_ADMIN
+	// emitting role change event
 	groupEvent := eventhandlers.GroupEvent{}
-	groupEvent.Name = eventhandlers.REMOVE_ADMIN
+	groupEvent.Name = change.EventName
 
-	// fetching phonenumbers of groupmembers
-	phonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
+	// creating group action
+	user, err := apiConfig.DB.GetUserById(r.Context(), change.UserID)
 	if err != nil {
-		log.Printf("[/api/v1/group/admin/remove]: error fetching phonenumbers of group members: %v", err)
+		log.Printf("[%s]: error fetching requested user information: %v", change.Endpoint, err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	groupEvent.Phonenumbers = phonenumbers
+	groupEvent.Group = eventhandlers.Group{
+		ID:          change.GroupID,
+		UserID:      change.UserID,
+		Username:    user.Username,
+		Phonenumber: user.Phonenumber,
+		Role:        change.Role,
+	}
 
-	// creating group action
-	user, err := apiConfig.DB.GetUserById(r.Context(), params.UserID)
+	// fetching group members who will receive the event
+	recipients, err := apiConfig.memberEventRecipients(r.Context(), change.GroupID)
 	if err != nil {
-		log.Printf("[/api/v1/group/admin/remove]: error fetching requested user information: %v", err)
+		log.Printf("[%s]: error fetching group members: %v", change.Endpoint, err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	group := eventhandlers.Group{
-		ID:          params.GroupID,
-		Phonenumber: user.Phonenumber,
-		Username:    user.Username,
-	}
-	groupEvent.Group = group
+	groupEvent.UserIDs = recipients
 
 	// adding notification service
 	groupEvent.NotificationService = apiConfig.NotificationService
@@ -639,6 +1011,13 @@ func (apiConfig *ApiConfig) HandleGetAllAdminsForGroup(w http.ResponseWriter, r
 		return
 	}
 
+	// checking if the requesting user is member of the group
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup); err != nil {
+		log.Printf("[/api/v1/group/list/admins]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
 	// finding all the admins for group
 	admins, err := apiConfig.DB.GetGroupAdmins(r.Context(), params.GroupID)
 	if err != nil {
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// roles of the members of a group
+const (
+	roleOwner     = "owner"
+	roleAdmin     = "admin"
+	roleModerator = "moderator"
+	roleMember    = "member"
+)
+
+// types of a group, members of a channel are its subscribers
+const (
+	groupTypeGroup   = "group"
+	groupTypeChannel = "channel"
+)
+
+// actions on a group which require a permission
+type groupPermission int
+
+const (
+	permissionViewGroup groupPermission = iota
+	permissionEditGroupInfo
+	permissionPostMessages
+	permissionAddMembers
+	permissionRemoveMembers
+	permissionChangeRoles
+	permissionPinMessages
+	permissionDeleteOthersMessages
+	permissionChangeSettings
+	permissionDeleteGroup
+	permissionTransferOwnership
+	permissionViewSubscribers
+)
+
+/*
+permission matrix: roles which are allowed to perform each action.
+permissionEditGroupInfo and permissionPostMessages are only checked when the settings
+of the group restrict these actions to admins, otherwise every member is allowed.
+In a channel posting is always restricted and listing the members needs permissionViewSubscribers
+*/
+var groupPermissions = map[groupPermission][]string{
+	permissionViewGroup:            {roleOwner, roleAdmin, roleModerator, roleMember},
+	permissionEditGroupInfo:        {roleOwner, roleAdmin},
+	permissionPostMessages:         {roleOwner, roleAdmin},
+	permissionAddMembers:           {roleOwner, roleAdmin},
+	permissionRemoveMembers:        {roleOwner, roleAdmin, roleModerator},
+	permissionChangeRoles:          {roleOwner, roleAdmin},
+	permissionPinMessages:          {roleOwner, roleAdmin, roleModerator},
+	permissionDeleteOthersMessages: {roleOwner, roleAdmin, roleModerator},
+	permissionChangeSettings:       {roleOwner, roleAdmin},
+	permissionDeleteGroup:          {roleOwner},
+	permissionTransferOwnership:    {roleOwner},
+	permissionViewSubscribers:      {roleOwner, roleAdmin},
+}
+
+// rank of every role, a member can only act on members with lower rank than its own
+var roleRanks = map[string]int{
+	roleMember:    1,
+	roleModerator: 2,
+	roleAdmin:     3,
+	roleOwner:     4,
+}
+
+var (
+	errNotGroupMember        = errors.New("you are not a member of this group")
+	errGroupActionNotAllowed = errors.New("your role in the group does not allow this action")
+	errGroupFull             = errors.New("group has reached its member limit")
+)
+
+/*
+authorizeGroupAction checks whether the user is a member of the group and whether
+the role of the user allows the requested action. On success the role of the user
+is returned so that handlers can make further checks against the role of the member
+on which the action is performed
+*/
+func (apiConfig *ApiConfig) authorizeGroupAction(ctx context.Context, groupID, userID uuid.UUID, permission groupPermission) (string, error) {
+	return authorizeGroupActionWith(ctx, apiConfig.DB, groupID, userID, permission)
+}
+
+// authorizeGroupActionWith is authorizeGroupAction reading the role through queries, handlers pass their transaction to check the role of a locked group
+func authorizeGroupActionWith(ctx context.Context, queries *database.Queries, groupID, userID uuid.UUID, permission groupPermission) (string, error) {
+	role, err := queries.GetGroupMemberRole(ctx, database.GetGroupMemberRoleParams{
+		UserID:  userID,
+		GroupID: groupID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errNotGroupMember
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !slices.Contains(groupPermissions[permission], role) {
+		return role, errGroupActionNotAllowed
+	}
+
+	return role, nil
+}
+
+// authorizeRestrictedGroupAction is authorizeGroupAction for actions which the group settings can restrict to admins
+func (apiConfig *ApiConfig) authorizeRestrictedGroupAction(ctx context.Context, groupID, userID uuid.UUID, permission groupPermission, restricted bool) (string, error) {
+	if !restricted {
+		permission = permissionViewGroup
+	}
+
+	return apiConfig.authorizeGroupAction(ctx, groupID, userID, permission)
+}
+
+// outranks reports whether a member with role can act on a member with otherRole
+func outranks(role, otherRole string) bool {
+	return roleRanks[role] > roleRanks[otherRole]
+}
+
+// respondWithGroupAuthorizationError maps the errors of authorizeGroupAction to http responses
+func respondWithGroupAuthorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNotGroupMember):
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errGroupActionNotAllowed):
+		utility.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,409 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// limits of the group information, they match the columns of groups table
+const (
+	maxGroupNameLength        = 100
+	maxGroupDescriptionLength = 500
+	minGroupMemberLimit       = 2
+	maxGroupMemberLimit       = 1024
+)
+
+// endpoint: /api/v1/group/info
+func (apiConfig *ApiConfig) HandleGetGroupInfo(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+	}
+
+	type response struct {
+		ID                    uuid.UUID `json:"id"`
+		Name                  string    `json:"name"`
+		Description           string    `json:"description"`
+		HasAvatar             bool      `json:"has_avatar"`
+		MembersCount          int64     `json:"members_count"`
+		MemberLimit           int32     `json:"member_limit"`
+		OnlyAdminsCanPost     bool      `json:"only_admins_can_post"`
+		OnlyAdminsCanEditInfo bool      `json:"only_admins_can_edit_info"`
+		MessageTimer          int32     `json:"message_timer"`
+		Role                  string    `json:"role"`
+		CreatedAt             string    `json:"created_at"`
+		UpdatedAt             string    `json:"updated_at"`
+		AccessToken           string    `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/info]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// checking if the requesting user is member of the group
+	role, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup)
+	if err != nil {
+		log.Printf("[/api/v1/group/info]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/info]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	membersCount, err := apiConfig.DB.GroupMembersCount(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/info]: error counting group members: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		ID:                    group.ID,
+		Name:                  group.Name,
+		Description:           group.Description.String,
+		HasAvatar:             group.AvatarKey.Valid,
+		MembersCount:          membersCount,
+		MemberLimit:           group.MemberLimit,
+		OnlyAdminsCanPost:     group.OnlyAdminsCanPost,
+		OnlyAdminsCanEditInfo: group.OnlyAdminsCanEditInfo,
+		MessageTimer:          group.MessageTimer.Int32,
+		Role:                  role,
+		CreatedAt:             group.CreatedAt.Format(time.RFC1123),
+		UpdatedAt:             group.UpdatedAt.Format(time.RFC1123),
+		AccessToken:           newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/group/update/settings
+
+the settings which are not sent in the request body remain unchanged and the
+member limit can not be lowered below the current number of members
+*/
+func (apiConfig *ApiConfig) HandleUpdateGroupSettings(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID               uuid.UUID `json:"group_id"`
+		MemberLimit           *int32    `json:"member_limit"`
+		OnlyAdminsCanPost     *bool     `json:"only_admins_can_post"`
+		OnlyAdminsCanEditInfo *bool     `json:"only_admins_can_edit_info"`
+	}
+
+	type response struct {
+		MemberLimit           int32  `json:"member_limit"`
+		OnlyAdminsCanPost     bool   `json:"only_admins_can_post"`
+		OnlyAdminsCanEditInfo bool   `json:"only_admins_can_edit_info"`
+		UpdatedAt             string `json:"updated_at"`
+		AccessToken           string `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil {
+		log.Printf("[/api/v1/group/update/settings]: empty group id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+		return
+	}
+
+	if params.MemberLimit != nil && (*params.MemberLimit < minGroupMemberLimit || *params.MemberLimit > maxGroupMemberLimit) {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "member limit must be between 2 and 1024")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionChangeSettings); err != nil {
+		log.Printf("[/api/v1/group/update/settings]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	// group is locked so that no member joins while the member limit is lowered
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	if _, err = qtx.LockGroup(r.Context(), params.GroupID); err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error locking group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	group, err := qtx.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	changes := map[string]any{}
+	if params.MemberLimit != nil && *params.MemberLimit != group.MemberLimit {
+		membersCount, err := qtx.GroupMembersCount(r.Context(), params.GroupID)
+		if err != nil {
+			log.Printf("[/api/v1/group/update/settings]: error counting group members: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if int64(*params.MemberLimit) < membersCount {
+			utility.RespondWithError(w, http.StatusConflict, "member limit can not be lower than the number of members")
+			return
+		}
+
+		group.MemberLimit = *params.MemberLimit
+		changes["member_limit"] = group.MemberLimit
+	}
+	if params.OnlyAdminsCanPost != nil && *params.OnlyAdminsCanPost != group.OnlyAdminsCanPost {
+		group.OnlyAdminsCanPost = *params.OnlyAdminsCanPost
+		changes["only_admins_can_post"] = group.OnlyAdminsCanPost
+	}
+	if params.OnlyAdminsCanEditInfo != nil && *params.OnlyAdminsCanEditInfo != group.OnlyAdminsCanEditInfo {
+		group.OnlyAdminsCanEditInfo = *params.OnlyAdminsCanEditInfo
+		changes["only_admins_can_edit_info"] = group.OnlyAdminsCanEditInfo
+	}
+
+	settings, err := qtx.UpdateGroupSettings(r.Context(), database.UpdateGroupSettingsParams{
+		MemberLimit:           group.MemberLimit,
+		OnlyAdminsCanPost:     group.OnlyAdminsCanPost,
+		OnlyAdminsCanEditInfo: group.OnlyAdminsCanEditInfo,
+		ID:                    params.GroupID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error updating group settings: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.emitGroupUpdated(r.Context(), params.GroupID, userID, changes); err != nil {
+		log.Printf("[/api/v1/group/update/settings]: error emitting %s event: %v", eventhandlers.GROUP_UPDATED, err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		MemberLimit:           settings.MemberLimit,
+		OnlyAdminsCanPost:     settings.OnlyAdminsCanPost,
+		OnlyAdminsCanEditInfo: settings.OnlyAdminsCanEditInfo,
+		UpdatedAt:             settings.UpdatedAt.Format(time.RFC1123),
+		AccessToken:           newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/group/update/avatar
+
+the avatar is sent as multipart form file with the field name avatar along with
+the form field group_id and replaces the previous avatar of the group
+*/
+func (apiConfig *ApiConfig) HandleUpdateGroupAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	avatar, file, ok := readAvatarUpload(w, r, "/api/v1/group/update/avatar")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	groupID, err := uuid.Parse(r.FormValue("group_id"))
+	if err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: invalid group id: %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "invalid group id")
+		return
+	}
+
+	group, err := apiConfig.DB.GetGroup(r.Context(), groupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeRestrictedGroupAction(r.Context(), groupID, userID, permissionEditGroupInfo, group.OnlyAdminsCanEditInfo); err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	// saving new avatar
+	avatarKey := "groups/" + groupID.String() + "/avatar/" + uuid.NewString()
+	if err = apiConfig.Attachments.Put(r.Context(), avatarKey, avatar); err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: error saving avatar: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.DB.UpdateGroupAvatar(r.Context(), database.UpdateGroupAvatarParams{
+		AvatarKey: sql.NullString{
+			String: avatarKey,
+			Valid:  true,
+		},
+		ID: groupID,
+	}); err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: error updating avatar key: %v", err)
+		apiConfig.Attachments.Delete(r.Context(), avatarKey)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// removing previous avatar
+	if group.AvatarKey.Valid {
+		if err = apiConfig.Attachments.Delete(r.Context(), group.AvatarKey.String); err != nil {
+			log.Printf("[/api/v1/group/update/avatar]: error removing previous avatar: %v", err)
+		}
+	}
+
+	if err = apiConfig.emitGroupUpdated(r.Context(), groupID, userID, map[string]any{"has_avatar": true}); err != nil {
+		log.Printf("[/api/v1/group/update/avatar]: error emitting %s event: %v", eventhandlers.GROUP_UPDATED, err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/remove/avatar
+func (apiConfig *ApiConfig) HandleRemoveGroupAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: error fetching group: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "group not found")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeRestrictedGroupAction(r.Context(), params.GroupID, userID, permissionEditGroupInfo, group.OnlyAdminsCanEditInfo); err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	if !group.AvatarKey.Valid {
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+
+	if err = apiConfig.DB.UpdateGroupAvatar(r.Context(), database.UpdateGroupAvatarParams{
+		ID: params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: error removing avatar key: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.Attachments.Delete(r.Context(), group.AvatarKey.String); err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: error removing avatar: %v", err)
+	}
+
+	if err = apiConfig.emitGroupUpdated(r.Context(), params.GroupID, userID, map[string]any{"has_avatar": false}); err != nil {
+		log.Printf("[/api/v1/group/remove/avatar]: error emitting %s event: %v", eventhandlers.GROUP_UPDATED, err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/avatar
+func (apiConfig *ApiConfig) HandleGetGroupAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/avatar]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// checking if the requesting user is member of the group
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup); err != nil {
+		log.Printf("[/api/v1/group/avatar]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+	if err != nil || !group.AvatarKey.Valid {
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+
+	apiConfig.serveAvatar(r.Context(), w, group.AvatarKey.String, newAccessToken, "/api/v1/group/avatar")
+}
+
+// emitGroupUpdated sends the changed fields of the group to all its members, nothing is sent when nothing changed
+func (apiConfig *ApiConfig) emitGroupUpdated(ctx context.Context, groupID, updatedBy uuid.UUID, changes map[string]any) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	recipients, err := apiConfig.groupRecipients(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	apiConfig.GroupActionsEventEmitterChannel <- eventhandlers.GroupEvent{
+		Name: eventhandlers.GROUP_UPDATED,
+		Group: eventhandlers.Group{
+			ID:      groupID,
+			UserID:  updatedBy,
+			Changes: changes,
+		},
+		UserIDs:             recipients,
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
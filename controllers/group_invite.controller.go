@@ -0,0 +1,620 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// number of random bytes in an invite code, encoded to 16 url safe characters
+const inviteCodeLength = 12
+
+// invite information sent to the admins of the group
+type groupInvite struct {
+	ID               uuid.UUID `json:"id"`
+	Code             string    `json:"code"`
+	CreatedBy        uuid.UUID `json:"created_by"`
+	ExpiresAt        string    `json:"expires_at,omitempty"`
+	MaxUses          int32     `json:"max_uses,omitempty"`
+	Uses             int32     `json:"uses"`
+	RequiresApproval bool      `json:"requires_approval"`
+	CreatedAt        string    `json:"created_at"`
+}
+
+func newGroupInvite(invite database.GroupInvite) groupInvite {
+	response := groupInvite{
+		ID:               invite.ID,
+		Code:             invite.Code,
+		CreatedBy:        invite.CreatedBy,
+		MaxUses:          invite.MaxUses.Int32,
+		Uses:             invite.Uses,
+		RequiresApproval: invite.RequiresApproval,
+		CreatedAt:        invite.CreatedAt.Format(time.RFC1123),
+	}
+	if invite.ExpiresAt.Valid {
+		response.ExpiresAt = invite.ExpiresAt.Time.Format(time.RFC1123)
+	}
+
+	return response
+}
+
+func generateInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(code), nil
+}
+
+/*
+endpoint: /api/v1/group/invite/create
+This endpoint creates an invite code for the group. expires_in is the lifetime of the
+invite in seconds and max_uses the number of users who can join with it, zero means no limit.
+When requires_approval is set users joining with the invite have to be approved by an admin
+*/
+func (apiConfig *ApiConfig) HandleCreateGroupInvite(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID          uuid.UUID `json:"group_id"`
+		ExpiresIn        int64     `json:"expires_in"`
+		MaxUses          int32     `json:"max_uses"`
+		RequiresApproval bool      `json:"requires_approval"`
+	}
+
+	type response struct {
+		Invite      groupInvite `json:"invite"`
+		AccessToken string      `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/invite/create]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil {
+		log.Printf("[/api/v1/group/invite/create]: empty group id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+		return
+	}
+
+	if params.ExpiresIn < 0 || params.MaxUses < 0 {
+		log.Printf("[/api/v1/group/invite/create]: negative expires_in or max_uses")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "expires_in and max_uses can not be negative")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[/api/v1/group/invite/create]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		log.Printf("[/api/v1/group/invite/create]: error generating invite code: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	invite, err := apiConfig.DB.CreateGroupInvite(r.Context(), database.CreateGroupInviteParams{
+		GroupID:   params.GroupID,
+		Code:      code,
+		CreatedBy: userID,
+		ExpiresAt: sql.NullTime{
+			Time:  time.Now().Add(time.Duration(params.ExpiresIn) * time.Second),
+			Valid: params.ExpiresIn > 0,
+		},
+		MaxUses: sql.NullInt32{
+			Int32: params.MaxUses,
+			Valid: params.MaxUses > 0,
+		},
+		RequiresApproval: params.RequiresApproval,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/group/invite/create]: error creating invite: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		Invite:      newGroupInvite(invite),
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/invites
+func (apiConfig *ApiConfig) HandleGetGroupInvites(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+	}
+
+	type response struct {
+		Invites     []groupInvite `json:"invites"`
+		AccessToken string        `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/invites]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil {
+		log.Printf("[/api/v1/group/invites]: empty group id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[/api/v1/group/invites]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	invites, err := apiConfig.DB.GetGroupInvites(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/invites]: error fetching invites: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	groupInvites := make([]groupInvite, 0, len(invites))
+	for _, invite := range invites {
+		groupInvites = append(groupInvites, newGroupInvite(invite))
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Invites:     groupInvites,
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/invite/revoke
+func (apiConfig *ApiConfig) HandleRevokeGroupInvite(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID  uuid.UUID `json:"group_id"`
+		InviteID uuid.UUID `json:"invite_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/invite/revoke]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil || params.InviteID == uuid.Nil {
+		log.Printf("[/api/v1/group/invite/revoke]: empty group id or invite id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id or invite id field")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[/api/v1/group/invite/revoke]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	revoked, err := apiConfig.DB.RevokeGroupInvite(r.Context(), database.RevokeGroupInviteParams{
+		ID:      params.InviteID,
+		GroupID: params.GroupID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/group/invite/revoke]: error revoking invite: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if revoked == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "invite not found")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/group/join
+This endpoint lets the requesting user join a group using an invite code.
+If the invite requires approval a join request is created and the admins of the group
+are notified instead, the user becomes member once an admin approves the request
+*/
+func (apiConfig *ApiConfig) HandleJoinGroup(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Code string `json:"code"`
+	}
+
+	type response struct {
+		GroupID         uuid.UUID `json:"group_id"`
+		PendingApproval bool      `json:"pending_approval"`
+		AccessToken     string    `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/join]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.Code == "" {
+		log.Printf("[/api/v1/group/join]: empty code field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty code field")
+		return
+	}
+
+	invite, err := apiConfig.DB.GetGroupInviteByCode(r.Context(), params.Code)
+	if err != nil {
+		log.Printf("[/api/v1/group/join]: invalid invite code: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "invalid invite code")
+		return
+	}
+
+	// checking if the requesting user is already a member of the group
+	if _, err = apiConfig.DB.GetGroupMemberRole(r.Context(), database.GetGroupMemberRoleParams{
+		UserID:  userID,
+		GroupID: invite.GroupID,
+	}); err == nil {
+		utility.RespondWithError(w, http.StatusConflict, "you are already a member of this group")
+		return
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("[/api/v1/group/join]: error checking group membership: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// using the invite and joining the group or creating join request in a single transaction
+	// so that a failed join does not count against the usage limit of the invite
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/group/join]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	used, err := qtx.UseGroupInvite(r.Context(), invite.ID)
+	if err != nil {
+		log.Printf("[/api/v1/group/join]: error using invite: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if used == 0 {
+		utility.RespondWithError(w, http.StatusGone, "invite has expired or reached its usage limit")
+		return
+	}
+
+	if invite.RequiresApproval {
+		created, err := qtx.CreateGroupJoinRequest(r.Context(), database.CreateGroupJoinRequestParams{
+			GroupID: invite.GroupID,
+			UserID:  userID,
+			InviteID: uuid.NullUUID{
+				UUID:  invite.ID,
+				Valid: true,
+			},
+		})
+		if err != nil {
+			log.Printf("[/api/v1/group/join]: error creating join request: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if created == 0 {
+			utility.RespondWithError(w, http.StatusConflict, "you have already requested to join this group")
+			return
+		}
+	} else if err = addGroupMember(r.Context(), qtx, invite.GroupID, userID); errors.Is(err, errGroupFull) {
+		utility.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		log.Printf("[/api/v1/group/join]: error adding user to group: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/group/join]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	eventName := eventhandlers.ADD_USER_TO_GROUP
+	if invite.RequiresApproval {
+		eventName = eventhandlers.PENDING_JOIN_REQUEST
+	}
+	if err = apiConfig.emitGroupMemberEvent(r.Context(), eventName, invite.GroupID, userID, roleMember); err != nil {
+		log.Printf("[/api/v1/group/join]: error emitting %s event: %v", eventName, err)
+	}
+
+	// adding the notice to the group history
+	if !invite.RequiresApproval {
+		if err = apiConfig.postSystemMessage(r.Context(), invite.GroupID, systemActionMemberJoined, userID, userID); err != nil {
+			log.Printf("[/api/v1/group/join]: error posting system message: %v", err)
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		GroupID:         invite.GroupID,
+		PendingApproval: invite.RequiresApproval,
+		AccessToken:     newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/join/requests
+func (apiConfig *ApiConfig) HandleGetGroupJoinRequests(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+	}
+
+	type joinRequest struct {
+		UserID      uuid.UUID `json:"user_id"`
+		Username    string    `json:"username"`
+		DisplayName string    `json:"display_name,omitempty"`
+		CreatedAt   string    `json:"created_at"`
+	}
+
+	type response struct {
+		JoinRequests []joinRequest `json:"join_requests"`
+		AccessToken  string        `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/group/join/requests]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil {
+		log.Printf("[/api/v1/group/join/requests]: empty group id field")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id field")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[/api/v1/group/join/requests]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	pendingRequests, err := apiConfig.DB.GetGroupJoinRequests(r.Context(), params.GroupID)
+	if err != nil {
+		log.Printf("[/api/v1/group/join/requests]: error fetching join requests: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	joinRequests := make([]joinRequest, 0, len(pendingRequests))
+	for _, pendingRequest := range pendingRequests {
+		joinRequests = append(joinRequests, joinRequest{
+			UserID:      pendingRequest.UserID,
+			Username:    pendingRequest.Username,
+			DisplayName: pendingRequest.DisplayName.String,
+			CreatedAt:   pendingRequest.CreatedAt.Format(time.RFC1123),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		JoinRequests: joinRequests,
+		AccessToken:  newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/group/join/requests/approve
+func (apiConfig *ApiConfig) HandleApproveGroupJoinRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.answerGroupJoinRequest(w, r, userID, newAccessToken, true, "/api/v1/group/join/requests/approve")
+}
+
+// endpoint: /api/v1/group/join/requests/decline
+func (apiConfig *ApiConfig) HandleDeclineGroupJoinRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.answerGroupJoinRequest(w, r, userID, newAccessToken, false, "/api/v1/group/join/requests/decline")
+}
+
+// answerGroupJoinRequest removes a pending join request and adds the user to the group if it was approved
+func (apiConfig *ApiConfig) answerGroupJoinRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string, approve bool, endpoint string) {
+	type request struct {
+		GroupID uuid.UUID `json:"group_id"`
+		UserID  uuid.UUID `json:"user_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[%s]: error decoding request body: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil || params.UserID == uuid.Nil {
+		log.Printf("[%s]: empty group id or user id field", endpoint)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id or user id field")
+		return
+	}
+
+	// checking if the role of requesting user allows this action
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionAddMembers); err != nil {
+		log.Printf("[%s]: requesting user %s is not allowed: %v", endpoint, userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[%s]: error starting transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	removed, err := qtx.RemoveGroupJoinRequest(r.Context(), database.RemoveGroupJoinRequestParams{
+		GroupID: params.GroupID,
+		UserID:  params.UserID,
+	})
+	if err != nil {
+		log.Printf("[%s]: error removing join request: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if removed == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "join request not found")
+		return
+	}
+
+	if approve {
+		err = addGroupMember(r.Context(), qtx, params.GroupID, params.UserID)
+		if errors.Is(err, errGroupFull) {
+			utility.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("[%s]: error adding user to group: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[%s]: error committing transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if approve {
+		if err = apiConfig.emitGroupMemberEvent(r.Context(), eventhandlers.ADD_USER_TO_GROUP, params.GroupID, params.UserID, roleMember); err != nil {
+			log.Printf("[%s]: error emitting %s event: %v", endpoint, eventhandlers.ADD_USER_TO_GROUP, err)
+		}
+
+		if err = apiConfig.postSystemMessage(r.Context(), params.GroupID, systemActionMemberJoined, userID, params.UserID); err != nil {
+			log.Printf("[%s]: error posting system message: %v", endpoint, err)
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+addGroupMember adds the user to the group as member and restores its access to earlier group messages.
+errGroupFull is returned when the group has reached its member limit, the limit can only be relied
+upon when db belongs to a transaction because the group stays locked until the transaction ends
+*/
+func addGroupMember(ctx context.Context, db *database.Queries, groupID, userID uuid.UUID) error {
+	if _, err := db.LockGroup(ctx, groupID); err != nil {
+		return err
+	}
+
+	group, err := db.GetGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	membersCount, err := db.GroupMembersCount(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	// channels have no member limit
+	if group.Type == groupTypeGroup && membersCount >= int64(group.MemberLimit) {
+		return errGroupFull
+	}
+
+	if err := db.AddUserToGroup(ctx, database.AddUserToGroupParams{
+		UserID:  userID,
+		GroupID: groupID,
+		Role:    roleMember,
+	}); err != nil {
+		return err
+	}
+
+	// if user was previously part of the group then mark is_receiver_allowed_to_see = true
+	return db.MarkIsAllowedToSeeAsTrueForSpecificGroupMember(ctx, database.MarkIsAllowedToSeeAsTrueForSpecificGroupMemberParams{
+		MemberID: userID,
+		GroupID:  groupID,
+	})
+}
+
+/*
+emitGroupMemberEvent emits an event about the member to the group.
+PENDING_JOIN_REQUEST is only sent to the owner and admins of the group,
+every other event is sent to the recipients of memberEventRecipients
+*/
+func (apiConfig *ApiConfig) emitGroupMemberEvent(ctx context.Context, eventName string, groupID, memberID uuid.UUID, role string) error {
+	user, err := apiConfig.DB.GetUserById(ctx, memberID)
+	if err != nil {
+		return err
+	}
+
+	var recipients []uuid.UUID
+	if eventName == eventhandlers.PENDING_JOIN_REQUEST {
+		recipients, err = apiConfig.groupAdminRecipients(ctx, groupID)
+	} else {
+		recipients, err = apiConfig.memberEventRecipients(ctx, groupID)
+	}
+	if err != nil {
+		return err
+	}
+
+	apiConfig.GroupActionsEventEmitterChannel <- eventhandlers.GroupEvent{
+		Name: eventName,
+		Group: eventhandlers.Group{
+			ID:          groupID,
+			UserID:      memberID,
+			Username:    user.Username,
+			Phonenumber: user.Phonenumber,
+			Role:        role,
+		},
+		UserIDs:             recipients,
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/linkpreview"
+)
+
+const (
+	linkPreviewWorkers = 4                // number of link previews generated at the same time
+	linkPreviewTimeout = 10 * time.Second // time in which the preview of a link has to be fetched and stored
+)
+
+/*
+queueLinkPreview hands the message to the link preview worker if its description contains a link.
+A full queue drops the message instead of blocking the request, the message is then sent without a preview
+*/
+func (apiConfig *ApiConfig) queueLinkPreview(message database.Message) {
+	if apiConfig.LinkPreviewQueue == nil || linkpreview.FirstURL(message.Description) == "" {
+		return
+	}
+
+	select {
+	case apiConfig.LinkPreviewQueue <- message:
+	default:
+		log.Printf("[LINK_PREVIEW_WORKER]: queue is full, skipping link preview of message %s", message.ID)
+	}
+}
+
+/*
+LinkPreviewWorker generates the previews of the links in the queued messages. The preview of the first link
+of a message is stored with the message and pushed to the participants of its conversation with the
+MESSAGE_PREVIEW_READY event. Messages deleted before their preview was ready do not get one
+*/
+func (apiConfig *ApiConfig) LinkPreviewWorker(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Printf("[LINK_PREVIEW_WORKER]: started link preview worker")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var workers sync.WaitGroup
+	for range linkPreviewWorkers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case message := <-apiConfig.LinkPreviewQueue:
+					if err := apiConfig.generateLinkPreview(ctx, message); err != nil {
+						log.Printf("[LINK_PREVIEW_WORKER]: error generating link preview of message %s: %v", message.ID, err)
+					}
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	<-stop
+	cancel()
+	workers.Wait()
+	log.Printf("[LINK_PREVIEW_WORKER]: stopped link preview worker")
+}
+
+// generateLinkPreview fetches the preview of the first link of the message, stores it and emits the MESSAGE_PREVIEW_READY event
+func (apiConfig *ApiConfig) generateLinkPreview(ctx context.Context, message database.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, linkPreviewTimeout)
+	defer cancel()
+
+	link := linkpreview.FirstURL(message.Description)
+	preview, err := apiConfig.LinkPreviews.Fetch(ctx, link)
+	if err != nil {
+		return err
+	}
+
+	stored, err := apiConfig.DB.CreateMessageLinkPreview(ctx, database.CreateMessageLinkPreviewParams{
+		MessageID:   message.ID,
+		Url:         preview.URL,
+		Title:       preview.Title,
+		Description: preview.Description,
+		ImageUrl:    preview.Image,
+	})
+	if err != nil || stored == 0 {
+		return err
+	}
+
+	var recipients []uuid.UUID
+	if message.GroupID.Valid {
+		recipients, err = apiConfig.groupRecipients(ctx, message.GroupID.UUID)
+		if err != nil {
+			return err
+		}
+	} else {
+		recipients = []uuid.UUID{message.SenderID, message.RecieverID.UUID}
+	}
+
+	mutedRecipients, err := apiConfig.mutedRecipients(ctx, message.SenderID, conversation{
+		ReceiverID: message.RecieverID.UUID,
+		GroupID:    message.GroupID.UUID,
+	})
+	if err != nil {
+		return err
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:         eventhandlers.MESSAGE_PREVIEW_READY,
+		UserIDs:      recipients,
+		MutedUserIDs: mutedRecipients,
+		Message: eventhandlers.Message{
+			ID:          message.ID,
+			SenderID:    message.SenderID,
+			GroupID:     message.GroupID.UUID,
+			LinkPreview: newLinkPreview(preview),
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
+
+// newLinkPreview converts the fetched preview into the preview sent to the client
+func newLinkPreview(preview linkpreview.Preview) *eventhandlers.LinkPreview {
+	return &eventhandlers.LinkPreview{
+		URL:         preview.URL,
+		Title:       preview.Title,
+		Description: preview.Description,
+		Image:       preview.Image,
+	}
+}
+
+// withLinkPreviews adds the stored link previews to the messages, the previews of deleted messages are not shown
+func (apiConfig *ApiConfig) withLinkPreviews(ctx context.Context, messages []detailedMessage) error {
+	messageIDs := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	previews, err := apiConfig.DB.GetLinkPreviewsByMessageIDs(ctx, messageIDs)
+	if err != nil {
+		return err
+	}
+
+	messagePreviews := make(map[uuid.UUID]database.MessageLinkPreview)
+	for _, preview := range previews {
+		messagePreviews[preview.MessageID] = preview
+	}
+
+	for index := range messages {
+		if preview, ok := messagePreviews[messages[index].ID]; ok && !messages[index].DeletedAt.Valid {
+			messages[index].LinkPreview = &eventhandlers.LinkPreview{
+				URL:         preview.Url,
+				Title:       preview.Title,
+				Description: preview.Description,
+				Image:       preview.ImageUrl,
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// mention of the requesting user sent to the client
+type messageMention struct {
+	ID             uuid.UUID `json:"id"`
+	Description    string    `json:"description"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	GroupID        uuid.UUID `json:"group_id"`
+	GroupName      string    `json:"group_name"`
+	CreatedAt      string    `json:"created_at"`
+}
+
+// isUsernameCharacter reports whether the byte can be part of a username
+func isUsernameCharacter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+/*
+parseMentions returns the ids of the members mentioned in the description with @username.
+Usernames can contain spaces so every @ is matched against the usernames of the members,
+case insensitively, and the longest username ending at a word boundary wins. Usernames are
+not unique so every member with the matched username is mentioned. An @ inside a word, like
+in an email address, is not a mention
+*/
+func parseMentions(description string, members []database.GetGroupMembersRow) []uuid.UUID {
+	mentioned := []uuid.UUID{}
+	seen := make(map[uuid.UUID]bool)
+	for index := 0; index < len(description); index++ {
+		if description[index] != '@' || (index > 0 && isUsernameCharacter(description[index-1])) {
+			continue
+		}
+
+		rest := description[index+1:]
+		longest := 0
+		for _, member := range members {
+			length := len(member.Username)
+			if length > longest && length <= len(rest) && strings.EqualFold(rest[:length], member.Username) &&
+				(length == len(rest) || !isUsernameCharacter(rest[length])) {
+				longest = length
+			}
+		}
+		if longest == 0 {
+			continue
+		}
+
+		for _, member := range members {
+			if len(member.Username) == longest && strings.EqualFold(rest[:longest], member.Username) && !seen[member.ID] {
+				seen[member.ID] = true
+				mentioned = append(mentioned, member.ID)
+			}
+		}
+		index += longest
+	}
+
+	return mentioned
+}
+
+/*
+recordMentions stores the members mentioned in the group message and emits the MENTIONED event to them.
+The event is separate from NEW_MESSAGE so that it reaches the mentioned members even if they muted the group
+*/
+func (apiConfig *ApiConfig) recordMentions(ctx context.Context, message database.Message) error {
+	if !message.GroupID.Valid || !strings.Contains(message.Description, "@") {
+		return nil
+	}
+
+	// the sender is excluded so that it can not mention itself
+	members, err := apiConfig.DB.GetGroupMembers(ctx, database.GetGroupMembersParams{
+		GroupID: message.GroupID.UUID,
+		ID:      message.SenderID,
+	})
+	if err != nil {
+		return err
+	}
+
+	mentioned := parseMentions(message.Description, members)
+	if len(mentioned) == 0 {
+		return nil
+	}
+
+	for _, memberID := range mentioned {
+		if err = apiConfig.DB.CreateMessageMention(ctx, database.CreateMessageMentionParams{
+			MessageID: message.ID,
+			UserID:    memberID,
+			GroupID:   message.GroupID.UUID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	sender, err := apiConfig.DB.GetUserById(ctx, message.SenderID)
+	if err != nil {
+		return err
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventhandlers.MENTIONED,
+		UserIDs: mentioned,
+		Message: eventhandlers.Message{
+			ID:             message.ID,
+			Description:    message.Description,
+			SenderID:       message.SenderID,
+			SenderUsername: sender.Username,
+			GroupID:        message.GroupID.UUID,
+			CreatedAt:      message.CreatedAt.Format(time.RFC1123),
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
+
+/*
+endpoint: /api/v1/message/mentions
+
+returns the messages in which the requesting user was mentioned before the given time, latest first
+and 20 at a time. Mentions in groups which the user left or messages which it deleted are excluded
+*/
+func (apiConfig *ApiConfig) HandleGetMentions(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Before time.Time `json:"before"`
+	}
+
+	type response struct {
+		Mentions    []messageMention `json:"mentions"`
+		AccessToken string           `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/mentions]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if params.Before.IsZero() {
+		params.Before = time.Now()
+	}
+
+	mentions, err := apiConfig.DB.GetMentionsForUser(r.Context(), database.GetMentionsForUserParams{
+		UserID:    userID,
+		CreatedAt: params.Before,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/mentions]: error fetching mentions: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	messageMentions := []messageMention{}
+	for _, mention := range mentions {
+		messageMentions = append(messageMentions, messageMention{
+			ID:             mention.ID,
+			Description:    mention.Description,
+			SenderID:       mention.SenderID,
+			SenderUsername: mention.SenderUsername,
+			GroupID:        mention.GroupID,
+			GroupName:      mention.GroupName,
+			CreatedAt:      mention.CreatedAt.Format(time.RFC1123),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Mentions:    messageMentions,
+		AccessToken: newAccessToken,
+	})
+}
@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"slices"
@@ -13,19 +16,67 @@ import (
 	"github.com/harshvardha/TerTerChat/utility"
 )
 
+// modes of deleting a message
+const (
+	deleteForMe       = "me"
+	deleteForEveryone = "everyone"
+)
+
+// number of messages returned in a page of a conversation, matches the limit of the history queries
+const conversationPageSize = 10
+
+// description shown in place of a message deleted for everyone
+const deletedMessageDescription = "message deleted"
+
+// showTombstones replaces the empty description of the messages deleted for everyone with a tombstone
+func showTombstones(messages []database.Message) []database.Message {
+	for index := range messages {
+		if messages[index].DeletedAt.Valid {
+			messages[index].Description = deletedMessageDescription
+		}
+	}
+
+	return messages
+}
+
+// message sent to the client with the results of its poll and the preview of its link
+type detailedMessage struct {
+	database.Message
+	Poll        *eventhandlers.Poll        `json:"poll,omitempty"`
+	LinkPreview *eventhandlers.LinkPreview `json:"link_preview,omitempty"`
+}
+
+// withDetails adds the polls and the link previews to the messages and shows the tombstones of the deleted ones
+func (apiConfig *ApiConfig) withDetails(ctx context.Context, messages []database.Message, userID uuid.UUID) ([]detailedMessage, error) {
+	detailedMessages, err := apiConfig.withPolls(ctx, showTombstones(messages), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = apiConfig.withLinkPreviews(ctx, detailedMessages); err != nil {
+		return nil, err
+	}
+
+	return detailedMessages, nil
+}
+
 // endpoint: /api/v1/message/create
 func (apiConfig *ApiConfig) HandleCreateNewMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
-		Description string `json:"description"`
-		ReceiverID  string `json:"receiver_id"`
-		GroupID     string `json:"group_id"`
+		Description string          `json:"description"`
+		ReceiverID  string          `json:"receiver_id"`
+		GroupID     string          `json:"group_id"`
+		Kind        string          `json:"kind"`
+		Content     json.RawMessage `json:"content"`
 	}
 
 	type response struct {
-		ID          string `json:"id"`
-		Description string `json:"description"`
-		UpdatedAt   string `json:"updated_at"`
-		AccessToken string `json:"accessToken"`
+		ID          string          `json:"id"`
+		Description string          `json:"description"`
+		Kind        string          `json:"kind"`
+		Content     json.RawMessage `json:"content"`
+		UpdatedAt   string          `json:"updated_at"`
+		AccessToken string          `json:"accessToken"`
 	}
 
 	// extracting message from request body
@@ -45,15 +96,28 @@ func (apiConfig *ApiConfig) HandleCreateNewMessage(w http.ResponseWriter, r *htt
 		return
 	}
 
-	if len(params.Description) == 0 {
-		log.Printf("[/api/v1/message/create]: empty message description")
-		utility.RespondWithError(w, http.StatusNotAcceptable, "empty message description")
+	// messages without a kind are text messages
+	if params.Kind == "" {
+		params.Kind = messageKindText
+	}
+
+	content, err := apiConfig.validateMessageContent(r.Context(), userID, params.Kind, params.Description, params.Content)
+	if errors.Is(err, errInvalidMessageContent) {
+		log.Printf("[/api/v1/message/create]: %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("[/api/v1/message/create]: error validating message content: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// creating new message
 	message := database.CreateMessageParams{}
 	message.Description = params.Description
+	message.Kind = params.Kind
+	message.Content = content
 
 	if len(params.ReceiverID) > 0 {
 		receiverId, err := uuid.Parse(params.ReceiverID)
@@ -79,81 +143,69 @@ func (apiConfig *ApiConfig) HandleCreateNewMessage(w http.ResponseWriter, r *htt
 		}
 	}
 
-	message.SenderID = userID
-	message.Description = params.Description
-	message.Sent = true
-
-	newMessage, err := apiConfig.DB.CreateMessage(r.Context(), message)
-	if err != nil {
-		log.Printf("[/api/v1/message/create]: error creating new message: %v", err)
-		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+	// checking if the requesting user can post in the conversation
+	if err = apiConfig.authorizeMessage(r.Context(), userID, conversation{
+		ReceiverID: message.RecieverID.UUID,
+		GroupID:    message.GroupID.UUID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/create]: requesting user %s is not allowed to post: %v", userID, err)
+		respondWithMessageAuthorizationError(w, err)
 		return
 	}
 
-	// adding new message to cache
-	if len(params.GroupID) > 0 {
-		apiConfig.MessageCache.Set(params.GroupID, newMessage)
-	} else {
-		apiConfig.MessageCache.Set(userID.String()+params.ReceiverID, newMessage)
-	}
-
-	// emitting new message event
-	messageEvent := eventhandlers.MessageEvent{}
-
-	// adding event name
-	messageEvent.Name = eventhandlers.NEW_MESSAGE
-
-	// adding the receivers contact number
-	if newMessage.GroupID.UUID != uuid.Nil {
-		groupMembersPhonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), newMessage.GroupID.UUID)
+	// checking if the message has to go to the message requests inbox of the receiver
+	isMessageRequest := false
+	if message.RecieverID.Valid {
+		isMessageRequest, err = apiConfig.isMessageRequest(r.Context(), userID, message.RecieverID.UUID)
+		if errors.Is(err, errMessageRequestDeclined) {
+			utility.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
 		if err != nil {
-			log.Printf("[/api/v1/message/create]: error fetching group members id: %v", err)
+			log.Printf("[/api/v1/message/create]: error checking message request: %v", err)
 			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
-
-		messageEvent.Phonenumbers = groupMembersPhonenumbers
 	}
 
-	if newMessage.RecieverID.UUID != uuid.Nil {
-		receiverPhonenumber, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), newMessage.RecieverID.UUID)
-		if err != nil {
-			log.Printf("[/api/v1/message/create]: error fetching the receiver phonenumber: %v", err)
-			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
-			return
-		}
+	message.SenderID = userID
+	message.Description = params.Description
+	message.Sent = true
 
-		messageEvent.Phonenumbers = []string{receiverPhonenumber}
+	// messages of a conversation with a message timer disappear
+	if err = apiConfig.applyMessageTimer(r.Context(), &message); err != nil {
+		log.Printf("[/api/v1/message/create]: error applying message timer: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	// adding the message
-	senderUsername, err := apiConfig.DB.GetUserById(r.Context(), newMessage.SenderID)
+	newMessage, err := apiConfig.DB.CreateMessage(r.Context(), message)
 	if err != nil {
-		log.Printf("[/api/v1/message/create]: error fetching sender username: %v", err)
+		log.Printf("[/api/v1/message/create]: error creating new message: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	messageEvent.Message = eventhandlers.Message{
-		ID:             newMessage.ID,
-		Description:    newMessage.Description,
-		SenderID:       newMessage.SenderID,
-		SenderUsername: senderUsername.Username,
-		GroupID:        newMessage.GroupID.UUID,
-		CreatedAt:      newMessage.CreatedAt.Format(time.RFC1123),
-	}
 
-	// providing event handler the instance of notification service
-	messageEvent.NotificationService = apiConfig.NotificationService
+	// caching the message and pushing it to the receivers
+	if err = apiConfig.publishMessage(r.Context(), newMessage, isMessageRequest); err != nil {
+		log.Printf("[/api/v1/message/create]: error publishing new message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// providing event emitting time instance
-	messageEvent.EmittedAt = time.Now()
+	// notifying the group members mentioned in the message
+	if err = apiConfig.recordMentions(r.Context(), newMessage); err != nil {
+		log.Printf("[/api/v1/message/create]: error recording mentions: %v", err)
+	}
 
-	// passing the event to event handler
-	apiConfig.MessageEventEmitterChannel <- messageEvent
+	// the preview of a link in the message is pushed when it is ready
+	apiConfig.queueLinkPreview(newMessage)
 
 	utility.RespondWithJson(w, http.StatusCreated, response{
 		ID:          newMessage.ID.String(),
 		Description: newMessage.Description,
+		Kind:        newMessage.Kind,
+		Content:     newMessage.Content,
 		UpdatedAt:   newMessage.UpdatedAt.Format(time.RFC1123),
 		AccessToken: newAccessToken,
 	})
@@ -164,13 +216,13 @@ func (apiConfig *ApiConfig) HandleUpdateMessage(w http.ResponseWriter, r *http.R
 	type request struct {
 		ID          uuid.UUID `json:"id"`
 		Description string    `json:"description"`
-		ReceiverID  uuid.UUID `json:"receiver_id"`
-		GroupID     uuid.UUID `json:"group_id"`
 	}
 
 	type response struct {
 		ID          string `json:"id"`
 		Description string `json:"description"`
+		Edited      bool   `json:"edited"`
+		EditCount   int32  `json:"edit_count"`
 		UpdatedAt   string `json:"updated_at"`
 		AccessToken string `json:"access_token"`
 	}
@@ -192,74 +244,110 @@ func (apiConfig *ApiConfig) HandleUpdateMessage(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// updating message
-	message := database.UpdateMessageParams{
-		ID:          params.ID,
-		Description: params.Description,
-		SenderID:    userID,
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/update]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	// only the sender can edit the message and only within the edit window,
+	// messages deleted for everyone or whose timer has run out are gone
+	current, err := qtx.GetMessageForEdit(r.Context(), database.GetMessageForEditParams{
+		EditWindow: int32(apiConfig.MessageEditWindow.Seconds()),
+		ID:         params.ID,
+		SenderID:   userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[/api/v1/message/update]: error fetching message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !isEditableKind(current.Kind) {
+		utility.RespondWithError(w, http.StatusForbidden, current.Kind+" messages can not be edited")
+		return
+	}
+
+	if !current.Editable {
+		utility.RespondWithError(w, http.StatusForbidden, "message can no longer be edited")
+		return
 	}
 
-	if params.GroupID != uuid.Nil {
-		message.GroupID.UUID = params.GroupID
-		message.GroupID.Valid = true
+	if current.Description == params.Description {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "message description is unchanged")
+		return
+	}
+
+	// keeping the previous version in the edit history
+	if err = qtx.CreateMessageEdit(r.Context(), database.CreateMessageEditParams{
+		MessageID:   params.ID,
+		Description: current.Description,
+	}); err != nil {
+		log.Printf("[/api/v1/message/update]: error saving edit history: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-	updatedMessage, err := apiConfig.DB.UpdateMessage(r.Context(), message)
+	// updating message
+	updatedMessage, err := qtx.UpdateMessage(r.Context(), database.UpdateMessageParams{
+		ID:          params.ID,
+		Description: params.Description,
+		SenderID:    userID,
+	})
 	if err != nil {
 		log.Printf("[/api/v1/message/update]: error updating message: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// updating message cache
-	if params.GroupID != uuid.Nil {
-		apiConfig.MessageCache.Update(
-			params.GroupID.String(),
-			params.ID,
-			updatedMessage.Description,
-			updatedMessage.Recieved,
-			updatedMessage.Read,
-			updatedMessage.IsReceiverAllowedToSee,
-			updatedMessage.UpdatedAt,
-		)
-	} else {
-		apiConfig.MessageCache.Update(
-			userID.String()+params.ReceiverID.String(),
-			params.ID,
-			updatedMessage.Description,
-			updatedMessage.Recieved,
-			updatedMessage.Read,
-			updatedMessage.IsReceiverAllowedToSee,
-			updatedMessage.UpdatedAt,
-		)
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/update]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
+	// updating message cache
+	apiConfig.MessageCache.Update(
+		conversationCacheKey(userID, conversation{
+			ReceiverID: updatedMessage.RecieverID.UUID,
+			GroupID:    updatedMessage.GroupID.UUID,
+		}),
+		params.ID,
+		func(message *database.Message) {
+			message.Description = updatedMessage.Description
+			message.EditCount = updatedMessage.EditCount
+			message.UpdatedAt = updatedMessage.UpdatedAt
+		},
+	)
+
 	// creating message event
 	messageEvent := eventhandlers.MessageEvent{}
 	messageEvent.Name = eventhandlers.EDIT_MESSAGE
 
-	// fetching contact numbers of group members
-	if params.GroupID != uuid.Nil {
-		receiversPhonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
-		if err != nil {
-			log.Printf("[/api/v1/message/update]: error fetching phonenumbers of group members: %v", err)
-			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		messageEvent.Phonenumbers = receiversPhonenumbers
+	// adding the receivers of the message
+	messageConversation := conversation{
+		ReceiverID: updatedMessage.RecieverID.UUID,
+		GroupID:    updatedMessage.GroupID.UUID,
 	}
+	recipients, err := apiConfig.conversationRecipients(r.Context(), messageConversation)
+	if err != nil {
+		log.Printf("[/api/v1/message/update]: error fetching message receivers: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	messageEvent.UserIDs = recipients
 
-	// fetching contact number of receiver
-	if params.ReceiverID != uuid.Nil {
-		receiverPhonenumber, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), params.ReceiverID)
-		if err != nil {
-			log.Printf("[/api/v1/message/update]: error fetching receiver phonenumber: %v", err)
-			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
-			return
-		}
-
-		messageEvent.Phonenumbers = []string{receiverPhonenumber}
+	// receivers who muted the conversation get the edit silently
+	messageEvent.MutedUserIDs, err = apiConfig.mutedRecipients(r.Context(), updatedMessage.SenderID, messageConversation)
+	if err != nil {
+		log.Printf("[/api/v1/message/update]: error fetching muted receivers: %v", err)
 	}
 
 	// adding message to messageEvent
@@ -275,6 +363,7 @@ func (apiConfig *ApiConfig) HandleUpdateMessage(w http.ResponseWriter, r *http.R
 		SenderID:       updatedMessage.SenderID,
 		SenderUsername: sender.Username,
 		GroupID:        updatedMessage.GroupID.UUID,
+		EditCount:      updatedMessage.EditCount,
 		UpdatedAt:      updatedMessage.UpdatedAt.Format(time.RFC1123),
 	}
 
@@ -290,16 +379,25 @@ func (apiConfig *ApiConfig) HandleUpdateMessage(w http.ResponseWriter, r *http.R
 	utility.RespondWithJson(w, http.StatusOK, response{
 		ID:          params.ID.String(),
 		Description: updatedMessage.Description,
+		Edited:      updatedMessage.EditCount > 0,
+		EditCount:   updatedMessage.EditCount,
 		UpdatedAt:   updatedMessage.UpdatedAt.Format(time.RFC1123),
 		AccessToken: newAccessToken,
 	})
 }
 
-// endpoint: /api/v1/message/delete
+/*
+endpoint: /api/v1/message/delete
+
+mode is me or everyone and defaults to me. Deleting for me hides the message only from the
+requesting user. Deleting for everyone replaces the message with a tombstone for every participant,
+the sender can do it within DELETE_FOR_EVERYONE_WINDOW of sending and in a group the owner,
+admins and moderators can do it to any message at any time
+*/
 func (apiConfig *ApiConfig) HandleDeleteMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
-		ID      uuid.UUID `json:"id"`
-		GroupID uuid.UUID `json:"group_id"`
+		ID   uuid.UUID `json:"id"`
+		Mode string    `json:"mode"`
 	}
 
 	// extracting request body
@@ -319,155 +417,203 @@ func (apiConfig *ApiConfig) HandleDeleteMessage(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// if requesting user is sender of the message then both is_sender_allowed_to_see
-	// and is_receiver_allowed_to_see will be marked as false
-	// and if requesting user is receiver of the message then only is_receiver_allowed_to_see
-	// will be marked as false
-	message, err := apiConfig.DB.GetMessageSenderReceiverAndGroupID(r.Context(), params.ID)
+	if params.Mode == "" {
+		params.Mode = deleteForMe
+	}
+	if params.Mode != deleteForMe && params.Mode != deleteForEveryone {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "mode must be me or everyone")
+		return
+	}
+
+	message, err := apiConfig.DB.GetMessageForDelete(r.Context(), database.GetMessageForDeleteParams{
+		DeleteWindow: int32(apiConfig.DeleteForEveryoneWindow.Seconds()),
+		ID:           params.ID,
+	})
 	if err != nil {
 		log.Printf("[/api/v1/message/delete]: error fetching the message: %v", err)
-		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
 		return
 	}
 
-	// if params.GroupID == nil then the message is not a group message, it belongs to one to one conversation
-	// between two users.
-	if params.GroupID == uuid.Nil {
-		// if message.SenderID == userID then both sender and receiver are not allowed to see the message
-		// if message.SenderID == message.Receiver then only the receiver will not be allowed to see the message
-		if message.SenderID == userID {
-			if err = apiConfig.DB.MarkIsSenderAllowedToSeeFalse(r.Context(), database.MarkIsSenderAllowedToSeeFalseParams{
-				SenderID:   userID,
-				RecieverID: message.RecieverID,
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking sender to see message as false: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			if err = apiConfig.DB.MarkIsReceiverAllowedToSeeFalse(r.Context(), database.MarkIsReceiverAllowedToSeeFalseParams{
-				SenderID:   userID,
-				RecieverID: message.RecieverID,
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking receiver to see message as false: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-		} else if message.RecieverID.UUID == userID {
-			if err = apiConfig.DB.MarkIsReceiverAllowedToSeeFalse(r.Context(), database.MarkIsReceiverAllowedToSeeFalseParams{
-				SenderID: message.SenderID,
-				RecieverID: uuid.NullUUID{
-					UUID:  userID,
-					Valid: true,
-				},
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking receiver to see message as false: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
+	// checking if the requesting user is a participant of the conversation
+	role := ""
+	if message.GroupID.Valid {
+		role, err = apiConfig.authorizeGroupAction(r.Context(), message.GroupID.UUID, userID, permissionViewGroup)
+		if err != nil {
+			log.Printf("[/api/v1/message/delete]: requesting user %s is not allowed: %v", userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
 		}
-	} else if message.GroupID.UUID == params.GroupID {
-		// if the userID == message.SenderID then mark isAllowedToSee = false for all the receivers in the group
-		// if userID != message.SenderID then mark isAllowedToSee = false for that specific receiver
+	} else if userID != message.SenderID && userID != message.RecieverID.UUID {
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	if params.Mode == deleteForMe {
+		// hiding the message only from the requesting user
 		if message.SenderID == userID {
-			if err = apiConfig.DB.MarkIsSenderAllowedToSeeFalse(r.Context(), database.MarkIsSenderAllowedToSeeFalseParams{
+			_, err = apiConfig.DB.HideMessageForSender(r.Context(), database.HideMessageForSenderParams{
+				ID:       params.ID,
 				SenderID: userID,
-				RecieverID: uuid.NullUUID{
-					UUID:  uuid.Nil,
-					Valid: false,
-				},
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking sender to see group message as false: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-
-			// marking isAllowedToSee = false for all the group members who are receivers of this message
-			if err = apiConfig.DB.MarkIsAllowedToSeeAsFalseForGroupMemeberReceivers(r.Context(), database.MarkIsAllowedToSeeAsFalseForGroupMemeberReceiversParams{
+			})
+		} else if message.GroupID.Valid {
+			err = apiConfig.DB.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeber(r.Context(), database.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeberParams{
 				MessageID: params.ID,
-				GroupID:   params.GroupID,
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking allowed to see as false for group members who are recivers: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-		} else {
-			// requesting user is the receiver of this group message
-			// so only marking isAllowedToSee = false for this group member
-			if err = apiConfig.DB.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeber(r.Context(), database.MarkIsAllowedToSeeAsFalseForSpecificGroupMemeberParams{
-				MessageID: params.ID,
-				GroupID:   params.GroupID,
+				GroupID:   message.GroupID.UUID,
 				MemberID:  userID,
-			}); err != nil {
-				log.Printf("[/api/v1/message/delete]: error marking allowed to see as false for requesting group member receiver: %v", err)
-				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
+			})
+		} else {
+			_, err = apiConfig.DB.HideMessageForReceiver(r.Context(), database.HideMessageForReceiverParams{
+				ID:         params.ID,
+				RecieverID: message.RecieverID,
+			})
 		}
-	}
-
-	// removing message from cache
-	if params.GroupID != uuid.Nil {
-		if message.SenderID == userID {
-			apiConfig.MessageCache.RemoveMessage(params.GroupID.String(), params.ID)
+		if err != nil {
+			log.Printf("[/api/v1/message/delete]: error hiding message for requesting user: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
-	} else if message.SenderID == userID {
-		apiConfig.MessageCache.RemoveMessage(userID.String()+message.RecieverID.UUID.String(), params.ID)
-	} else if message.RecieverID.UUID == userID {
-		apiConfig.MessageCache.Update(message.SenderID.String()+userID.String(), params.ID, "", true, true, false, time.Now())
-	}
 
-	// creating delete_message event
-	messageEvent := eventhandlers.MessageEvent{}
-	messageEvent.Name = eventhandlers.DELETE_MESSAGE
+		// the cached copy is shared by the participants so only the visibility flag of the requesting user changes,
+		// hiding a group message for a member who is not its sender is tracked outside the message
+		apiConfig.MessageCache.Update(
+			conversationCacheKey(message.SenderID, conversation{
+				ReceiverID: message.RecieverID.UUID,
+				GroupID:    message.GroupID.UUID,
+			}),
+			params.ID,
+			func(cached *database.Message) {
+				if message.SenderID == userID {
+					cached.IsSenderAllowedToSee = false
+				} else if !message.GroupID.Valid {
+					cached.IsReceiverAllowedToSee = false
+				}
+			},
+		)
 
-	// fetching contact number of group members
-	if params.GroupID != uuid.Nil {
-		phonenumbers, err := apiConfig.DB.GetGroupMembersPhonenumbers(r.Context(), params.GroupID)
-		if err != nil {
-			log.Printf("[/api/v1/message/delete]: error fetching group members contacts: %v", err)
-			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
-			return
+		// letting the other sessions of the requesting user drop the message
+		apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+			Name:    eventhandlers.DELETE_MESSAGE,
+			UserIDs: []uuid.UUID{userID},
+			Message: eventhandlers.Message{
+				ID:       params.ID,
+				SenderID: message.SenderID,
+				GroupID:  message.GroupID.UUID,
+			},
+			NotificationService: apiConfig.NotificationService,
+			EmittedAt:           time.Now(),
 		}
 
-		messageEvent.Phonenumbers = phonenumbers
+		utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+			AccessToken: newAccessToken,
+		})
+		return
 	}
 
-	// fetching contact number of receiver
-	if message.SenderID == userID {
-		phonenumber, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), message.RecieverID.UUID)
-		if err != nil {
-			log.Printf("[/api/v1/message/delete]: error fetching receiver contact: %v", err)
-			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+	if message.DeletedAt.Valid {
+		utility.RespondWithError(w, http.StatusConflict, "message is already deleted")
+		return
+	}
+
+	// notices about the group are part of its history
+	if message.Kind == messageKindSystem {
+		utility.RespondWithError(w, http.StatusForbidden, "system messages can not be deleted for everyone")
+		return
+	}
+
+	// moderators of a group can delete any message at any time, the sender only within the delete window
+	canModerate := message.GroupID.Valid && slices.Contains(groupPermissions[permissionDeleteOthersMessages], role)
+	if !canModerate {
+		if message.SenderID != userID {
+			utility.RespondWithError(w, http.StatusForbidden, "only the sender can delete a message for everyone")
 			return
 		}
-
-		messageEvent.Phonenumbers = []string{phonenumber}
-	} else if message.RecieverID.UUID == userID {
-		phonenumber, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), userID)
-		if err != nil {
-			log.Printf("[/api/v1/message/delete]: error fetching receiver contact: %v", err)
-			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		if !message.DeletableForEveryone {
+			utility.RespondWithError(w, http.StatusForbidden, "message can no longer be deleted for everyone")
 			return
 		}
+	}
 
-		messageEvent.Phonenumbers = []string{phonenumber}
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/delete]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
 
-	// adding message to messageEvent
-	messageEvent.Message = eventhandlers.Message{
-		ID:       params.ID,
-		SenderID: message.SenderID,
-		GroupID:  params.GroupID,
+	// the tombstone keeps the place of the message in the history, its content and edit history are dropped
+	deleted, err := qtx.DeleteMessageForEveryone(r.Context(), params.ID)
+	if err != nil {
+		log.Printf("[/api/v1/message/delete]: error deleting message for everyone: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if deleted == 0 {
+		utility.RespondWithError(w, http.StatusConflict, "message is already deleted")
+		return
 	}
 
-	// adding notification service
-	messageEvent.NotificationService = apiConfig.NotificationService
+	if err = qtx.DeleteMessageEdits(r.Context(), params.ID); err != nil {
+		log.Printf("[/api/v1/message/delete]: error deleting edit history: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// adding event emitting time
-	messageEvent.EmittedAt = time.Now()
+	// a deleted message can not stay pinned
+	if _, err = qtx.UnpinMessage(r.Context(), params.ID); err != nil {
+		log.Printf("[/api/v1/message/delete]: error unpinning deleted message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// emitting event
-	apiConfig.MessageEventEmitterChannel <- messageEvent
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/delete]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// replacing the cached message with the tombstone and telling every participant to do the same
+	deletedAt := time.Now()
+	apiConfig.MessageCache.Update(
+		conversationCacheKey(message.SenderID, conversation{
+			ReceiverID: message.RecieverID.UUID,
+			GroupID:    message.GroupID.UUID,
+		}),
+		params.ID,
+		func(cached *database.Message) {
+			cached.Description = ""
+			cached.Content = json.RawMessage(emptyMessageContent)
+			cached.DeletedAt = sql.NullTime{
+				Time:  deletedAt,
+				Valid: true,
+			}
+		},
+	)
+
+	var recipients []uuid.UUID
+	if message.GroupID.Valid {
+		recipients, err = apiConfig.groupRecipients(r.Context(), message.GroupID.UUID)
+		if err != nil {
+			log.Printf("[/api/v1/message/delete]: error fetching group members: %v", err)
+		}
+	} else {
+		recipients = []uuid.UUID{message.SenderID, message.RecieverID.UUID}
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventhandlers.DELETE_MESSAGE,
+		UserIDs: recipients,
+		Message: eventhandlers.Message{
+			ID:          params.ID,
+			SenderID:    message.SenderID,
+			GroupID:     message.GroupID.UUID,
+			ForEveryone: true,
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
 
 	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
 		AccessToken: newAccessToken,
@@ -492,18 +638,79 @@ func (apiConfig *ApiConfig) HandleGetAllConversations(w http.ResponseWriter, r *
 		return
 	}
 
+	// fetching the mute, archive and pin settings of the user for its conversations
+	settings, err := apiConfig.DB.GetConversationSettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/conversations]: error fetching conversation settings for user %s: %v", userID.String(), err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	userSettings := make(map[uuid.UUID]database.GetConversationSettingsRow)
+	groupSettings := make(map[uuid.UUID]database.GetConversationSettingsRow)
+	for _, setting := range settings {
+		if setting.GroupID.Valid {
+			groupSettings[setting.GroupID.UUID] = setting
+		} else {
+			userSettings[setting.OtherUserID.UUID] = setting
+		}
+	}
+
+	type oneToOneConversation struct {
+		database.GetAllOneToOneConversationsRow
+		conversationSettings
+	}
+
+	type groupConversation struct {
+		database.GetAllGroupConversationsRow
+		conversationSettings
+	}
+
 	// creating response data
 	type response struct {
-		OneToOneConversations []database.GetAllOneToOneConversationsRow `json:"one_to_one_conversations"`
-		GroupConversations    []database.GetAllGroupConversationsRow    `json:"group_conversations"`
-		AccessToken           string                                    `json:"access_token"`
+		OneToOneConversations []oneToOneConversation `json:"one_to_one_conversations"`
+		GroupConversations    []groupConversation    `json:"group_conversations"`
+		AccessToken           string                 `json:"access_token"`
 	}
 
-	utility.RespondWithJson(w, http.StatusOK, response{
-		OneToOneConversations: oneToOneConversations,
-		GroupConversations:    groupConversations,
-		AccessToken:           newAccessToken,
+	// pinned conversations come first, most recently pinned on top
+	pinnedFirst := func(a, b database.GetConversationSettingsRow) int {
+		if a.PinnedAt.Valid != b.PinnedAt.Valid {
+			if a.PinnedAt.Valid {
+				return -1
+			}
+			return 1
+		}
+
+		return b.PinnedAt.Time.Compare(a.PinnedAt.Time)
+	}
+
+	slices.SortStableFunc(oneToOneConversations, func(a, b database.GetAllOneToOneConversationsRow) int {
+		return pinnedFirst(userSettings[a.RecieverID.UUID], userSettings[b.RecieverID.UUID])
+	})
+	slices.SortStableFunc(groupConversations, func(a, b database.GetAllGroupConversationsRow) int {
+		return pinnedFirst(groupSettings[a.GroupID.UUID], groupSettings[b.GroupID.UUID])
 	})
+
+	conversations := response{
+		OneToOneConversations: []oneToOneConversation{},
+		GroupConversations:    []groupConversation{},
+		AccessToken:           newAccessToken,
+	}
+	for _, oneToOne := range oneToOneConversations {
+		conversations.OneToOneConversations = append(conversations.OneToOneConversations, oneToOneConversation{
+			GetAllOneToOneConversationsRow: oneToOne,
+			conversationSettings:           newConversationSettings(userSettings[oneToOne.RecieverID.UUID]),
+		})
+	}
+	for _, group := range groupConversations {
+		conversations.GroupConversations = append(conversations.GroupConversations, groupConversation{
+			GetAllGroupConversationsRow: group,
+			conversationSettings:        newConversationSettings(groupSettings[group.GroupID.UUID]),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, conversations)
 }
 
 // endpoint: /api/v1/message/conversation/delete
@@ -560,8 +767,8 @@ func (apiConfig *ApiConfig) HandleGetConversation(w http.ResponseWriter, r *http
 	}
 
 	type response struct {
-		Messages    []database.Message `json:"messages"`
-		AccessToken string             `json:"access_token"`
+		Messages    []detailedMessage `json:"messages"`
+		AccessToken string            `json:"access_token"`
 	}
 
 	// extracting request body
@@ -587,69 +794,52 @@ func (apiConfig *ApiConfig) HandleGetConversation(w http.ResponseWriter, r *http
 		return
 	}
 
-	// first checking if the messages are present in cache
-	// if messages are not present in cache then hitting database
-	// fetching group messages with limit 10 sorted in ascending order by created_at
-	var messages []database.Message
-	messages = apiConfig.MessageCache.Get(userID.String()+params.ReceiverID.UUID.String(), params.Before)
-	if messages != nil {
-		// if requesting user with userID is sender of message and isSenderAllowedToSee = false
-		// or if requesting user with userID is receiver of message and isReceiverAllowedToSee = false
-		// then message will be excluded from response body
-		for index, value := range messages {
-			if (value.SenderID == userID && !value.IsSenderAllowedToSee) ||
-				(value.RecieverID.UUID == userID && !value.IsReceiverAllowedToSee) {
-				messages = slices.Delete(messages, index, index+1)
-			}
-		}
-
-		utility.RespondWithJson(w, http.StatusOK, response{
-			Messages:    messages,
-			AccessToken: newAccessToken,
+	// first checking if the page of messages is present in cache
+	// if the cache does not cover the whole page then hitting database
+	// fetching the latest 10 messages before the given time sorted in ascending order by created_at
+	cacheKey := conversationCacheKey(userID, conversation{
+		ReceiverID: params.ReceiverID.UUID,
+	})
+	messages, ok := apiConfig.MessageCache.Get(cacheKey, params.Before, conversationPageSize)
+	if !ok {
+		messages, err = apiConfig.DB.GetAllMessages(r.Context(), database.GetAllMessagesParams{
+			UserID:      userID,
+			OtherUserID: params.ReceiverID.UUID,
+			Before:      params.Before,
 		})
+		if err != nil {
+			log.Printf("[/api/v1/message/conversation]: error fetching messages: %v", err)
+			utility.RespondWithError(w, http.StatusNotFound, "no conversations found")
+			return
+		}
+		apiConfig.MessageCache.Fill(cacheKey, messages, params.Before, conversationPageSize)
 	}
 
-	// fetching messages where senderID = userID and receiverID = ReceiverID
-	// if the IsSenderAllowedToSee = false then message will be excluded from final response body data
-	messages, err = apiConfig.DB.GetAllMessages(r.Context(), database.GetAllMessagesParams{
-		SenderID:   userID,
-		RecieverID: params.ReceiverID,
-		CreatedAt:  params.Before,
+	// if requesting user with userID is sender of message and isSenderAllowedToSee = false
+	// or if requesting user with userID is receiver of message and isReceiverAllowedToSee = false
+	// then message will be excluded from response body
+	messages = slices.DeleteFunc(messages, func(message database.Message) bool {
+		return (message.SenderID == userID && !message.IsSenderAllowedToSee) ||
+			(message.RecieverID.UUID == userID && !message.IsReceiverAllowedToSee)
 	})
+
+	// hiding read status if receiver does not share read receipts
+	messages, err = apiConfig.hideReadReceipts(r.Context(), messages, userID, params.ReceiverID.UUID)
 	if err != nil {
-		log.Printf("[/api/v1/message/conversation]: error fetching messages: %v", err)
-		utility.RespondWithError(w, http.StatusNotFound, "no conversations found")
+		log.Printf("[/api/v1/message/conversation]: error checking read receipts privacy: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	for index, value := range messages {
-		if !value.IsSenderAllowedToSee {
-			messages = slices.Delete(messages, index, index+1)
-		}
-	}
 
-	// fetching messages where senderID = ReceiverID and receiverID = userID
-	// if the IsReceiverAllowedToSee = false then message will be excluded from final response body data
-	messages_2, err := apiConfig.DB.GetAllMessages(r.Context(), database.GetAllMessagesParams{
-		SenderID: params.ReceiverID.UUID,
-		RecieverID: uuid.NullUUID{
-			UUID:  userID,
-			Valid: true,
-		},
-		CreatedAt: params.Before,
-	})
+	detailedMessages, err := apiConfig.withDetails(r.Context(), messages, userID)
 	if err != nil {
-		log.Printf("[/api/v1/message/conversation]: error fetching messages: %v", err)
-		utility.RespondWithError(w, http.StatusNotFound, "no conversations found")
+		log.Printf("[/api/v1/message/conversation]: error fetching message details: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	for index, value := range messages_2 {
-		if !value.IsReceiverAllowedToSee {
-			messages_2 = slices.Delete(messages_2, index, index+1)
-		}
-	}
 
 	utility.RespondWithJson(w, http.StatusOK, response{
-		Messages:    append(messages, messages_2...),
+		Messages:    detailedMessages,
 		AccessToken: newAccessToken,
 	})
 }
@@ -662,8 +852,8 @@ func (apiConfig *ApiConfig) HandleGetAllGroupMessages(w http.ResponseWriter, r *
 	}
 
 	type response struct {
-		Messages    []database.Message `json:"messages"`
-		AccessToken string             `json:"access_token"`
+		Messages    []detailedMessage `json:"messages"`
+		AccessToken string            `json:"access_token"`
 	}
 
 	// extracting request body
@@ -689,63 +879,73 @@ func (apiConfig *ApiConfig) HandleGetAllGroupMessages(w http.ResponseWriter, r *
 		return
 	}
 
-	// first checking if the messages are present in cache
-	// if messages are not present in cache then hitting database
-	// fetching group messages with limit 10 sorted in ascending order by created_at
-	var messages []database.Message
-	messages = apiConfig.MessageCache.Get(params.GroupID.String(), params.Before)
-	if messages != nil {
-		// for the group messages where the requesting user is receiver
-		// we have to check if the user isAllowedToSee the message
-		// if not then exclude that message
-		for index, value := range messages {
-			if value.SenderID != userID {
-				if isAllowedToSee, err := apiConfig.DB.IsGroupMemberAllowedToSeeMessage(r.Context(), database.IsGroupMemberAllowedToSeeMessageParams{
-					MessageID: value.ID,
-					GroupID:   params.GroupID,
-					MemberID:  userID,
-				}); err == nil && !isAllowedToSee {
-					messages = slices.Delete(messages, index, index+1)
-				}
-			}
+	// only the members of the group can read its messages
+	if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup); err != nil {
+		log.Printf("[/api/v1/message/group]: requesting user %s is not allowed: %v", userID, err)
+		respondWithGroupAuthorizationError(w, err)
+		return
+	}
+
+	// first checking if the page of messages is present in cache
+	// if the cache does not cover the whole page then hitting database
+	// fetching the latest 10 group messages before the given time sorted in ascending order by created_at
+	messages, ok := apiConfig.MessageCache.Get(params.GroupID.String(), params.Before, conversationPageSize)
+	if !ok {
+		messages, err = apiConfig.DB.GetAllGroupMessages(r.Context(), database.GetAllGroupMessagesParams{
+			GroupID: uuid.NullUUID{
+				UUID:  params.GroupID,
+				Valid: true,
+			},
+			CreatedAt: params.Before,
+		})
+		if err != nil {
+			log.Printf("[/api/v1/message/group]: error fetching messages for group %s: %v", params.GroupID, err)
+			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
+		apiConfig.MessageCache.Fill(params.GroupID.String(), messages, params.Before, conversationPageSize)
+	}
 
-		utility.RespondWithJson(w, http.StatusOK, response{
-			Messages:    messages,
-			AccessToken: newAccessToken,
+	// for the group messages where the requesting user is receiver
+	// we have to check if the user isAllowedToSee the message
+	// if not then exclude that message, the whole page is checked in one query
+	receivedMessageIDs := []uuid.UUID{}
+	for _, message := range messages {
+		if message.SenderID != userID {
+			receivedMessageIDs = append(receivedMessageIDs, message.ID)
+		}
+	}
+
+	hiddenMessageIDs := []uuid.UUID{}
+	if len(receivedMessageIDs) > 0 {
+		hiddenMessageIDs, err = apiConfig.DB.GetMessagesHiddenFromGroupMember(r.Context(), database.GetMessagesHiddenFromGroupMemberParams{
+			GroupID:    params.GroupID,
+			MemberID:   userID,
+			MessageIds: receivedMessageIDs,
 		})
+		if err != nil {
+			log.Printf("[/api/v1/message/group]: error fetching hidden messages for group %s: %v", params.GroupID, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	}
 
-	messages, err = apiConfig.DB.GetAllGroupMessages(r.Context(), database.GetAllGroupMessagesParams{
-		GroupID: uuid.NullUUID{
-			UUID:  params.GroupID,
-			Valid: true,
-		},
-		CreatedAt: params.Before,
+	messages = slices.DeleteFunc(messages, func(message database.Message) bool {
+		if message.SenderID == userID {
+			return !message.IsSenderAllowedToSee
+		}
+		return slices.Contains(hiddenMessageIDs, message.ID)
 	})
+
+	groupMessages, err := apiConfig.withDetails(r.Context(), messages, userID)
 	if err != nil {
-		log.Printf("[/api/v1/message/group]: error fetching messages for group %s: %v", params.GroupID, err)
-		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		log.Printf("[/api/v1/message/group]: error fetching message details for group %s: %v", params.GroupID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// for the group messages where the requesting user is receiver
-	// we have to check if the user isAllowedToSee the message
-	// if not then exclude that message
-	for index, value := range messages {
-		if value.SenderID != userID {
-			if isAllowedToSee, err := apiConfig.DB.IsGroupMemberAllowedToSeeMessage(r.Context(), database.IsGroupMemberAllowedToSeeMessageParams{
-				MessageID: value.ID,
-				GroupID:   params.GroupID,
-				MemberID:  userID,
-			}); err == nil && !isAllowedToSee {
-				messages = slices.Delete(messages, index, index+1)
-			}
-		}
-	}
-
 	utility.RespondWithJson(w, http.StatusOK, response{
-		Messages:    messages,
+		Messages:    groupMessages,
 		AccessToken: newAccessToken,
 	})
 }
@@ -776,20 +976,19 @@ func (apiConfig *ApiConfig) HandleMarkMessageReceived(w http.ResponseWriter, r *
 	}
 
 	// updating cache
-	apiConfig.MessageCache.Update(params.SenderID.String()+userID.String(), params.MessageID, "", true, false, true, updatedAt)
+	apiConfig.MessageCache.Update(conversationCacheKey(userID, conversation{
+		ReceiverID: params.SenderID,
+	}), params.MessageID, func(message *database.Message) {
+		message.Recieved = true
+		message.UpdatedAt = updatedAt
+	})
 
 	// creating message event
 	messageEvent := eventhandlers.MessageEvent{}
 	messageEvent.Name = eventhandlers.MESSAGE_RECEIVED
 
-	// fetching sender phonenumber
-	senderContact, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), params.SenderID)
-	if err != nil {
-		log.Printf("[/api/v1/message/received]: error fetching sender phonenumber: %v", err)
-		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	messageEvent.Phonenumbers = []string{senderContact}
+	// event will be sent to the sender of the message
+	messageEvent.UserIDs = []uuid.UUID{params.SenderID}
 
 	// adding message to messageEvent
 	messageEvent.Message = eventhandlers.Message{
@@ -815,7 +1014,6 @@ func (apiConfig *ApiConfig) HandleMarkMessageReceived(w http.ResponseWriter, r *
 func (apiConfig *ApiConfig) HandleMarkMessageRead(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	type request struct {
 		MessageID uuid.UUID `json:"id"`
-		SenderID  uuid.UUID `json:"sender_id"`
 	}
 
 	// extracting request body
@@ -828,29 +1026,59 @@ func (apiConfig *ApiConfig) HandleMarkMessageRead(w http.ResponseWriter, r *http
 		return
 	}
 
+	// only the receiver can mark the message read, it also starts the timer of a disappearing message
+	message, err := apiConfig.DB.GetMessageSenderReceiverAndGroupID(r.Context(), params.MessageID)
+	if err != nil || message.RecieverID.UUID != userID {
+		log.Printf("[/api/v1/message/mark/read]: message %s not found for user %s: %v", params.MessageID, userID, err)
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
 	// marking message as read
-	updatedAt, err := apiConfig.DB.MarkMessageRead(r.Context(), params.MessageID)
+	markedRead, err := apiConfig.DB.MarkMessageRead(r.Context(), params.MessageID)
 	if err != nil {
 		log.Printf("[/api/v1/message/mark/read]: error marking message as read: %v", err)
 		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// updating cache
-	apiConfig.MessageCache.Update(params.SenderID.String()+userID.String(), params.MessageID, "", true, true, true, updatedAt)
+	// updating cache, reading a disappearing message starts its timer
+	apiConfig.MessageCache.Update(conversationCacheKey(userID, conversation{
+		ReceiverID: message.SenderID,
+	}), params.MessageID, func(message *database.Message) {
+		message.Recieved = true
+		message.Read = true
+		message.UpdatedAt = markedRead.UpdatedAt
+		message.ExpiresAt = markedRead.ExpiresAt
+	})
 
-	// creating MESSAGE_RECEIVED event
-	messageEvent := eventhandlers.MessageEvent{}
-	messageEvent.Name = eventhandlers.MESSAGE_READ
+	// read receipt is sent only if the privacy settings of the reader allow the sender to see it
+	privacySettings, err := apiConfig.DB.GetUserPrivacySettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/mark/read]: error fetching privacy settings: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	// fetching sender phonenumber
-	senderContact, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), params.SenderID)
+	sendReadReceipt, err := apiConfig.isVisibleTo(r.Context(), privacySettings.ReadReceiptsPrivacy, userID, message.SenderID)
 	if err != nil {
-		log.Printf("[/api/v1/message/mark/read]: error fetching sender contact: %v", err)
-		utility.RespondWithError(w, http.StatusNotFound, "sender not found")
+		log.Printf("[/api/v1/message/mark/read]: error checking read receipts privacy: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !sendReadReceipt {
+		utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+			AccessToken: newAccessToken,
+		})
 		return
 	}
-	messageEvent.Phonenumbers = []string{senderContact}
+
+	// creating MESSAGE_READ event
+	messageEvent := eventhandlers.MessageEvent{}
+	messageEvent.Name = eventhandlers.MESSAGE_READ
+
+	// event will be sent to the sender of the message
+	messageEvent.UserIDs = []uuid.UUID{message.SenderID}
 
 	// adding message to message event
 	messageEvent.Message = eventhandlers.Message{
@@ -932,20 +1160,17 @@ func (apiConfig *ApiConfig) HandleMarkGroupMessageReceived(w http.ResponseWriter
 		}
 
 		// updating cache
-		apiConfig.MessageCache.Update(params.GroupID.String(), params.MessageID, "", true, false, true, updatedAt)
+		apiConfig.MessageCache.Update(params.GroupID.String(), params.MessageID, func(message *database.Message) {
+			message.Recieved = true
+			message.UpdatedAt = updatedAt
+		})
 
 		// emitting GROUP_MESSAGE_RECEIVED event
 		messageEvent := eventhandlers.MessageEvent{}
 		messageEvent.Name = eventhandlers.GROUP_MESSAGE_RECEIVED
 
-		// fetching phonenumber of the sender
-		senderContact, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), params.SenderID)
-		if err != nil {
-			log.Printf("[/api/v1/message/group/mark/received]: error fetching sender phonenumber: %v", err)
-			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		messageEvent.Phonenumbers = []string{senderContact}
+		// event will be sent to the sender of the message
+		messageEvent.UserIDs = []uuid.UUID{params.SenderID}
 
 		// adding message to event
 		messageEvent.Message = eventhandlers.Message{
@@ -986,6 +1211,29 @@ func (apiConfig *ApiConfig) HandleMarkGroupMessageRead(w http.ResponseWriter, r
 		return
 	}
 
+	// the read is counted only if the privacy settings of the reader allow the sender to see it, otherwise
+	// it would reach the sender through the GROUP_MESSAGE_READ event and the read flag of the message in
+	// the group listings once every member has read the message
+	privacySettings, err := apiConfig.DB.GetUserPrivacySettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/group/mark/read]: error fetching privacy settings: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	sendReadReceipt, err := apiConfig.isVisibleTo(r.Context(), privacySettings.ReadReceiptsPrivacy, userID, params.SenderID)
+	if err != nil {
+		log.Printf("[/api/v1/message/group/mark/read]: error checking read receipts privacy: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !sendReadReceipt {
+		utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+			AccessToken: newAccessToken,
+		})
+		return
+	}
+
 	// marking group message read
 	if err = apiConfig.DB.MarkGroupMessageRead(r.Context(), database.MarkGroupMessageReadParams{
 		MessageID:     params.MessageID,
@@ -1020,7 +1268,7 @@ func (apiConfig *ApiConfig) HandleMarkGroupMessageRead(w http.ResponseWriter, r
 	// if groupMembersWhoReadMessage == groupMembersCount
 	// then mark message as read
 	if groupMemberCount == groupMembersWhoReadMessage {
-		updatedAt, err := apiConfig.DB.MarkMessageRead(r.Context(), params.MessageID)
+		markedRead, err := apiConfig.DB.MarkMessageRead(r.Context(), params.MessageID)
 		if err != nil {
 			log.Printf("[/api/v1/message/group/mark/read]: error marking the message as read: %v", err)
 			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
@@ -1028,20 +1276,19 @@ func (apiConfig *ApiConfig) HandleMarkGroupMessageRead(w http.ResponseWriter, r
 		}
 
 		// updating cache
-		apiConfig.MessageCache.Update(params.GroupID.String(), params.MessageID, "", true, true, true, updatedAt)
+		apiConfig.MessageCache.Update(params.GroupID.String(), params.MessageID, func(message *database.Message) {
+			message.Recieved = true
+			message.Read = true
+			message.UpdatedAt = markedRead.UpdatedAt
+			message.ExpiresAt = markedRead.ExpiresAt
+		})
 
 		// creating message event
 		messageEvent := eventhandlers.MessageEvent{}
 		messageEvent.Name = eventhandlers.GROUP_MESSAGE_READ
 
-		// fetching sender phonenumber
-		senderContact, err := apiConfig.DB.GetUserPhonenumberByID(r.Context(), params.SenderID)
-		if err != nil {
-			log.Printf("[/api/v1/message/group/read]: error fetching group members phonenumbers: %v", err)
-			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-		messageEvent.Phonenumbers = []string{senderContact}
+		// event will be sent to the sender of the message
+		messageEvent.UserIDs = []uuid.UUID{params.SenderID}
 
 		// adding message to event
 		messageEvent.Message = eventhandlers.Message{
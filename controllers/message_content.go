@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+// kinds of messages, the content of a message is a json object whose schema depends on its kind
+const (
+	messageKindText       = "text"       // description is the text, content is empty
+	messageKindSystem     = "system"     // notice about the group like a member joining, description is the rendered notice
+	messageKindAttachment = "attachment" // file from the attachments store, description is an optional caption
+	messageKindLocation   = "location"   // point on the map, description is an optional caption
+	messageKindContact    = "contact"    // contact card, description is an optional caption
+	messageKindPoll       = "poll"       // poll of a group, description is the question and the poll is stored separately
+)
+
+// actions of the system messages
+const (
+	systemActionMemberAdded   = "member_added"
+	systemActionMemberRemoved = "member_removed"
+	systemActionMemberJoined  = "member_joined"
+	systemActionMemberLeft    = "member_left"
+)
+
+const (
+	maxMessageContentSize    = 4096      // maximum size of the content json of a message in bytes
+	maxAttachmentSize        = 100 << 20 // maximum size of an attachment in bytes
+	maxAttachmentNameLength  = 255
+	maxLocationNameLength    = 100
+	maxLocationAddressLength = 300
+	maxContactNameLength     = 100
+
+	emptyMessageContent = "{}"
+	attachmentKeyPrefix = "attachments/" // attachments of a user are stored under attachments/{user id}/
+)
+
+var errInvalidMessageContent = errors.New("invalid message content")
+
+// content of an attachment message, the key points to a file uploaded by the sender to the attachments store
+type attachmentContent struct {
+	Key      string `json:"key"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// content of a location message
+type locationContent struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+}
+
+// content of a contact card message
+type contactContent struct {
+	Name        string `json:"name"`
+	Phonenumber string `json:"phonenumber"`
+}
+
+// content of a system message
+type systemContent struct {
+	Action   string    `json:"action"`
+	ActorID  uuid.UUID `json:"actor_id"`
+	MemberID uuid.UUID `json:"member_id"`
+}
+
+// decodeMessageContent decodes the content into v rejecting unknown fields
+func decodeMessageContent(content json.RawMessage, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidMessageContent, err)
+	}
+
+	return nil
+}
+
+// isEmptyMessageContent reports whether no content was sent
+func isEmptyMessageContent(content json.RawMessage) bool {
+	trimmed := strings.TrimSpace(string(content))
+	return trimmed == "" || trimmed == "null" || trimmed == emptyMessageContent
+}
+
+/*
+validateMessageContent checks the content of a message sent by a user against the schema of its kind
+and returns the content to store. Text messages need a description and no content, the other kinds
+need a content and the description is their caption. System messages and polls can not be sent
+through this path, they are created by the server and by the poll endpoint
+*/
+func (apiConfig *ApiConfig) validateMessageContent(ctx context.Context, senderID uuid.UUID, kind, description string, content json.RawMessage) (json.RawMessage, error) {
+	if len(content) > maxMessageContentSize {
+		return nil, fmt.Errorf("%w: content can be at most %d bytes", errInvalidMessageContent, maxMessageContentSize)
+	}
+
+	if kind == messageKindText {
+		if len(description) == 0 {
+			return nil, fmt.Errorf("%w: empty message description", errInvalidMessageContent)
+		}
+		if !isEmptyMessageContent(content) {
+			return nil, fmt.Errorf("%w: text messages have no content", errInvalidMessageContent)
+		}
+		return json.RawMessage(emptyMessageContent), nil
+	}
+
+	if isEmptyMessageContent(content) && (kind == messageKindAttachment || kind == messageKindLocation || kind == messageKindContact) {
+		return nil, fmt.Errorf("%w: %s messages need a content", errInvalidMessageContent, kind)
+	}
+
+	var validated any
+	switch kind {
+	case messageKindAttachment:
+		attachment := attachmentContent{}
+		if err := decodeMessageContent(content, &attachment); err != nil {
+			return nil, err
+		}
+
+		// users can only send the files which they uploaded
+		if !strings.HasPrefix(attachment.Key, attachmentKeyPrefix+senderID.String()+"/") || strings.Contains(attachment.Key, "..") {
+			return nil, fmt.Errorf("%w: unknown attachment key", errInvalidMessageContent)
+		}
+		if attachment.FileName == "" || utf8.RuneCountInString(attachment.FileName) > maxAttachmentNameLength ||
+			strings.ContainsAny(attachment.FileName, `/\`) {
+			return nil, fmt.Errorf("%w: file name must have 1 to %d characters and no path separators", errInvalidMessageContent, maxAttachmentNameLength)
+		}
+		if _, _, err := mime.ParseMediaType(attachment.MimeType); err != nil || !strings.Contains(attachment.MimeType, "/") {
+			return nil, fmt.Errorf("%w: invalid mime type", errInvalidMessageContent)
+		}
+		if attachment.Size <= 0 || attachment.Size > maxAttachmentSize {
+			return nil, fmt.Errorf("%w: attachment can be at most 100MB", errInvalidMessageContent)
+		}
+
+		file, err := apiConfig.Attachments.Get(ctx, attachment.Key)
+		if errors.Is(err, attachments.ErrNotFound) {
+			return nil, fmt.Errorf("%w: unknown attachment key", errInvalidMessageContent)
+		}
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+
+		validated = attachment
+	case messageKindLocation:
+		location := locationContent{}
+		if err := decodeMessageContent(content, &location); err != nil {
+			return nil, err
+		}
+
+		if math.IsNaN(location.Latitude) || location.Latitude < -90 || location.Latitude > 90 ||
+			math.IsNaN(location.Longitude) || location.Longitude < -180 || location.Longitude > 180 {
+			return nil, fmt.Errorf("%w: latitude must be between -90 and 90 and longitude between -180 and 180", errInvalidMessageContent)
+		}
+		if utf8.RuneCountInString(location.Name) > maxLocationNameLength || utf8.RuneCountInString(location.Address) > maxLocationAddressLength {
+			return nil, fmt.Errorf("%w: location name or address is too long", errInvalidMessageContent)
+		}
+
+		validated = location
+	case messageKindContact:
+		contact := contactContent{}
+		if err := decodeMessageContent(content, &contact); err != nil {
+			return nil, err
+		}
+
+		if contact.Name == "" || utf8.RuneCountInString(contact.Name) > maxContactNameLength {
+			return nil, fmt.Errorf("%w: contact name must have 1 to %d characters", errInvalidMessageContent, maxContactNameLength)
+		}
+		if err := apiConfig.DataValidator.Var(contact.Phonenumber, "required,phonenumber"); err != nil {
+			return nil, fmt.Errorf("%w: invalid contact phonenumber", errInvalidMessageContent)
+		}
+
+		validated = contact
+	case messageKindSystem, messageKindPoll:
+		return nil, fmt.Errorf("%w: %s messages can not be sent", errInvalidMessageContent, kind)
+	default:
+		return nil, fmt.Errorf("%w: unknown message kind %q", errInvalidMessageContent, kind)
+	}
+
+	// storing the content as decoded so that it follows the schema exactly
+	return json.Marshal(validated)
+}
+
+// isEditableKind reports whether the description of a message of the kind can be edited by its sender
+func isEditableKind(kind string) bool {
+	return kind != messageKindSystem && kind != messageKindPoll
+}
+
+/*
+postSystemMessage inserts a notice about the change in the members of the group into its history and
+pushes it to the members like any other message. The notice is rendered into the description with the
+current usernames while the content keeps the ids. Subscribers of a channel can not see each other so
+channels get no notices
+*/
+func (apiConfig *ApiConfig) postSystemMessage(ctx context.Context, groupID uuid.UUID, action string, actorID, memberID uuid.UUID) error {
+	group, err := apiConfig.DB.GetGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if group.Type == groupTypeChannel {
+		return nil
+	}
+
+	actor, err := apiConfig.DB.GetUserById(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	member, err := apiConfig.DB.GetUserById(ctx, memberID)
+	if err != nil {
+		return err
+	}
+
+	var description string
+	switch action {
+	case systemActionMemberAdded:
+		description = fmt.Sprintf("%s added %s", actor.Username, member.Username)
+	case systemActionMemberRemoved:
+		description = fmt.Sprintf("%s removed %s", actor.Username, member.Username)
+	case systemActionMemberJoined:
+		description = fmt.Sprintf("%s joined", member.Username)
+	case systemActionMemberLeft:
+		description = fmt.Sprintf("%s left", member.Username)
+	default:
+		return fmt.Errorf("unknown system message action %q", action)
+	}
+
+	content, err := json.Marshal(systemContent{
+		Action:   action,
+		ActorID:  actorID,
+		MemberID: memberID,
+	})
+	if err != nil {
+		return err
+	}
+
+	newMessage, err := apiConfig.DB.CreateMessage(ctx, database.CreateMessageParams{
+		Description: description,
+		SenderID:    actorID,
+		GroupID: uuid.NullUUID{
+			UUID:  groupID,
+			Valid: true,
+		},
+		Sent:    true,
+		Kind:    messageKindSystem,
+		Content: content,
+	})
+	if err != nil {
+		return err
+	}
+
+	return apiConfig.publishMessage(ctx, newMessage, false)
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+var (
+	errMessageBlocked = errors.New("you can not message this user")
+	errGroupNotFound  = errors.New("group not found")
+)
+
+/*
+authorizeMessage checks if the sender can post in the conversation. A user can not message
+a user who blocked it or whom it blocked and in a group the sender has to be a member who is
+allowed to post by the group settings. Only the owner and the admins post in a channel
+*/
+func (apiConfig *ApiConfig) authorizeMessage(ctx context.Context, senderID uuid.UUID, c conversation) error {
+	if c.GroupID == uuid.Nil {
+		blocked, err := apiConfig.DB.IsBlockedBetween(ctx, database.IsBlockedBetweenParams{
+			UserID:      senderID,
+			OtherUserID: c.ReceiverID,
+		})
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return errMessageBlocked
+		}
+
+		return nil
+	}
+
+	group, err := apiConfig.DB.GetGroup(ctx, c.GroupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errGroupNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	restricted := group.OnlyAdminsCanPost || group.Type == groupTypeChannel
+	_, err = apiConfig.authorizeRestrictedGroupAction(ctx, group.ID, senderID, permissionPostMessages, restricted)
+	return err
+}
+
+// respondWithMessageAuthorizationError responds with the status code matching the error returned by authorizeMessage
+func respondWithMessageAuthorizationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errMessageBlocked):
+		utility.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, errGroupNotFound):
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+	default:
+		respondWithGroupAuthorizationError(w, err)
+	}
+}
+
+// isMessageAuthorizationError reports whether the error returned by authorizeMessage means the sender can not post
+func isMessageAuthorizationError(err error) bool {
+	return errors.Is(err, errMessageBlocked) ||
+		errors.Is(err, errGroupNotFound) ||
+		errors.Is(err, errNotGroupMember) ||
+		errors.Is(err, errGroupActionNotAllowed)
+}
+
+/*
+publishMessage adds the newly created message to the cache and emits the NEW_MESSAGE event,
+or the MESSAGE_REQUEST event when the message went to the message requests inbox of the receiver.
+The conversation is unarchived for its participants and the members who muted it get a silent push
+*/
+func (apiConfig *ApiConfig) publishMessage(ctx context.Context, newMessage database.Message, isMessageRequest bool) error {
+	return apiConfig.publishMessageWithPoll(ctx, newMessage, isMessageRequest, nil)
+}
+
+// publishMessageWithPoll is publishMessage for a message which carries a poll
+func (apiConfig *ApiConfig) publishMessageWithPoll(ctx context.Context, newMessage database.Message, isMessageRequest bool, poll *eventhandlers.Poll) error {
+	// adding new message to cache
+	apiConfig.MessageCache.Set(messageCacheKey(newMessage), newMessage)
+
+	// emitting new message event
+	messageEvent := eventhandlers.MessageEvent{}
+
+	// adding event name
+	messageEvent.Name = eventhandlers.NEW_MESSAGE
+	if isMessageRequest {
+		messageEvent.Name = eventhandlers.MESSAGE_REQUEST
+	}
+
+	// adding the receivers of the message
+	recipients, err := apiConfig.conversationRecipients(ctx, conversation{
+		ReceiverID: newMessage.RecieverID.UUID,
+		GroupID:    newMessage.GroupID.UUID,
+	})
+	if err != nil {
+		return err
+	}
+	messageEvent.UserIDs = recipients
+
+	// a new message brings an archived conversation back to the inbox
+	if newMessage.GroupID.Valid {
+		err = apiConfig.DB.UnarchiveGroupConversation(ctx, newMessage.GroupID)
+	} else {
+		err = apiConfig.DB.UnarchiveConversation(ctx, database.UnarchiveConversationParams{
+			UserID:      newMessage.SenderID,
+			OtherUserID: newMessage.RecieverID.UUID,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	// finding the receivers who muted the conversation
+	messageEvent.MutedUserIDs, err = apiConfig.mutedRecipients(ctx, newMessage.SenderID, conversation{
+		ReceiverID: newMessage.RecieverID.UUID,
+		GroupID:    newMessage.GroupID.UUID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// adding the message
+	senderUsername, err := apiConfig.DB.GetUserById(ctx, newMessage.SenderID)
+	if err != nil {
+		return err
+	}
+	messageEvent.Message = eventhandlers.Message{
+		ID:             newMessage.ID,
+		Description:    newMessage.Description,
+		SenderID:       newMessage.SenderID,
+		SenderUsername: senderUsername.Username,
+		GroupID:        newMessage.GroupID.UUID,
+		Kind:           newMessage.Kind,
+		Content:        newMessage.Content,
+		Forwarded:      newMessage.Forwarded,
+		ForwardCount:   newMessage.ForwardCount,
+		Poll:           poll,
+		CreatedAt:      newMessage.CreatedAt.Format(time.RFC1123),
+	}
+
+	// providing event handler the instance of notification service
+	messageEvent.NotificationService = apiConfig.NotificationService
+
+	// providing event emitting time instance
+	messageEvent.EmittedAt = time.Now()
+
+	// passing the event to event handler
+	apiConfig.MessageEventEmitterChannel <- messageEvent
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// previous version of an edited message sent to the client
+type messageEdit struct {
+	Description string `json:"description"`
+	EditedAt    string `json:"edited_at"`
+}
+
+/*
+endpoint: /api/v1/message/edits
+
+returns the previous versions of the message, oldest first. The history is visible
+to the sender and the receiver of the message or to the members of its group
+*/
+func (apiConfig *ApiConfig) HandleGetMessageEdits(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ID uuid.UUID `json:"id"`
+	}
+
+	type response struct {
+		Edits       []messageEdit `json:"edits"`
+		AccessToken string        `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/edits]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	message, err := apiConfig.DB.GetMessageSenderReceiverAndGroupID(r.Context(), params.ID)
+	if err != nil {
+		log.Printf("[/api/v1/message/edits]: error fetching the message: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	// checking if the requesting user is a participant of the conversation
+	if message.GroupID.Valid {
+		if _, err := apiConfig.authorizeGroupAction(r.Context(), message.GroupID.UUID, userID, permissionViewGroup); err != nil {
+			log.Printf("[/api/v1/message/edits]: requesting user %s is not allowed: %v", userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
+		}
+	} else if userID != message.SenderID && userID != message.RecieverID.UUID {
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	edits, err := apiConfig.DB.GetMessageEdits(r.Context(), params.ID)
+	if err != nil {
+		log.Printf("[/api/v1/message/edits]: error fetching edit history: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	messageEdits := []messageEdit{}
+	for _, edit := range edits {
+		messageEdits = append(messageEdits, messageEdit{
+			Description: edit.Description,
+			EditedAt:    edit.EditedAt.Format(time.RFC1123),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Edits:       messageEdits,
+		AccessToken: newAccessToken,
+	})
+}
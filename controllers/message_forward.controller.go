@@ -0,0 +1,285 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const (
+	maxForwardMessages = 20 // number of messages which can be forwarded in one request
+	maxForwardTargets  = 5  // number of conversations into which messages can be forwarded in one request
+
+	// a message which went through this many forwards can only be forwarded into one conversation at a time
+	frequentlyForwardedCount = 5
+)
+
+var errMessageNotVisible = errors.New("message not found")
+
+// forwarded message sent to the client
+type forwardedMessage struct {
+	ID           uuid.UUID `json:"id"`
+	SourceID     uuid.UUID `json:"source_id"`
+	ReceiverID   uuid.UUID `json:"receiver_id,omitempty"`
+	GroupID      uuid.UUID `json:"group_id,omitempty"`
+	ForwardCount int32     `json:"forward_count"`
+	CreatedAt    string    `json:"created_at"`
+}
+
+/*
+getVisibleMessage returns the message if the user can currently see it. The user has to be a
+participant of the conversation of the message and must not have deleted it for itself, messages
+deleted for everyone and expired messages are not visible to anyone
+*/
+func (apiConfig *ApiConfig) getVisibleMessage(ctx context.Context, userID, messageID uuid.UUID) (database.GetMessageForForwardRow, error) {
+	message, err := apiConfig.DB.GetMessageForForward(ctx, messageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return message, errMessageNotVisible
+	}
+	if err != nil {
+		return message, err
+	}
+
+	if !message.GroupID.Valid {
+		if (message.SenderID == userID && message.IsSenderAllowedToSee) ||
+			(message.RecieverID.UUID == userID && message.IsReceiverAllowedToSee) {
+			return message, nil
+		}
+
+		return message, errMessageNotVisible
+	}
+
+	if _, err = apiConfig.authorizeGroupAction(ctx, message.GroupID.UUID, userID, permissionViewGroup); err != nil {
+		if errors.Is(err, errNotGroupMember) {
+			return message, errMessageNotVisible
+		}
+		return message, err
+	}
+
+	if message.SenderID == userID {
+		if !message.IsSenderAllowedToSee {
+			return message, errMessageNotVisible
+		}
+		return message, nil
+	}
+
+	isAllowedToSee, err := apiConfig.DB.IsGroupMemberAllowedToSeeMessage(ctx, database.IsGroupMemberAllowedToSeeMessageParams{
+		MessageID: messageID,
+		GroupID:   message.GroupID.UUID,
+		MemberID:  userID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return message, err
+	}
+	if err == nil && !isAllowedToSee {
+		return message, errMessageNotVisible
+	}
+
+	return message, nil
+}
+
+/*
+endpoint: /api/v1/message/forward
+
+forwards the messages with message_ids, in the given order, into every target conversation. A target
+has either a receiver_id or a group_id. The requesting user must be able to see every message and
+post in every target, otherwise nothing is forwarded. The copies are marked forwarded and carry
+the forward count of their source plus one
+*/
+func (apiConfig *ApiConfig) HandleForwardMessages(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type target struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+	}
+
+	type request struct {
+		MessageIDs []uuid.UUID `json:"message_ids"`
+		Targets    []target    `json:"targets"`
+	}
+
+	type response struct {
+		Messages    []forwardedMessage `json:"messages"`
+		AccessToken string             `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/forward]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if len(params.MessageIDs) == 0 || len(params.MessageIDs) > maxForwardMessages {
+		utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("between 1 and %d messages can be forwarded at once", maxForwardMessages))
+		return
+	}
+
+	if len(params.Targets) == 0 || len(params.Targets) > maxForwardTargets {
+		utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("messages can be forwarded into between 1 and %d conversations at once", maxForwardTargets))
+		return
+	}
+
+	// checking if the requesting user can see every message
+	seenMessages := make(map[uuid.UUID]bool)
+	sources := make([]database.GetMessageForForwardRow, 0, len(params.MessageIDs))
+	for _, messageID := range params.MessageIDs {
+		if seenMessages[messageID] {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "duplicate message id")
+			return
+		}
+		seenMessages[messageID] = true
+
+		source, err := apiConfig.getVisibleMessage(r.Context(), userID, messageID)
+		if errors.Is(err, errMessageNotVisible) {
+			utility.RespondWithError(w, http.StatusNotFound, fmt.Sprintf("message %s not found", messageID))
+			return
+		}
+		if err != nil {
+			log.Printf("[/api/v1/message/forward]: error fetching message %s: %v", messageID, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if !isEditableKind(source.Kind) {
+			utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("%s messages can not be forwarded", source.Kind))
+			return
+		}
+
+		if source.ForwardCount+1 >= frequentlyForwardedCount && len(params.Targets) > 1 {
+			utility.RespondWithError(w, http.StatusForbidden, "frequently forwarded messages can only be forwarded into one conversation at a time")
+			return
+		}
+
+		sources = append(sources, source)
+	}
+
+	// checking if the requesting user can post in every target
+	targets := make([]conversation, 0, len(params.Targets))
+	isMessageRequest := make(map[conversation]bool)
+	for _, requestedTarget := range params.Targets {
+		target := conversation(requestedTarget)
+		if (target.ReceiverID == uuid.Nil) == (target.GroupID == uuid.Nil) {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "target needs either a receiver id or a group id")
+			return
+		}
+
+		if slices.Contains(targets, target) {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "duplicate target")
+			return
+		}
+		targets = append(targets, target)
+
+		if err = apiConfig.authorizeMessage(r.Context(), userID, target); err != nil {
+			log.Printf("[/api/v1/message/forward]: requesting user %s is not allowed to post: %v", userID, err)
+			respondWithMessageAuthorizationError(w, err)
+			return
+		}
+
+		if target.ReceiverID != uuid.Nil {
+			isMessageRequest[target], err = apiConfig.isMessageRequest(r.Context(), userID, target.ReceiverID)
+			if errors.Is(err, errMessageRequestDeclined) {
+				utility.RespondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if err != nil {
+				log.Printf("[/api/v1/message/forward]: error checking message request: %v", err)
+				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+	}
+
+	// creating all the copies in one transaction so that a failure forwards nothing
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/forward]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	newMessages := make([]database.Message, 0, len(targets)*len(sources))
+	forwardedMessages := make([]forwardedMessage, 0, cap(newMessages))
+	for _, target := range targets {
+		for index, source := range sources {
+			message := database.CreateMessageParams{
+				Description: source.Description,
+				SenderID:    userID,
+				RecieverID: uuid.NullUUID{
+					UUID:  target.ReceiverID,
+					Valid: target.ReceiverID != uuid.Nil,
+				},
+				GroupID: uuid.NullUUID{
+					UUID:  target.GroupID,
+					Valid: target.GroupID != uuid.Nil,
+				},
+				Sent:         true,
+				Forwarded:    true,
+				ForwardCount: source.ForwardCount + 1,
+				Kind:         source.Kind,
+				Content:      source.Content,
+			}
+
+			// messages of a conversation with a message timer disappear
+			if err = apiConfig.applyMessageTimer(r.Context(), &message); err != nil {
+				log.Printf("[/api/v1/message/forward]: error applying message timer: %v", err)
+				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			newMessage, err := qtx.CreateMessage(r.Context(), message)
+			if err != nil {
+				log.Printf("[/api/v1/message/forward]: error creating forwarded message: %v", err)
+				utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			newMessages = append(newMessages, newMessage)
+			forwardedMessages = append(forwardedMessages, forwardedMessage{
+				ID:           newMessage.ID,
+				SourceID:     params.MessageIDs[index],
+				ReceiverID:   target.ReceiverID,
+				GroupID:      target.GroupID,
+				ForwardCount: newMessage.ForwardCount,
+				CreatedAt:    newMessage.CreatedAt.Format(time.RFC1123),
+			})
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/forward]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// caching the messages and pushing them to the receivers
+	for _, newMessage := range newMessages {
+		target := conversation{
+			ReceiverID: newMessage.RecieverID.UUID,
+			GroupID:    newMessage.GroupID.UUID,
+		}
+		if err = apiConfig.publishMessage(r.Context(), newMessage, isMessageRequest[target]); err != nil {
+			log.Printf("[/api/v1/message/forward]: error publishing forwarded message %s: %v", newMessage.ID, err)
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		Messages:    forwardedMessages,
+		AccessToken: newAccessToken,
+	})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+const (
+	expiredMessagesInterval  = 10 * time.Second // duration after which the expired messages are checked
+	expiredMessagesBatchSize = 100              // number of expired messages deleted in one query
+)
+
+/*
+MessageReaper deletes the disappearing messages whose timer has run out, evicts them from the
+message cache and tells the users of the conversation to drop them. The expired rows are locked
+while being deleted so the replicas never delete the same message twice
+*/
+func (apiConfig *ApiConfig) MessageReaper(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Printf("[MESSAGE_REAPER]: started message reaper")
+
+	checkingInterval := time.NewTicker(expiredMessagesInterval)
+	defer checkingInterval.Stop()
+
+	for {
+		select {
+		case <-checkingInterval.C:
+			apiConfig.deleteExpiredMessages(stop)
+		case <-stop:
+			log.Printf("[MESSAGE_REAPER]: stopped message reaper")
+			return
+		}
+	}
+}
+
+// deleteExpiredMessages deletes the expired messages batch by batch until none is left or the reaper is stopped
+func (apiConfig *ApiConfig) deleteExpiredMessages(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		expiredMessages, err := apiConfig.DB.DeleteExpiredMessages(ctx, expiredMessagesBatchSize)
+		if err != nil {
+			cancel()
+			log.Printf("[MESSAGE_REAPER]: error deleting expired messages: %v", err)
+			return
+		}
+
+		for _, message := range expiredMessages {
+			if err = apiConfig.dropExpiredMessage(ctx, message); err != nil {
+				log.Printf("[MESSAGE_REAPER]: error emitting DELETE_MESSAGE event for message %s: %v", message.ID, err)
+			}
+		}
+		cancel()
+
+		if len(expiredMessages) < expiredMessagesBatchSize {
+			return
+		}
+	}
+}
+
+// dropExpiredMessage removes the expired message from the cache and emits the DELETE_MESSAGE event
+func (apiConfig *ApiConfig) dropExpiredMessage(ctx context.Context, message database.DeleteExpiredMessagesRow) error {
+	var recipients []uuid.UUID
+	apiConfig.MessageCache.RemoveMessage(conversationCacheKey(message.SenderID, conversation{
+		ReceiverID: message.RecieverID.UUID,
+		GroupID:    message.GroupID.UUID,
+	}), message.ID)
+	if message.GroupID.Valid {
+		members, err := apiConfig.groupRecipients(ctx, message.GroupID.UUID)
+		if err != nil {
+			return err
+		}
+		recipients = members
+	} else {
+		// the message disappears for the sender as well
+		recipients = []uuid.UUID{message.SenderID, message.RecieverID.UUID}
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventhandlers.DELETE_MESSAGE,
+		UserIDs: recipients,
+		Message: eventhandlers.Message{
+			ID:       message.ID,
+			SenderID: message.SenderID,
+			GroupID:  message.GroupID.UUID,
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// status of a message request
+const (
+	messageRequestPending  = "pending"
+	messageRequestAccepted = "accepted"
+	messageRequestDeclined = "declined"
+)
+
+var errMessageRequestDeclined = errors.New("receiver declined your message request")
+
+/*
+isMessageRequest reports whether a message from sender to receiver has to go to the
+message requests inbox of the receiver. A message is delivered directly when the receiver
+has the sender in its contacts, has already messaged the sender or has accepted the message
+request of the sender. Replying to a pending message request accepts it
+*/
+func (apiConfig *ApiConfig) isMessageRequest(ctx context.Context, senderID, receiverID uuid.UUID) (bool, error) {
+	isContact, err := apiConfig.DB.IsUserContact(ctx, database.IsUserContactParams{
+		UserID:    receiverID,
+		ContactID: senderID,
+	})
+	if err != nil || isContact {
+		return false, err
+	}
+
+	// receiver started the conversation so sender is replying
+	hasReceiverMessaged, err := apiConfig.DB.HasSentMessageTo(ctx, database.HasSentMessageToParams{
+		SenderID: receiverID,
+		RecieverID: uuid.NullUUID{
+			UUID:  senderID,
+			Valid: true,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if hasReceiverMessaged {
+		if _, err = apiConfig.DB.UpdateMessageRequestStatus(ctx, database.UpdateMessageRequestStatusParams{
+			Status:     messageRequestAccepted,
+			SenderID:   receiverID,
+			ReceiverID: senderID,
+		}); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	status, err := apiConfig.DB.GetMessageRequestStatus(ctx, database.GetMessageRequestStatusParams{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, apiConfig.DB.CreateMessageRequest(ctx, database.CreateMessageRequestParams{
+			SenderID:   senderID,
+			ReceiverID: receiverID,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch status {
+	case messageRequestPending:
+		return true, nil
+	case messageRequestAccepted:
+		return false, nil
+	default:
+		return false, errMessageRequestDeclined
+	}
+}
+
+// endpoint: /api/v1/message/requests
+func (apiConfig *ApiConfig) HandleGetMessageRequests(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type messageRequest struct {
+		SenderID       uuid.UUID `json:"sender_id"`
+		SenderUsername string    `json:"sender_username"`
+		DisplayName    string    `json:"display_name,omitempty"`
+		TotalMessages  int64     `json:"total_messages"`
+		CreatedAt      string    `json:"created_at"`
+	}
+
+	type response struct {
+		MessageRequests []messageRequest `json:"message_requests"`
+		AccessToken     string           `json:"access_token"`
+	}
+
+	pendingRequests, err := apiConfig.DB.GetPendingMessageRequests(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/requests]: error fetching message requests: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	messageRequests := make([]messageRequest, 0, len(pendingRequests))
+	for _, pendingRequest := range pendingRequests {
+		messageRequests = append(messageRequests, messageRequest{
+			SenderID:       pendingRequest.SenderID,
+			SenderUsername: pendingRequest.Username,
+			DisplayName:    pendingRequest.DisplayName.String,
+			TotalMessages:  pendingRequest.TotalMessages,
+			CreatedAt:      pendingRequest.CreatedAt.Format(time.RFC1123),
+		})
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		MessageRequests: messageRequests,
+		AccessToken:     newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/message/requests/accept
+func (apiConfig *ApiConfig) HandleAcceptMessageRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.answerMessageRequest(w, r, userID, newAccessToken, messageRequestAccepted, "/api/v1/message/requests/accept")
+}
+
+// endpoint: /api/v1/message/requests/decline
+func (apiConfig *ApiConfig) HandleDeclineMessageRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.answerMessageRequest(w, r, userID, newAccessToken, messageRequestDeclined, "/api/v1/message/requests/decline")
+}
+
+// answerMessageRequest changes the status of a pending message request sent to the requesting user
+func (apiConfig *ApiConfig) answerMessageRequest(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken, status, endpoint string) {
+	type request struct {
+		SenderID uuid.UUID `json:"sender_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[%s]: error decoding request body: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	updated, err := apiConfig.DB.UpdateMessageRequestStatus(r.Context(), database.UpdateMessageRequestStatusParams{
+		Status:     status,
+		SenderID:   params.SenderID,
+		ReceiverID: userID,
+	})
+	if err != nil {
+		log.Printf("[%s]: error updating message request: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if updated == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "message request not found")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+const scheduledMessagesInterval = 10 * time.Second // duration after which the messages due for sending are checked
+
+/*
+MessageScheduler sends the scheduled messages whose send time has come. Scheduled messages are
+kept in the database so they survive restarts, and every due message is claimed with a row lock
+which the other replicas skip so that a message is sent only once
+*/
+func (apiConfig *ApiConfig) MessageScheduler(stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Printf("[MESSAGE_SCHEDULER]: started message scheduler")
+
+	checkingInterval := time.NewTicker(scheduledMessagesInterval)
+	defer checkingInterval.Stop()
+
+	for {
+		select {
+		case <-checkingInterval.C:
+			apiConfig.sendDueScheduledMessages(stop)
+		case <-stop:
+			log.Printf("[MESSAGE_SCHEDULER]: stopped message scheduler")
+			return
+		}
+	}
+}
+
+// sendDueScheduledMessages sends the due messages one by one until none is left or the scheduler is stopped
+func (apiConfig *ApiConfig) sendDueScheduledMessages(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		sent, err := apiConfig.sendNextScheduledMessage()
+		if err != nil {
+			log.Printf("[MESSAGE_SCHEDULER]: error sending scheduled message: %v", err)
+			return
+		}
+		if !sent {
+			return
+		}
+	}
+}
+
+/*
+sendNextScheduledMessage claims the earliest due message and sends it through the same path as
+a message created by the user. The message is created and marked sent in the transaction holding
+the claim so a crash before commit leaves it pending to be sent again. Returns false when no message is due
+*/
+func (apiConfig *ApiConfig) sendNextScheduledMessage() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	tx, err := apiConfig.DBConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	scheduled, err := qtx.ClaimDueScheduledMessage(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// the sender may have lost the right to post in the conversation after scheduling
+	isMessageRequest := false
+	err = apiConfig.authorizeMessage(ctx, scheduled.SenderID, conversation{
+		ReceiverID: scheduled.ReceiverID.UUID,
+		GroupID:    scheduled.GroupID.UUID,
+	})
+	if err == nil && scheduled.ReceiverID.Valid {
+		isMessageRequest, err = apiConfig.isMessageRequest(ctx, scheduled.SenderID, scheduled.ReceiverID.UUID)
+	}
+	if isMessageAuthorizationError(err) || errors.Is(err, errMessageRequestDeclined) {
+		log.Printf("[MESSAGE_SCHEDULER]: scheduled message %s failed: %v", scheduled.ID, err)
+		if err = qtx.MarkScheduledMessageFailed(ctx, scheduled.ID); err != nil {
+			return false, err
+		}
+
+		return true, tx.Commit()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	message := database.CreateMessageParams{
+		Description: scheduled.Description,
+		SenderID:    scheduled.SenderID,
+		RecieverID:  scheduled.ReceiverID,
+		GroupID:     scheduled.GroupID,
+		Sent:        true,
+		Kind:        messageKindText,
+		Content:     json.RawMessage(emptyMessageContent),
+	}
+	if err = apiConfig.applyMessageTimer(ctx, &message); err != nil {
+		return false, err
+	}
+
+	newMessage, err := qtx.CreateMessage(ctx, message)
+	if err != nil {
+		return false, err
+	}
+
+	if err = qtx.MarkScheduledMessageSent(ctx, database.MarkScheduledMessageSentParams{
+		MessageID: uuid.NullUUID{
+			UUID:  newMessage.ID,
+			Valid: true,
+		},
+		ID: scheduled.ID,
+	}); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+
+	// caching the message and pushing it to the receivers
+	if err = apiConfig.publishMessage(ctx, newMessage, isMessageRequest); err != nil {
+		log.Printf("[MESSAGE_SCHEDULER]: error publishing scheduled message %s: %v", scheduled.ID, err)
+	}
+
+	if err = apiConfig.recordMentions(ctx, newMessage); err != nil {
+		log.Printf("[MESSAGE_SCHEDULER]: error recording mentions of scheduled message %s: %v", scheduled.ID, err)
+	}
+	apiConfig.queueLinkPreview(newMessage)
+
+	return true, nil
+}
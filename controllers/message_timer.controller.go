@@ -0,0 +1,277 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+/*
+A conversation with a message timer has disappearing messages. Messages sent while the timer
+is set expire duration seconds after being sent, or in a one-to-one conversation optionally after
+being read by the receiver. Expired messages are deleted by the message reaper
+*/
+const (
+	timerStartsOnSent = "sent"
+	timerStartsOnRead = "read"
+
+	minMessageTimer = 5                 // shortest message timer in seconds
+	maxMessageTimer = 90 * 24 * 60 * 60 // longest message timer in seconds
+)
+
+/*
+applyMessageTimer sets the expiry of the message from the timer of its conversation. A message
+expiring after being read gets its expires_at when the receiver marks it read
+*/
+func (apiConfig *ApiConfig) applyMessageTimer(ctx context.Context, message *database.CreateMessageParams) error {
+	var duration int32
+	startsOn := timerStartsOnSent
+	if message.GroupID.Valid {
+		group, err := apiConfig.DB.GetGroup(ctx, message.GroupID.UUID)
+		if err != nil {
+			return err
+		}
+
+		duration = group.MessageTimer.Int32
+	} else {
+		timer, err := apiConfig.DB.GetConversationTimer(ctx, database.GetConversationTimerParams{
+			UserID:      message.SenderID,
+			OtherUserID: message.RecieverID.UUID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		duration = timer.Duration
+		startsOn = timer.StartsOn
+	}
+
+	if duration == 0 {
+		return nil
+	}
+
+	if startsOn == timerStartsOnRead {
+		message.ExpiresAfter = sql.NullInt32{
+			Int32: duration,
+			Valid: true,
+		}
+	} else {
+		message.ExpiresAt = sql.NullTime{
+			Time:  time.Now().Add(time.Duration(duration) * time.Second),
+			Valid: true,
+		}
+	}
+
+	return nil
+}
+
+/*
+endpoint: /api/v1/message/timer
+
+sets the message timer of the one-to-one conversation with receiver_id or of the group with group_id.
+duration is in seconds and 0 turns the timer off. starts_on is sent or read and defaults to sent,
+in a group the timer always starts when the message is sent because every member reads it at a different time
+*/
+func (apiConfig *ApiConfig) HandleSetMessageTimer(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+		Duration   int32     `json:"duration"`
+		StartsOn   string    `json:"starts_on"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/timer]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if (params.ReceiverID == uuid.Nil) == (params.GroupID == uuid.Nil) {
+		log.Printf("[/api/v1/message/timer]: invalid request body")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "timer needs either a receiver id or a group id")
+		return
+	}
+
+	if params.Duration != 0 && (params.Duration < minMessageTimer || params.Duration > maxMessageTimer) {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "duration must be between 5 seconds and 90 days")
+		return
+	}
+
+	if params.StartsOn == "" {
+		params.StartsOn = timerStartsOnSent
+	}
+	if params.StartsOn != timerStartsOnSent && params.StartsOn != timerStartsOnRead {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "starts_on must be sent or read")
+		return
+	}
+
+	if params.GroupID != uuid.Nil {
+		if params.StartsOn != timerStartsOnSent {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "timer of a group can only start when the message is sent")
+			return
+		}
+
+		group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+		if err != nil {
+			log.Printf("[/api/v1/message/timer]: error fetching group: %v", err)
+			utility.RespondWithError(w, http.StatusNotFound, "group not found")
+			return
+		}
+
+		// the timer is group info so the group settings decide who can change it
+		if _, err := apiConfig.authorizeRestrictedGroupAction(r.Context(), group.ID, userID, permissionEditGroupInfo, group.OnlyAdminsCanEditInfo); err != nil {
+			log.Printf("[/api/v1/message/timer]: requesting user %s is not allowed: %v", userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
+		}
+
+		if err = apiConfig.DB.UpdateGroupMessageTimer(r.Context(), database.UpdateGroupMessageTimerParams{
+			MessageTimer: sql.NullInt32{
+				Int32: params.Duration,
+				Valid: params.Duration > 0,
+			},
+			ID: group.ID,
+		}); err != nil {
+			log.Printf("[/api/v1/message/timer]: error updating group message timer: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err = apiConfig.emitGroupUpdated(r.Context(), group.ID, userID, map[string]any{"message_timer": params.Duration}); err != nil {
+			log.Printf("[/api/v1/message/timer]: error emitting GROUP_UPDATED event: %v", err)
+		}
+
+		utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+			AccessToken: newAccessToken,
+		})
+		return
+	}
+
+	// a user can change the timer only of a conversation in which it can message
+	if err = apiConfig.authorizeMessage(r.Context(), userID, conversation{ReceiverID: params.ReceiverID}); err != nil {
+		log.Printf("[/api/v1/message/timer]: requesting user %s is not allowed: %v", userID, err)
+		respondWithMessageAuthorizationError(w, err)
+		return
+	}
+
+	if params.Duration == 0 {
+		params.StartsOn = ""
+		err = apiConfig.DB.RemoveConversationTimer(r.Context(), database.RemoveConversationTimerParams{
+			UserID:      userID,
+			OtherUserID: params.ReceiverID,
+		})
+	} else {
+		err = apiConfig.DB.SetConversationTimer(r.Context(), database.SetConversationTimerParams{
+			UserID:      userID,
+			OtherUserID: params.ReceiverID,
+			Duration:    params.Duration,
+			StartsOn:    params.StartsOn,
+		})
+	}
+	if err != nil {
+		log.Printf("[/api/v1/message/timer]: error updating conversation timer: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// letting the other user of the conversation know about the new timer
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventhandlers.MESSAGE_TIMER_CHANGED,
+		UserIDs: []uuid.UUID{params.ReceiverID},
+		Message: eventhandlers.Message{
+			SenderID:      userID,
+			Timer:         params.Duration,
+			TimerStartsOn: params.StartsOn,
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/message/timer
+func (apiConfig *ApiConfig) HandleGetMessageTimer(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+	}
+
+	type response struct {
+		Duration    int32  `json:"duration"`
+		StartsOn    string `json:"starts_on,omitempty"`
+		AccessToken string `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/timer]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	timer := response{
+		AccessToken: newAccessToken,
+	}
+	if params.GroupID != uuid.Nil {
+		// checking if the requesting user is member of the group
+		if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup); err != nil {
+			log.Printf("[/api/v1/message/timer]: requesting user %s is not allowed: %v", userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
+		}
+
+		group, err := apiConfig.DB.GetGroup(r.Context(), params.GroupID)
+		if err != nil {
+			log.Printf("[/api/v1/message/timer]: error fetching group: %v", err)
+			utility.RespondWithError(w, http.StatusNotFound, "group not found")
+			return
+		}
+
+		if group.MessageTimer.Valid {
+			timer.Duration = group.MessageTimer.Int32
+			timer.StartsOn = timerStartsOnSent
+		}
+	} else if params.ReceiverID != uuid.Nil {
+		conversationTimer, err := apiConfig.DB.GetConversationTimer(r.Context(), database.GetConversationTimerParams{
+			UserID:      userID,
+			OtherUserID: params.ReceiverID,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("[/api/v1/message/timer]: error fetching conversation timer: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		timer.Duration = conversationTimer.Duration
+		timer.StartsOn = conversationTimer.StartsOn
+	} else {
+		log.Printf("[/api/v1/message/timer]: empty receiver id and group id")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty receiver id and group id")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, timer)
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const maxPinnedMessages = 3 // maximum number of pinned messages in a conversation
+
+// pinned message sent to the client
+type pinnedMessage struct {
+	ID          uuid.UUID `json:"id"`
+	Description string    `json:"description"`
+	SenderID    uuid.UUID `json:"sender_id"`
+	PinnedBy    uuid.UUID `json:"pinned_by"`
+	CreatedAt   string    `json:"created_at"`
+	PinnedAt    string    `json:"pinned_at"`
+}
+
+// endpoint: /api/v1/message/pin
+func (apiConfig *ApiConfig) HandlePinMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.changeMessagePin(w, r, userID, newAccessToken, true, "/api/v1/message/pin")
+}
+
+// endpoint: /api/v1/message/unpin
+func (apiConfig *ApiConfig) HandleUnpinMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	apiConfig.changeMessagePin(w, r, userID, newAccessToken, false, "/api/v1/message/unpin")
+}
+
+/*
+changeMessagePin pins or unpins a message for everyone in the conversation.
+In a one-to-one conversation both the users can pin messages and in a group
+the role of the requesting user has to allow pinning
+*/
+func (apiConfig *ApiConfig) changeMessagePin(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string, pin bool, endpoint string) {
+	type request struct {
+		ID uuid.UUID `json:"id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[%s]: error decoding request body: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.ID == uuid.Nil {
+		log.Printf("[%s]: empty message id", endpoint)
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty message id")
+		return
+	}
+
+	message, err := apiConfig.DB.GetMessageSenderReceiverAndGroupID(r.Context(), params.ID)
+	if err != nil {
+		log.Printf("[%s]: error fetching the message: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusNotFound, "message not found")
+		return
+	}
+
+	// messages deleted for everyone and expired messages can not be pinned, they can still be unpinned
+	if pin {
+		if _, err = apiConfig.getVisibleMessage(r.Context(), userID, params.ID); err != nil {
+			if errors.Is(err, errMessageNotVisible) {
+				utility.RespondWithError(w, http.StatusNotFound, "message not found")
+				return
+			}
+
+			log.Printf("[%s]: error checking visibility of the message: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	// the conversation is locked before counting its pinned messages so that concurrent pins can not exceed the limit
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[%s]: error starting transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	// finding the conversation of the message and the number of messages pinned in it
+	var c conversation
+	var pinnedCount int64
+	if message.GroupID.Valid {
+		if _, err = qtx.LockGroup(r.Context(), message.GroupID.UUID); err != nil {
+			log.Printf("[%s]: error locking group: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusNotFound, "group not found")
+			return
+		}
+
+		// role is checked after locking the group so that a member demoted meanwhile can not pin
+		if _, err := authorizeGroupActionWith(r.Context(), qtx, message.GroupID.UUID, userID, permissionPinMessages); err != nil {
+			log.Printf("[%s]: requesting user %s is not allowed: %v", endpoint, userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
+		}
+
+		c.GroupID = message.GroupID.UUID
+		pinnedCount, err = qtx.CountGroupPinnedMessages(r.Context(), message.GroupID)
+	} else {
+		switch userID {
+		case message.SenderID:
+			c.ReceiverID = message.RecieverID.UUID
+		case message.RecieverID.UUID:
+			c.ReceiverID = message.SenderID
+		default:
+			utility.RespondWithError(w, http.StatusNotFound, "message not found")
+			return
+		}
+
+		// a one-to-one conversation has no row to lock so a transaction level advisory lock on both the users is taken
+		if err = qtx.LockConversation(r.Context(), database.LockConversationParams{
+			UserID:      userID,
+			OtherUserID: c.ReceiverID,
+		}); err != nil {
+			log.Printf("[%s]: error locking conversation: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		pinnedCount, err = qtx.CountConversationPinnedMessages(r.Context(), database.CountConversationPinnedMessagesParams{
+			UserID:      userID,
+			OtherUserID: c.ReceiverID,
+		})
+	}
+	if err != nil {
+		log.Printf("[%s]: error counting pinned messages: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	eventName := eventhandlers.MESSAGE_PINNED
+	if pin {
+		if pinnedCount >= maxPinnedMessages {
+			utility.RespondWithError(w, http.StatusConflict, "a conversation can have at most 3 pinned messages")
+			return
+		}
+
+		pinned, err := qtx.PinMessage(r.Context(), database.PinMessageParams{
+			MessageID: params.ID,
+			PinnedBy:  userID,
+		})
+		if err != nil {
+			log.Printf("[%s]: error pinning message: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if pinned == 0 {
+			utility.RespondWithError(w, http.StatusConflict, "message is already pinned")
+			return
+		}
+	} else {
+		eventName = eventhandlers.MESSAGE_UNPINNED
+		unpinned, err := qtx.UnpinMessage(r.Context(), params.ID)
+		if err != nil {
+			log.Printf("[%s]: error unpinning message: %v", endpoint, err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if unpinned == 0 {
+			utility.RespondWithError(w, http.StatusNotFound, "message is not pinned")
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[%s]: error committing transaction: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// emitting the event to the other users of the conversation
+	recipients, err := apiConfig.conversationRecipients(r.Context(), c)
+	if err != nil {
+		log.Printf("[%s]: error fetching recipients: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventName,
+		UserIDs: recipients,
+		Message: eventhandlers.Message{
+			ID:       params.ID,
+			SenderID: message.SenderID,
+			GroupID:  c.GroupID,
+			PinnedBy: userID,
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/message/pinned
+
+returns the pinned messages of the one-to-one conversation with receiver_id
+or of the group with group_id, latest pinned first
+*/
+func (apiConfig *ApiConfig) HandleGetPinnedMessages(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ReceiverID uuid.UUID `json:"receiver_id"`
+		GroupID    uuid.UUID `json:"group_id"`
+	}
+
+	type response struct {
+		PinnedMessages []pinnedMessage `json:"pinned_messages"`
+		AccessToken    string          `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/pinned]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	pinnedMessages := []pinnedMessage{}
+	if params.GroupID != uuid.Nil {
+		// checking if the requesting user is member of the group
+		if _, err := apiConfig.authorizeGroupAction(r.Context(), params.GroupID, userID, permissionViewGroup); err != nil {
+			log.Printf("[/api/v1/message/pinned]: requesting user %s is not allowed: %v", userID, err)
+			respondWithGroupAuthorizationError(w, err)
+			return
+		}
+
+		pinned, err := apiConfig.DB.GetGroupPinnedMessages(r.Context(), uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: true,
+		})
+		if err != nil {
+			log.Printf("[/api/v1/message/pinned]: error fetching pinned messages: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, message := range pinned {
+			pinnedMessages = append(pinnedMessages, pinnedMessage{
+				ID:          message.ID,
+				Description: message.Description,
+				SenderID:    message.SenderID,
+				PinnedBy:    message.PinnedBy,
+				CreatedAt:   message.CreatedAt.Format(time.RFC1123),
+				PinnedAt:    message.PinnedAt.Format(time.RFC1123),
+			})
+		}
+	} else if params.ReceiverID != uuid.Nil {
+		pinned, err := apiConfig.DB.GetConversationPinnedMessages(r.Context(), database.GetConversationPinnedMessagesParams{
+			UserID:      userID,
+			OtherUserID: params.ReceiverID,
+		})
+		if err != nil {
+			log.Printf("[/api/v1/message/pinned]: error fetching pinned messages: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		for _, message := range pinned {
+			pinnedMessages = append(pinnedMessages, pinnedMessage{
+				ID:          message.ID,
+				Description: message.Description,
+				SenderID:    message.SenderID,
+				PinnedBy:    message.PinnedBy,
+				CreatedAt:   message.CreatedAt.Format(time.RFC1123),
+				PinnedAt:    message.PinnedAt.Format(time.RFC1123),
+			})
+		}
+	} else {
+		log.Printf("[/api/v1/message/pinned]: empty receiver id and group id")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty receiver id and group id")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		PinnedMessages: pinnedMessages,
+		AccessToken:    newAccessToken,
+	})
+}
@@ -0,0 +1,605 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const (
+	minPollOptions        = 2
+	maxPollOptions        = 12
+	maxPollQuestionLength = 300
+	maxPollOptionLength   = 100
+	maxPollDuration       = 30 * 24 * time.Hour // a poll can be set to close at most this far in the future
+)
+
+var errPollNotFound = errors.New("poll not found")
+
+// isPollClosed reports whether the poll was closed or its close time has passed
+func isPollClosed(poll database.Poll) bool {
+	return poll.ClosedAt.Valid || (poll.ClosesAt.Valid && !poll.ClosesAt.Time.After(time.Now()))
+}
+
+/*
+pollResults returns the poll with the number of votes of every option. The voters of every
+option are listed only when the poll is not anonymous. If userID is not nil the options voted
+by that user are added so that it can see its own vote even in an anonymous poll
+*/
+func (apiConfig *ApiConfig) pollResults(ctx context.Context, poll database.Poll, userID uuid.UUID) (*eventhandlers.Poll, error) {
+	options, err := apiConfig.DB.GetPollResults(ctx, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	voters := make(map[uuid.UUID][]uuid.UUID)
+	if !poll.Anonymous {
+		votes, err := apiConfig.DB.GetPollVoters(ctx, poll.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			voters[vote.OptionID] = append(voters[vote.OptionID], vote.UserID)
+		}
+	}
+
+	results := &eventhandlers.Poll{
+		ID:             poll.ID,
+		Question:       poll.Question,
+		MultipleChoice: poll.MultipleChoice,
+		Anonymous:      poll.Anonymous,
+		Closed:         isPollClosed(poll),
+		Options:        []eventhandlers.PollOption{},
+	}
+	if poll.ClosesAt.Valid {
+		results.ClosesAt = poll.ClosesAt.Time.Format(time.RFC1123)
+	}
+	for _, option := range options {
+		results.TotalVotes += option.Votes
+		results.Options = append(results.Options, eventhandlers.PollOption{
+			ID:     option.ID,
+			Text:   option.Text,
+			Votes:  option.Votes,
+			Voters: voters[option.ID],
+		})
+	}
+
+	if userID != uuid.Nil {
+		results.MyVotes, err = apiConfig.DB.GetUserPollVotes(ctx, database.GetUserPollVotesParams{
+			PollID: poll.ID,
+			UserID: userID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// emitPollUpdated pushes the latest results of the poll to every member of its group
+func (apiConfig *ApiConfig) emitPollUpdated(ctx context.Context, poll database.Poll) error {
+	results, err := apiConfig.pollResults(ctx, poll, uuid.Nil)
+	if err != nil {
+		return err
+	}
+
+	recipients, err := apiConfig.groupRecipients(ctx, poll.GroupID)
+	if err != nil {
+		return err
+	}
+
+	apiConfig.MessageEventEmitterChannel <- eventhandlers.MessageEvent{
+		Name:    eventhandlers.POLL_UPDATED,
+		UserIDs: recipients,
+		Message: eventhandlers.Message{
+			ID:      poll.MessageID,
+			GroupID: poll.GroupID,
+			Poll:    results,
+		},
+		NotificationService: apiConfig.NotificationService,
+		EmittedAt:           time.Now(),
+	}
+
+	return nil
+}
+
+// withPolls adds the poll results to the poll messages of a group, the polls of deleted messages are not shown
+func (apiConfig *ApiConfig) withPolls(ctx context.Context, messages []database.Message, userID uuid.UUID) ([]detailedMessage, error) {
+	messageIDs := make([]uuid.UUID, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+
+	polls, err := apiConfig.DB.GetPollsByMessageIDs(ctx, messageIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	messagePolls := make(map[uuid.UUID]database.Poll)
+	for _, poll := range polls {
+		messagePolls[poll.MessageID] = poll
+	}
+
+	detailedMessages := []detailedMessage{}
+	for _, message := range messages {
+		detailedMessage := detailedMessage{
+			Message: message,
+		}
+		if poll, ok := messagePolls[message.ID]; ok && !message.DeletedAt.Valid {
+			detailedMessage.Poll, err = apiConfig.pollResults(ctx, poll, userID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		detailedMessages = append(detailedMessages, detailedMessage)
+	}
+
+	return detailedMessages, nil
+}
+
+// lockPoll locks the poll in the transaction and checks if the user is a member of its group
+func (apiConfig *ApiConfig) lockPoll(ctx context.Context, qtx *database.Queries, pollID, userID uuid.UUID) (database.Poll, string, error) {
+	row, err := qtx.GetPollForUpdate(ctx, pollID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && row.DeletedAt.Valid) {
+		return database.Poll{}, "", errPollNotFound
+	}
+	if err != nil {
+		return database.Poll{}, "", err
+	}
+
+	poll := database.Poll{
+		ID:             row.ID,
+		MessageID:      row.MessageID,
+		GroupID:        row.GroupID,
+		CreatedBy:      row.CreatedBy,
+		Question:       row.Question,
+		MultipleChoice: row.MultipleChoice,
+		Anonymous:      row.Anonymous,
+		ClosesAt:       row.ClosesAt,
+		ClosedAt:       row.ClosedAt,
+		CreatedAt:      row.CreatedAt,
+	}
+
+	role, err := apiConfig.authorizeGroupAction(ctx, poll.GroupID, userID, permissionViewGroup)
+	return poll, role, err
+}
+
+// respondWithPollError responds with the status code matching the error returned by lockPoll
+func respondWithPollError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errPollNotFound) {
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondWithGroupAuthorizationError(w, err)
+}
+
+/*
+endpoint: /api/v1/message/poll/create
+
+posts a poll in the group. A poll has 2 to 12 options, allows one or multiple choices, shows or hides
+who voted for what and optionally closes at closes_at which is at most 30 days from now
+*/
+func (apiConfig *ApiConfig) HandleCreatePoll(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		GroupID        uuid.UUID `json:"group_id"`
+		Question       string    `json:"question"`
+		Options        []string  `json:"options"`
+		MultipleChoice bool      `json:"multiple_choice"`
+		Anonymous      bool      `json:"anonymous"`
+		ClosesAt       time.Time `json:"closes_at"`
+	}
+
+	type response struct {
+		ID          uuid.UUID           `json:"id"`
+		Poll        *eventhandlers.Poll `json:"poll"`
+		CreatedAt   string              `json:"created_at"`
+		AccessToken string              `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.GroupID == uuid.Nil {
+		log.Printf("[/api/v1/message/poll/create]: empty group id")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty group id")
+		return
+	}
+
+	params.Question = strings.TrimSpace(params.Question)
+	if len(params.Question) == 0 || len(params.Question) > maxPollQuestionLength {
+		utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("question must be between 1 and %d characters", maxPollQuestionLength))
+		return
+	}
+
+	if len(params.Options) < minPollOptions || len(params.Options) > maxPollOptions {
+		utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("poll must have between %d and %d options", minPollOptions, maxPollOptions))
+		return
+	}
+
+	for index, option := range params.Options {
+		option = strings.TrimSpace(option)
+		if len(option) == 0 || len(option) > maxPollOptionLength {
+			utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("option must be between 1 and %d characters", maxPollOptionLength))
+			return
+		}
+		if slices.Contains(params.Options[:index], option) {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "duplicate option")
+			return
+		}
+		params.Options[index] = option
+	}
+
+	if !params.ClosesAt.IsZero() {
+		if delay := time.Until(params.ClosesAt); delay < minScheduleDelay || delay > maxPollDuration {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "closes_at must be between a minute and 30 days from now")
+			return
+		}
+	}
+
+	// checking if the requesting user can post in the group
+	if err = apiConfig.authorizeMessage(r.Context(), userID, conversation{
+		GroupID: params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/poll/create]: requesting user %s is not allowed to post: %v", userID, err)
+		respondWithMessageAuthorizationError(w, err)
+		return
+	}
+
+	// the poll is a message with the question as its description
+	message := database.CreateMessageParams{
+		Description: params.Question,
+		SenderID:    userID,
+		GroupID: uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: true,
+		},
+		Sent:    true,
+		Kind:    messageKindPoll,
+		Content: json.RawMessage(emptyMessageContent),
+	}
+	if err = apiConfig.applyMessageTimer(r.Context(), &message); err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error applying message timer: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	newMessage, err := qtx.CreateMessage(r.Context(), message)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error creating poll message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	poll, err := qtx.CreatePoll(r.Context(), database.CreatePollParams{
+		MessageID:      newMessage.ID,
+		GroupID:        params.GroupID,
+		CreatedBy:      userID,
+		Question:       params.Question,
+		MultipleChoice: params.MultipleChoice,
+		Anonymous:      params.Anonymous,
+		ClosesAt: sql.NullTime{
+			Time:  params.ClosesAt,
+			Valid: !params.ClosesAt.IsZero(),
+		},
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error creating poll: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for index, option := range params.Options {
+		if err = qtx.CreatePollOption(r.Context(), database.CreatePollOptionParams{
+			PollID:   poll.ID,
+			Position: int32(index),
+			Text:     option,
+		}); err != nil {
+			log.Printf("[/api/v1/message/poll/create]: error creating poll option: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	results, err := apiConfig.pollResults(r.Context(), poll, uuid.Nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error fetching poll results: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// caching the message and pushing it with the poll to the group members
+	if err = apiConfig.publishMessageWithPoll(r.Context(), newMessage, false, results); err != nil {
+		log.Printf("[/api/v1/message/poll/create]: error publishing poll message: %v", err)
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		ID:          newMessage.ID,
+		Poll:        results,
+		CreatedAt:   newMessage.CreatedAt.Format(time.RFC1123),
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/message/poll/vote
+
+votes for the options with option_ids in the poll, replacing the previous vote of the requesting user.
+A single choice poll takes exactly one option
+*/
+func (apiConfig *ApiConfig) HandleVotePoll(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		PollID    uuid.UUID   `json:"poll_id"`
+		OptionIDs []uuid.UUID `json:"option_ids"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if len(params.OptionIDs) == 0 {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty option ids")
+		return
+	}
+
+	for index, optionID := range params.OptionIDs {
+		if slices.Contains(params.OptionIDs[:index], optionID) {
+			utility.RespondWithError(w, http.StatusNotAcceptable, "duplicate option id")
+			return
+		}
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	// locking the poll so that the votes of a user are replaced atomically
+	poll, _, err := apiConfig.lockPoll(r.Context(), qtx, params.PollID, userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: requesting user %s can not vote: %v", userID, err)
+		respondWithPollError(w, err)
+		return
+	}
+
+	if isPollClosed(poll) {
+		utility.RespondWithError(w, http.StatusConflict, "poll is closed")
+		return
+	}
+
+	if !poll.MultipleChoice && len(params.OptionIDs) > 1 {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "poll allows only one option")
+		return
+	}
+
+	if _, err = qtx.DeletePollVotes(r.Context(), database.DeletePollVotesParams{
+		PollID: poll.ID,
+		UserID: userID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error removing previous vote: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	for _, optionID := range params.OptionIDs {
+		voted, err := qtx.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+			UserID:   userID,
+			PollID:   poll.ID,
+			OptionID: optionID,
+		})
+		if err != nil {
+			log.Printf("[/api/v1/message/poll/vote]: error saving vote: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if voted == 0 {
+			utility.RespondWithError(w, http.StatusNotAcceptable, fmt.Sprintf("option %s is not in the poll", optionID))
+			return
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.respondWithPoll(w, r, poll, userID, newAccessToken, "/api/v1/message/poll/vote")
+}
+
+// endpoint: /api/v1/message/poll/vote
+func (apiConfig *ApiConfig) HandleRetractPollVote(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		PollID uuid.UUID `json:"poll_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	poll, _, err := apiConfig.lockPoll(r.Context(), qtx, params.PollID, userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: requesting user %s can not retract vote: %v", userID, err)
+		respondWithPollError(w, err)
+		return
+	}
+
+	if isPollClosed(poll) {
+		utility.RespondWithError(w, http.StatusConflict, "poll is closed")
+		return
+	}
+
+	retracted, err := qtx.DeletePollVotes(r.Context(), database.DeletePollVotesParams{
+		PollID: poll.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error retracting vote: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if retracted == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "you have not voted in this poll")
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/poll/vote]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	apiConfig.respondWithPoll(w, r, poll, userID, newAccessToken, "/api/v1/message/poll/vote")
+}
+
+/*
+endpoint: /api/v1/message/poll/close
+
+closes the poll so that nobody can vote anymore. The creator of the poll and the members
+who can delete the messages of others can close it
+*/
+func (apiConfig *ApiConfig) HandleClosePoll(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		PollID uuid.UUID `json:"poll_id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/close]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/close]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	poll, role, err := apiConfig.lockPoll(r.Context(), qtx, params.PollID, userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/poll/close]: requesting user %s can not close poll: %v", userID, err)
+		respondWithPollError(w, err)
+		return
+	}
+
+	if poll.CreatedBy != userID && !slices.Contains(groupPermissions[permissionDeleteOthersMessages], role) {
+		utility.RespondWithError(w, http.StatusForbidden, "only the creator of the poll can close it")
+		return
+	}
+
+	if isPollClosed(poll) {
+		utility.RespondWithError(w, http.StatusConflict, "poll is already closed")
+		return
+	}
+
+	if _, err = qtx.ClosePoll(r.Context(), poll.ID); err != nil {
+		log.Printf("[/api/v1/message/poll/close]: error closing poll: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/message/poll/close]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	poll.ClosedAt = sql.NullTime{
+		Time:  time.Now(),
+		Valid: true,
+	}
+	apiConfig.respondWithPoll(w, r, poll, userID, newAccessToken, "/api/v1/message/poll/close")
+}
+
+// respondWithPoll pushes the POLL_UPDATED event to the group and responds with the results seen by the requesting user
+func (apiConfig *ApiConfig) respondWithPoll(w http.ResponseWriter, r *http.Request, poll database.Poll, userID uuid.UUID, newAccessToken string, endpoint string) {
+	type response struct {
+		Poll        *eventhandlers.Poll `json:"poll"`
+		AccessToken string              `json:"access_token"`
+	}
+
+	if err := apiConfig.emitPollUpdated(r.Context(), poll); err != nil {
+		log.Printf("[%s]: error emitting POLL_UPDATED event: %v", endpoint, err)
+	}
+
+	results, err := apiConfig.pollResults(r.Context(), poll, userID)
+	if err != nil {
+		log.Printf("[%s]: error fetching poll results: %v", endpoint, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Poll:        results,
+		AccessToken: newAccessToken,
+	})
+}
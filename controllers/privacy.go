@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+// audiences for the privacy settings of a user
+const (
+	privacyEveryone = "everyone"
+	privacyContacts = "contacts"
+	privacyNobody   = "nobody"
+)
+
+/*
+isVisibleTo reports whether the information of the owner protected by a privacy setting with the
+given audience can be seen by the viewer. The owner can always see its own information and
+the users blocked by the owner can never see it
+*/
+func (apiConfig *ApiConfig) isVisibleTo(ctx context.Context, audience string, ownerID, viewerID uuid.UUID) (bool, error) {
+	if ownerID == viewerID {
+		return true, nil
+	}
+
+	// nothing is shared with the users blocked by the owner
+	blocked, err := apiConfig.DB.IsUserBlocked(ctx, database.IsUserBlockedParams{
+		UserID:        ownerID,
+		BlockedUserID: viewerID,
+	})
+	if err != nil || blocked {
+		return false, err
+	}
+
+	switch audience {
+	case privacyEveryone:
+		return true, nil
+	case privacyContacts:
+		return apiConfig.DB.IsUserContact(ctx, database.IsUserContactParams{
+			UserID:    ownerID,
+			ContactID: viewerID,
+		})
+	default:
+		return false, nil
+	}
+}
+
+/*
+hideReadReceipts marks the messages sent by the viewer to the receiver as unread when the
+receiver does not share read receipts with the viewer. A copy of messages is returned so
+that the cached messages remain untouched
+*/
+func (apiConfig *ApiConfig) hideReadReceipts(ctx context.Context, messages []database.Message, viewerID, receiverID uuid.UUID) ([]database.Message, error) {
+	privacySettings, err := apiConfig.DB.GetUserPrivacySettings(ctx, receiverID)
+	if err != nil {
+		return nil, err
+	}
+
+	visible, err := apiConfig.isVisibleTo(ctx, privacySettings.ReadReceiptsPrivacy, receiverID, viewerID)
+	if err != nil || visible {
+		return messages, err
+	}
+
+	filtered := make([]database.Message, len(messages))
+	copy(filtered, messages)
+	for index := range filtered {
+		if filtered[index].SenderID == viewerID {
+			filtered[index].Read = false
+		}
+	}
+
+	return filtered, nil
+}
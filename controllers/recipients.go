@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/cache"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+// a conversation is either between two users or inside a group
+type conversation struct {
+	ReceiverID uuid.UUID
+	GroupID    uuid.UUID
+}
+
+// conversationCacheKey returns the key of the conversation of the user in the message cache
+func conversationCacheKey(userID uuid.UUID, c conversation) string {
+	if c.GroupID != uuid.Nil {
+		return c.GroupID.String()
+	}
+
+	return cache.ConversationKey(userID, c.ReceiverID)
+}
+
+// messageCacheKey returns the key of the conversation of the message in the message cache
+func messageCacheKey(message database.Message) string {
+	return conversationCacheKey(message.SenderID, conversation{
+		ReceiverID: message.RecieverID.UUID,
+		GroupID:    message.GroupID.UUID,
+	})
+}
+
+/*
+conversationRecipients returns the ids of the users to whom the events of a conversation
+will be pushed. For a one-to-one conversation it is the receiver and for a group conversation
+it is every member of the group
+*/
+func (apiConfig *ApiConfig) conversationRecipients(ctx context.Context, c conversation) ([]uuid.UUID, error) {
+	if c.GroupID != uuid.Nil {
+		return apiConfig.groupRecipients(ctx, c.GroupID)
+	}
+
+	if c.ReceiverID != uuid.Nil {
+		return []uuid.UUID{c.ReceiverID}, nil
+	}
+
+	return nil, nil
+}
+
+// groupRecipients returns the ids of all the members of the group
+func (apiConfig *ApiConfig) groupRecipients(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	return apiConfig.DB.GetGroupMembersIDs(ctx, groupID)
+}
+
+/*
+memberEventRecipients returns the ids of the users who are told about changes in the members
+of the group. Subscribers of a channel can not see each other so only its owner and admins are told
+*/
+func (apiConfig *ApiConfig) memberEventRecipients(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	group, err := apiConfig.DB.GetGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if group.Type != groupTypeChannel {
+		return apiConfig.groupRecipients(ctx, groupID)
+	}
+
+	return apiConfig.groupAdminRecipients(ctx, groupID)
+}
+
+// groupAdminRecipients returns the ids of the owner and the admins of the group
+func (apiConfig *ApiConfig) groupAdminRecipients(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	admins, err := apiConfig.DB.GetGroupAdmins(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make([]uuid.UUID, 0, len(admins))
+	for _, admin := range admins {
+		recipients = append(recipients, admin.ID)
+	}
+
+	return recipients, nil
+}
+
+// mutedRecipients returns the ids of the receivers who muted the conversation in which the sender posted
+func (apiConfig *ApiConfig) mutedRecipients(ctx context.Context, senderID uuid.UUID, c conversation) ([]uuid.UUID, error) {
+	if c.GroupID != uuid.Nil {
+		return apiConfig.DB.GetMutedGroupMembers(ctx, uuid.NullUUID{
+			UUID:  c.GroupID,
+			Valid: true,
+		})
+	}
+
+	muted, err := apiConfig.DB.IsConversationMuted(ctx, database.IsConversationMutedParams{
+		UserID: c.ReceiverID,
+		OtherUserID: uuid.NullUUID{
+			UUID:  senderID,
+			Valid: true,
+		},
+	})
+	if err != nil || !muted {
+		return nil, err
+	}
+
+	return []uuid.UUID{c.ReceiverID}, nil
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+const (
+	minScheduleDelay = time.Minute          // a message can be scheduled at least this far in the future
+	maxScheduleDelay = 365 * 24 * time.Hour // a message can be scheduled at most this far in the future
+)
+
+// scheduled message sent to the client
+type scheduledMessage struct {
+	ID          uuid.UUID `json:"id"`
+	Description string    `json:"description"`
+	ReceiverID  uuid.UUID `json:"receiver_id"`
+	GroupID     uuid.UUID `json:"group_id"`
+	SendAt      string    `json:"send_at"`
+	Status      string    `json:"status"`
+	CreatedAt   string    `json:"created_at"`
+	UpdatedAt   string    `json:"updated_at"`
+}
+
+func newScheduledMessage(message database.ScheduledMessage) scheduledMessage {
+	return scheduledMessage{
+		ID:          message.ID,
+		Description: message.Description,
+		ReceiverID:  message.ReceiverID.UUID,
+		GroupID:     message.GroupID.UUID,
+		SendAt:      message.SendAt.Format(time.RFC1123),
+		Status:      message.Status,
+		CreatedAt:   message.CreatedAt.Format(time.RFC1123),
+		UpdatedAt:   message.UpdatedAt.Format(time.RFC1123),
+	}
+}
+
+// validateSendAt checks if the message can be scheduled at sendAt
+func validateSendAt(sendAt time.Time) bool {
+	delay := time.Until(sendAt)
+	return delay >= minScheduleDelay && delay <= maxScheduleDelay
+}
+
+/*
+endpoint: /api/v1/message/schedule
+
+schedules a message to the receiver_id or the group_id which the message scheduler sends at send_at.
+send_at is an RFC 3339 timestamp between a minute and a year from now
+*/
+func (apiConfig *ApiConfig) HandleScheduleMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		Description string    `json:"description"`
+		ReceiverID  uuid.UUID `json:"receiver_id"`
+		GroupID     uuid.UUID `json:"group_id"`
+		SendAt      time.Time `json:"send_at"`
+	}
+
+	type response struct {
+		scheduledMessage
+		AccessToken string `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if (params.ReceiverID == uuid.Nil) == (params.GroupID == uuid.Nil) {
+		log.Printf("[/api/v1/message/schedule]: invalid message body")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "message needs either a receiver id or a group id")
+		return
+	}
+
+	if len(params.Description) == 0 {
+		log.Printf("[/api/v1/message/schedule]: empty message description")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty message description")
+		return
+	}
+
+	if !validateSendAt(params.SendAt) {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "send_at must be between a minute and a year from now")
+		return
+	}
+
+	// checking if the requesting user can post in the conversation, it is checked again when the message is sent
+	if err = apiConfig.authorizeMessage(r.Context(), userID, conversation{
+		ReceiverID: params.ReceiverID,
+		GroupID:    params.GroupID,
+	}); err != nil {
+		log.Printf("[/api/v1/message/schedule]: requesting user %s is not allowed to post: %v", userID, err)
+		respondWithMessageAuthorizationError(w, err)
+		return
+	}
+
+	message, err := apiConfig.DB.CreateScheduledMessage(r.Context(), database.CreateScheduledMessageParams{
+		SenderID: userID,
+		ReceiverID: uuid.NullUUID{
+			UUID:  params.ReceiverID,
+			Valid: params.ReceiverID != uuid.Nil,
+		},
+		GroupID: uuid.NullUUID{
+			UUID:  params.GroupID,
+			Valid: params.GroupID != uuid.Nil,
+		},
+		Description: params.Description,
+		SendAt:      params.SendAt,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule]: error scheduling message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusCreated, response{
+		scheduledMessage: newScheduledMessage(message),
+		AccessToken:      newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/message/scheduled
+
+returns the pending and the failed scheduled messages of the requesting user, earliest first.
+A scheduled message fails when the user is no longer allowed to post in the conversation at send time
+*/
+func (apiConfig *ApiConfig) HandleGetScheduledMessages(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type response struct {
+		ScheduledMessages []scheduledMessage `json:"scheduled_messages"`
+		AccessToken       string             `json:"access_token"`
+	}
+
+	messages, err := apiConfig.DB.GetScheduledMessages(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/message/scheduled]: error fetching scheduled messages: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	scheduledMessages := []scheduledMessage{}
+	for _, message := range messages {
+		scheduledMessages = append(scheduledMessages, newScheduledMessage(message))
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		ScheduledMessages: scheduledMessages,
+		AccessToken:       newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/message/schedule/update
+func (apiConfig *ApiConfig) HandleUpdateScheduledMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ID          uuid.UUID `json:"id"`
+		Description string    `json:"description"`
+		SendAt      time.Time `json:"send_at"`
+	}
+
+	type response struct {
+		scheduledMessage
+		AccessToken string `json:"access_token"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule/update]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating request body
+	if params.ID == uuid.Nil {
+		log.Printf("[/api/v1/message/schedule/update]: empty scheduled message id")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty scheduled message id")
+		return
+	}
+
+	if len(params.Description) == 0 {
+		log.Printf("[/api/v1/message/schedule/update]: empty message description")
+		utility.RespondWithError(w, http.StatusNotAcceptable, "empty message description")
+		return
+	}
+
+	if !validateSendAt(params.SendAt) {
+		utility.RespondWithError(w, http.StatusNotAcceptable, "send_at must be between a minute and a year from now")
+		return
+	}
+
+	// only pending messages can be edited, a message being sent is locked until it is marked sent
+	message, err := apiConfig.DB.UpdateScheduledMessage(r.Context(), database.UpdateScheduledMessageParams{
+		Description: params.Description,
+		SendAt:      params.SendAt,
+		ID:          params.ID,
+		SenderID:    userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule/update]: error updating scheduled message: %v", err)
+		utility.RespondWithError(w, http.StatusNotFound, "scheduled message not found")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		scheduledMessage: newScheduledMessage(message),
+		AccessToken:      newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/message/schedule/cancel
+func (apiConfig *ApiConfig) HandleCancelScheduledMessage(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		ID uuid.UUID `json:"id"`
+	}
+
+	// extracting request body
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule/cancel]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cancelled, err := apiConfig.DB.CancelScheduledMessage(r.Context(), database.CancelScheduledMessageParams{
+		ID:       params.ID,
+		SenderID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/message/schedule/cancel]: error cancelling scheduled message: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if cancelled == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "scheduled message not found")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
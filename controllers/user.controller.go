@@ -1,13 +1,19 @@
 package controllers
 
 import (
+	"cmp"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
 	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/services"
 	"github.com/harshvardha/TerTerChat/utility"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -57,7 +63,76 @@ func (apiConfig *ApiConfig) UpdateUsername(w http.ResponseWriter, r *http.Reques
 	})
 }
 
-// endpoint: /api/v1/users/update/phonenumber
+const (
+	phonenumberChangeExpiresAfter = 10 * time.Minute // same as the validity of otp sent to the new phonenumber
+)
+
+/*
+endpoint: /api/v1/users/update/phonenumber/otp/send
+First phase of changing the phonenumber. The otp is sent to the new phonenumber
+and the requested change is saved until it is confirmed or expires
+*/
+func (apiConfig *ApiConfig) HandleSendOTPToNewPhonenumber(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	// extracting new phonenumber from request body
+	decoder := json.NewDecoder(r.Body)
+	params := phonenumber{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating phonenumber
+	if err = apiConfig.DataValidator.Var(params.Phonenumber, "required,phonenumber"); err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: error validating phonenumber: %v", err)
+		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	// new phonenumber must not belong to any registered user
+	if _, err = apiConfig.DB.DoesUserExist(r.Context(), params.Phonenumber); err == nil {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: phonenumber %s already in use", params.Phonenumber)
+		utility.RespondWithError(w, http.StatusConflict, "phonenumber already in use")
+		return
+	}
+
+	// checking if sending otp to new phonenumber is allowed or not
+	if ok, err := apiConfig.TwilioConfig.IsResendAllowed(params.Phonenumber); !ok {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// sending otp to new phonenumber
+	if err = apiConfig.TwilioConfig.SendOTP(params.Phonenumber); err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: error sending otp: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// saving the requested change so that only this user can confirm it
+	if err = apiConfig.DB.CreatePhonenumberChangeRequest(r.Context(), database.CreatePhonenumberChangeRequestParams{
+		UserID:         userID,
+		NewPhonenumber: params.Phonenumber,
+		ExpiresAt:      time.Now().UTC().Add(phonenumberChangeExpiresAfter),
+	}); err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber/otp/send]: error saving phonenumber change request: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/users/update/phonenumber
+Second phase of changing the phonenumber. After verifying the otp sent to the new
+phonenumber it is updated, live socket session is moved to the new phonenumber
+and the contacts of the user are notified
+*/
 func (apiConfig *ApiConfig) UpdatePhonenumber(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
 	// extracting new phonenumber and otp from request body
 	type request struct {
@@ -65,46 +140,111 @@ func (apiConfig *ApiConfig) UpdatePhonenumber(w http.ResponseWriter, r *http.Req
 		OTP         string `json:"otp"`
 	}
 
+	type response struct {
+		Phonenumber string `json:"phonenumber"`
+		AccessToken string `json:"access_token"`
+	}
+
 	decoder := json.NewDecoder(r.Body)
 	params := request{}
 	err := decoder.Decode(&params)
 	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: error decoding request body: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// validating phonenumber
-	if err = apiConfig.DataValidator.Var(params.Phonenumber, "required,phonenumber"); err != nil {
-		log.Printf("[/api/v1/users/update/phonenumber]: error validating phonenumber: %v", err)
-		utility.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+	// fetching the pending phonenumber change
+	newPhonenumber, err := apiConfig.DB.GetPhonenumberChangeRequest(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: no pending phonenumber change for user %s: %v", userID.String(), err)
+		utility.RespondWithError(w, http.StatusNotFound, "no pending phonenumber change")
+		return
+	}
+
+	if len(params.Phonenumber) > 0 && params.Phonenumber != newPhonenumber {
+		log.Printf("[/api/v1/users/update/phonenumber]: phonenumber does not match the pending change")
+		utility.RespondWithError(w, http.StatusBadRequest, "phonenumber does not match the pending change")
 		return
 	}
 
 	// validating otp
-	if err = apiConfig.TwilioConfig.VerifyOTP(params.Phonenumber, params.OTP); err != nil {
+	if err = apiConfig.TwilioConfig.VerifyOTP(newPhonenumber, params.OTP); err != nil {
 		log.Printf("[/api/v1/users/update/phonenumber]: error validating otp: %v", err)
 		utility.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// updating phonenumber
-	if err = apiConfig.DB.UpdatePhonenumber(r.Context(), database.UpdatePhonenumberParams{
-		Phonenumber: params.Phonenumber,
-		ID:          userID,
+	// fetching the current user information
+	user, err := apiConfig.DB.GetUserById(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: user with id %s does not exist", userID.String())
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// updating phonenumber and removing the pending change atomically
+	tx, err := apiConfig.DBConnection.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: error starting transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiConfig.DB.WithTx(tx)
+
+	if err = qtx.UpdatePhonenumber(r.Context(), database.UpdatePhonenumberParams{
+		Phonenumber:     newPhonenumber,
+		PhonenumberHash: apiConfig.hashPhonenumber(newPhonenumber),
+		ID:              userID,
 	}); err != nil {
+		// unique constraint on users.phonenumber fails if someone registered it meanwhile
 		log.Printf("[/api/v1/users/update/phonenumber]: error updating phonenumber: %v", err)
+		utility.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err = qtx.RemovePhonenumberChangeRequest(r.Context(), userID); err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: error removing phonenumber change request: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// deleting refresh token
-	if err = apiConfig.DB.RemoveRefreshToken(r.Context(), userID); err != nil {
+	// deleting refresh token so that the sessions created with the old phonenumber have to login again
+	if err = qtx.RemoveRefreshToken(r.Context(), userID); err != nil {
 		log.Printf("[/api/v1/users/update/phonenumber]: error deleting refresh token: %v", err)
 		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+	if err = tx.Commit(); err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: error committing transaction: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// emitting PHONENUMBER_CHANGED event to the saved contacts of the user, blocked users in either direction are left out
+	contacts, err := apiConfig.DB.GetUnblockedContactsIDs(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/phonenumber]: error fetching contacts of user: %v", err)
+	}
+	if len(contacts) > 0 {
+		apiConfig.UserEventEmitterChannel <- eventhandlers.UserEvent{
+			Name: eventhandlers.PHONENUMBER_CHANGED,
+			User: eventhandlers.User{
+				ID:             userID,
+				Username:       user.Username,
+				Phonenumber:    newPhonenumber,
+				OldPhonenumber: user.Phonenumber,
+			},
+			UserIDs:             contacts,
+			NotificationService: apiConfig.NotificationService,
+			EmittedAt:           time.Now(),
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Phonenumber: newPhonenumber,
 		AccessToken: newAccessToken,
 	})
 }
@@ -184,9 +324,15 @@ func (apiConfig *ApiConfig) GetUserByPhonenumber(w http.ResponseWriter, r *http.
 	}
 
 	type response struct {
-		Username    string `json:"username"`
-		CreatedAt   string `json:"created_at"`
-		AccessToken string `json:"access_token"`
+		ID          uuid.UUID `json:"id"`
+		Username    string    `json:"username"`
+		DisplayName string    `json:"display_name,omitempty"`
+		Status      string    `json:"status,omitempty"`
+		HasAvatar   bool      `json:"has_avatar"`
+		Online      bool      `json:"online,omitempty"`
+		LastSeen    string    `json:"last_seen,omitempty"`
+		CreatedAt   string    `json:"created_at"`
+		AccessToken string    `json:"access_token"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -206,20 +352,524 @@ func (apiConfig *ApiConfig) GetUserByPhonenumber(w http.ResponseWriter, r *http.
 		return
 	}
 
+	// users who blocked the requesting user are not visible to it
+	blocked, err := apiConfig.DB.IsUserBlocked(r.Context(), database.IsUserBlockedParams{
+		UserID:        userInfo.ID,
+		BlockedUserID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/user/get]: error checking blocked users: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if blocked {
+		utility.RespondWithError(w, http.StatusNotFound, "user not found")
+		return
+	}
+
+	profile, err := apiConfig.DB.GetUserProfile(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("[/api/v1/user/get]: error fetching profile of user %s: %v", userInfo.ID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	userProfile := response{
+		ID:          profile.ID,
+		Username:    profile.Username,
+		DisplayName: profile.DisplayName.String,
+		Status:      profile.Status.String,
+		CreatedAt:   profile.CreatedAt.Format(time.RFC1123),
+		AccessToken: newAccessToken,
+	}
+
+	// sharing profile photo and presence only if the privacy settings of the user allow it
+	if profile.AvatarKey.Valid {
+		userProfile.HasAvatar, err = apiConfig.isVisibleTo(r.Context(), profile.ProfilePhotoPrivacy, profile.ID, userID)
+		if err != nil {
+			log.Printf("[/api/v1/user/get]: error checking profile photo privacy: %v", err)
+			utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	lastSeenVisible, err := apiConfig.isVisibleTo(r.Context(), profile.LastSeenPrivacy, profile.ID, userID)
+	if err != nil {
+		log.Printf("[/api/v1/user/get]: error checking last seen privacy: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if lastSeenVisible {
+		userProfile.Online = apiConfig.NotificationService.IsUserOnline(profile.ID)
+		if !userProfile.Online && profile.LastAvailable.Valid {
+			userProfile.LastSeen = profile.LastAvailable.Time.Format(time.RFC1123)
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, userProfile)
+}
+
+// endpoint: /api/v1/users/update/profile
+func (apiConfig *ApiConfig) UpdateProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		DisplayName string `json:"display_name"`
+		Status      string `json:"status"`
+	}
+
+	type response struct {
+		DisplayName string `json:"display_name"`
+		Status      string `json:"status"`
+		UpdatedAt   string `json:"updated_at"`
+		AccessToken string `json:"access_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/profile]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// validating display name and status
+	params.DisplayName = strings.TrimSpace(params.DisplayName)
+	params.Status = strings.TrimSpace(params.Status)
+	if err = apiConfig.DataValidator.Var(params.DisplayName, "max=50"); err != nil {
+		log.Printf("[/api/v1/users/update/profile]: invalid display name: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, "display name can have at most 50 characters")
+		return
+	}
+
+	if err = apiConfig.DataValidator.Var(params.Status, "max=140"); err != nil {
+		log.Printf("[/api/v1/users/update/profile]: invalid status: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, "status can have at most 140 characters")
+		return
+	}
+
+	// empty values clear the display name and status
+	profile, err := apiConfig.DB.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		DisplayName: sql.NullString{
+			String: params.DisplayName,
+			Valid:  params.DisplayName != "",
+		},
+		Status: sql.NullString{
+			String: params.Status,
+			Valid:  params.Status != "",
+		},
+		ID: userID,
+	})
+	if err != nil {
+		log.Printf("[/api/v1/users/update/profile]: error updating profile: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		DisplayName: profile.DisplayName.String,
+		Status:      profile.Status.String,
+		UpdatedAt:   profile.UpdatedAt.Format(time.RFC1123),
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/users/update/privacy
+
+every setting accepts one of everyone, contacts or nobody and the settings which are
+not sent in the request body remain unchanged
+*/
+func (apiConfig *ApiConfig) UpdatePrivacySettings(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type privacySettings struct {
+		LastSeen     string `json:"last_seen" validate:"omitempty,oneof=everyone contacts nobody"`
+		ReadReceipts string `json:"read_receipts" validate:"omitempty,oneof=everyone contacts nobody"`
+		ProfilePhoto string `json:"profile_photo" validate:"omitempty,oneof=everyone contacts nobody"`
+	}
+
+	type response struct {
+		privacySettings
+		AccessToken string `json:"access_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := privacySettings{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/privacy]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.DataValidator.Struct(&params); err != nil {
+		log.Printf("[/api/v1/users/update/privacy]: invalid privacy settings: %v", err)
+		utility.RespondWithError(w, http.StatusBadRequest, "privacy settings can be one of everyone, contacts or nobody")
+		return
+	}
+
+	// keeping the current value of settings which are not sent
+	current, err := apiConfig.DB.GetUserPrivacySettings(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/privacy]: user with id %s does not exist", userID.String())
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	updateParams := database.UpdateUserPrivacySettingsParams{
+		LastSeenPrivacy:     cmp.Or(params.LastSeen, current.LastSeenPrivacy),
+		ReadReceiptsPrivacy: cmp.Or(params.ReadReceipts, current.ReadReceiptsPrivacy),
+		ProfilePhotoPrivacy: cmp.Or(params.ProfilePhoto, current.ProfilePhotoPrivacy),
+		ID:                  userID,
+	}
+	updated, err := apiConfig.DB.UpdateUserPrivacySettings(r.Context(), updateParams)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/privacy]: error updating privacy settings: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
 	utility.RespondWithJson(w, http.StatusOK, response{
-		Username:    userInfo.Username,
-		CreatedAt:   userInfo.CreatedAt.Format(time.RFC1123),
+		privacySettings: privacySettings{
+			LastSeen:     updated.LastSeenPrivacy,
+			ReadReceipts: updated.ReadReceiptsPrivacy,
+			ProfilePhoto: updated.ProfilePhotoPrivacy,
+		},
 		AccessToken: newAccessToken,
 	})
 }
 
-// endpoint: /api/v1/users/remove
+/*
+endpoint: /api/v1/users/update/avatar
+
+the avatar is sent as multipart form file with the field name avatar and is saved
+in the attachments store replacing the previous avatar of the user
+*/
+func (apiConfig *ApiConfig) UpdateAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	avatar, file, ok := readAvatarUpload(w, r, "/api/v1/users/update/avatar")
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	profile, err := apiConfig.DB.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/update/avatar]: user with id %s does not exist", userID.String())
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// saving new avatar
+	avatarKey := "avatars/" + userID.String() + "/" + uuid.NewString()
+	if err = apiConfig.Attachments.Put(r.Context(), avatarKey, avatar); err != nil {
+		log.Printf("[/api/v1/users/update/avatar]: error saving avatar: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.DB.UpdateUserAvatar(r.Context(), database.UpdateUserAvatarParams{
+		AvatarKey: sql.NullString{
+			String: avatarKey,
+			Valid:  true,
+		},
+		ID: userID,
+	}); err != nil {
+		log.Printf("[/api/v1/users/update/avatar]: error updating avatar key: %v", err)
+		apiConfig.Attachments.Delete(r.Context(), avatarKey)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// removing previous avatar
+	if profile.AvatarKey.Valid {
+		if err = apiConfig.Attachments.Delete(r.Context(), profile.AvatarKey.String); err != nil {
+			log.Printf("[/api/v1/users/update/avatar]: error removing previous avatar: %v", err)
+		}
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/remove/avatar
+func (apiConfig *ApiConfig) RemoveAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	profile, err := apiConfig.DB.GetUserProfile(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/remove/avatar]: user with id %s does not exist", userID.String())
+		utility.RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if !profile.AvatarKey.Valid {
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+
+	if err = apiConfig.DB.UpdateUserAvatar(r.Context(), database.UpdateUserAvatarParams{
+		ID: userID,
+	}); err != nil {
+		log.Printf("[/api/v1/users/remove/avatar]: error removing avatar key: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err = apiConfig.Attachments.Delete(r.Context(), profile.AvatarKey.String); err != nil {
+		log.Printf("[/api/v1/users/remove/avatar]: error removing avatar: %v", err)
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/avatar
+func (apiConfig *ApiConfig) GetAvatar(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type request struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/avatar]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	profile, err := apiConfig.DB.GetUserProfile(r.Context(), params.UserID)
+	if err != nil {
+		log.Printf("[/api/v1/users/avatar]: user with id %s does not exist", params.UserID.String())
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+
+	// the avatar is reported as missing when the profile photo privacy does not allow the requesting user
+	visible, err := apiConfig.isVisibleTo(r.Context(), profile.ProfilePhotoPrivacy, profile.ID, userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/avatar]: error checking profile photo privacy: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !profile.AvatarKey.Valid || !visible {
+		utility.RespondWithError(w, http.StatusNotFound, "avatar not found")
+		return
+	}
+
+	apiConfig.serveAvatar(r.Context(), w, profile.AvatarKey.String, newAccessToken, "/api/v1/users/avatar")
+}
+
+/*
+endpoint: /api/v1/users/remove
+
+the account is not deleted right away instead its deletion is scheduled after a grace
+period during which the user can cancel it. The user has to confirm the deletion either
+with password or with otp sent on the registered phonenumber
+*/
 func (apiConfig *ApiConfig) RemoveUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
-	if err := apiConfig.DB.RemoveUser(r.Context(), userID); err != nil {
-		log.Printf("[/api/v1/users/remove]: error removing user account %v: %v", userID, err)
+	type request struct {
+		Password string `json:"password"`
+		OTP      string `json:"otp"`
+	}
+
+	type response struct {
+		DeletionScheduledAt string `json:"deletion_scheduled_at"`
+		AccessToken         string `json:"access_token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := request{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		log.Printf("[/api/v1/users/remove]: error decoding request body: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// confirming the deletion with password or otp
+	switch {
+	case params.Password != "":
+		hashedPassword, err := apiConfig.DB.GetUserPasswordByID(r.Context(), userID)
+		if err != nil {
+			log.Printf("[/api/v1/users/remove]: user with id %s does not exist", userID.String())
+			utility.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		if err = bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(params.Password)); err != nil {
+			log.Printf("[/api/v1/users/remove]: invalid password for user %s", userID.String())
+			utility.RespondWithError(w, http.StatusUnauthorized, "invalid password")
+			return
+		}
+	case params.OTP != "":
+		user, err := apiConfig.DB.GetUserById(r.Context(), userID)
+		if err != nil {
+			log.Printf("[/api/v1/users/remove]: user with id %s does not exist", userID.String())
+			utility.RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		if err = apiConfig.TwilioConfig.VerifyOTP(user.Phonenumber, params.OTP); err != nil {
+			log.Printf("[/api/v1/users/remove]: error validating otp: %v", err)
+			utility.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	default:
+		utility.RespondWithError(w, http.StatusBadRequest, "password or otp is required to delete account")
+		return
+	}
+
+	// scheduling the deletion after grace period
+	deletionScheduledAt := time.Now().UTC().Add(services.AccountDeletionGracePeriod)
+	if err = apiConfig.DB.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		DeletionScheduledAt: sql.NullTime{
+			Time:  deletionScheduledAt,
+			Valid: true,
+		},
+		ID: userID,
+	}); err != nil {
+		log.Printf("[/api/v1/users/remove]: error scheduling deletion of user account %v: %v", userID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, response{
+		DeletionScheduledAt: deletionScheduledAt.Format(time.RFC1123),
+		AccessToken:         newAccessToken,
+	})
+}
+
+// endpoint: /api/v1/users/remove/cancel
+func (apiConfig *ApiConfig) CancelRemoveUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	cancelled, err := apiConfig.DB.CancelUserDeletion(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/remove/cancel]: error cancelling deletion of user account %v: %v", userID, err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if cancelled == 0 {
+		utility.RespondWithError(w, http.StatusNotFound, "account deletion is not scheduled")
+		return
+	}
+
+	utility.RespondWithJson(w, http.StatusOK, EmptyResponse{
+		AccessToken: newAccessToken,
+	})
+}
+
+/*
+endpoint: /api/v1/users/export
+
+exports the profile of the user along with the messages sent or received by the user and
+the groups the user is member of as a downloadable json file
+*/
+func (apiConfig *ApiConfig) ExportUserData(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+	type profile struct {
+		ID                  uuid.UUID `json:"id"`
+		Phonenumber         string    `json:"phonenumber"`
+		Username            string    `json:"username"`
+		LastAvailable       string    `json:"last_available,omitempty"`
+		DeletionScheduledAt string    `json:"deletion_scheduled_at,omitempty"`
+		CreatedAt           string    `json:"created_at"`
+		UpdatedAt           string    `json:"updated_at"`
+	}
+
+	type message struct {
+		ID          uuid.UUID  `json:"id"`
+		Description string     `json:"description"`
+		SenderID    uuid.UUID  `json:"sender_id"`
+		ReceiverID  *uuid.UUID `json:"receiver_id,omitempty"`
+		GroupID     *uuid.UUID `json:"group_id,omitempty"`
+		CreatedAt   string     `json:"created_at"`
+		UpdatedAt   string     `json:"updated_at"`
+	}
+
+	type group struct {
+		ID       uuid.UUID `json:"id"`
+		Name     string    `json:"name"`
+		JoinedAt string    `json:"joined_at"`
+	}
+
+	type response struct {
+		Profile     profile   `json:"profile"`
+		Messages    []message `json:"messages"`
+		Groups      []group   `json:"groups"`
+		ExportedAt  string    `json:"exported_at"`
+		AccessToken string    `json:"access_token"`
+	}
+
+	// fetching profile of the user
+	user, err := apiConfig.DB.GetUserProfileForExport(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/export]: user with id %s does not exist", userID.String())
 		utility.RespondWithError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	utility.RespondWithJson(w, http.StatusOK, uuid.Nil)
+	userProfile := profile{
+		ID:          user.ID,
+		Phonenumber: user.Phonenumber,
+		Username:    user.Username,
+		CreatedAt:   user.CreatedAt.Format(time.RFC1123),
+		UpdatedAt:   user.UpdatedAt.Format(time.RFC1123),
+	}
+	if user.LastAvailable.Valid {
+		userProfile.LastAvailable = user.LastAvailable.Time.Format(time.RFC1123)
+	}
+	if user.DeletionScheduledAt.Valid {
+		userProfile.DeletionScheduledAt = user.DeletionScheduledAt.Time.Format(time.RFC1123)
+	}
+
+	// fetching messages sent or received by the user
+	userMessages, err := apiConfig.DB.GetUserMessagesForExport(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/export]: error fetching messages of user: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	messages := make([]message, 0, len(userMessages))
+	for _, userMessage := range userMessages {
+		exportedMessage := message{
+			ID:          userMessage.ID,
+			Description: userMessage.Description,
+			SenderID:    userMessage.SenderID,
+			CreatedAt:   userMessage.CreatedAt.Format(time.RFC1123),
+			UpdatedAt:   userMessage.UpdatedAt.Format(time.RFC1123),
+		}
+		if userMessage.RecieverID.Valid {
+			exportedMessage.ReceiverID = &userMessage.RecieverID.UUID
+		}
+		if userMessage.GroupID.Valid {
+			exportedMessage.GroupID = &userMessage.GroupID.UUID
+		}
+		messages = append(messages, exportedMessage)
+	}
+
+	// fetching groups of the user
+	userGroups, err := apiConfig.DB.GetUserGroupsForExport(r.Context(), userID)
+	if err != nil {
+		log.Printf("[/api/v1/users/export]: error fetching groups of user: %v", err)
+		utility.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	groups := make([]group, 0, len(userGroups))
+	for _, userGroup := range userGroups {
+		groups = append(groups, group{
+			ID:       userGroup.ID,
+			Name:     userGroup.Name,
+			JoinedAt: userGroup.JoinedAt.Format(time.RFC1123),
+		})
+	}
+
+	// making the response downloadable as a file
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"terterchat-export-%s.json\"", userID.String()))
+	utility.RespondWithJson(w, http.StatusOK, response{
+		Profile:     userProfile,
+		Messages:    messages,
+		Groups:      groups,
+		ExportedAt:  time.Now().Format(time.RFC1123),
+		AccessToken: newAccessToken,
+	})
 }
@@ -6,13 +6,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/harshvardha/TerTerChat/internal/database"
 	"github.com/harshvardha/TerTerChat/internal/services"
 )
 
 type ConnectionEvent struct {
 	Name                string
-	Phonenumber         string
+	UserID              uuid.UUID
 	ConnectionInstance  net.Conn
 	NotificationService *services.Notification
 	DB                  *database.Queries
@@ -32,11 +33,11 @@ func ConnectionEventHandler(event chan ConnectionEvent, wg *sync.WaitGroup) {
 	for connectionEvent := range event {
 		switch connectionEvent.Name {
 		case CONNECTED:
-			go connectionEvent.NotificationService.AddUserConnection(connectionEvent.Phonenumber, connectionEvent.ConnectionInstance)
+			go connectionEvent.NotificationService.AddUserConnection(connectionEvent.UserID, connectionEvent.ConnectionInstance)
 		case DISCONNECTED:
-			connectionEvent.NotificationService.RemoveUserConnection(connectionEvent.Phonenumber, connectionEvent.DB)
+			connectionEvent.NotificationService.RemoveUserConnection(connectionEvent.UserID, connectionEvent.ConnectionInstance, connectionEvent.DB)
 		}
 	}
 
-	log.Printf("[EVENT]: ConnectionEventHandler stopped for %s because event channel was closed, [TIME]: %s", (<-event).Phonenumber, time.Now().Format(time.RFC1123))
+	log.Printf("[EVENT]: ConnectionEventHandler stopped because event channel was closed, [TIME]: %s", time.Now().Format(time.RFC1123))
 }
@@ -13,15 +13,18 @@ import (
 // information about the group that will be provided by event emitted
 type Group struct {
 	ID          uuid.UUID
+	UserID      uuid.UUID
 	Username    string
 	Phonenumber string
+	Role        string
+	Changes     map[string]any
 }
 
 // event information
 type GroupEvent struct {
 	Name                string
 	Group               Group
-	Phonenumbers        []string
+	UserIDs             []uuid.UUID
 	NotificationService *services.Notification
 	EmittedAt           time.Time
 }
@@ -32,6 +35,11 @@ const (
 	REMOVE_USER_FROM_GROUP = "REMOVE_USER_FROM_GROUP"
 	MADE_ADMIN             = "MADE_ADMIN"
 	REMOVE_ADMIN           = "REMOVE_ADMIN"
+	ROLE_CHANGED           = "ROLE_CHANGED"
+	PENDING_JOIN_REQUEST   = "PENDING_JOIN_REQUEST"
+	LEFT_GROUP             = "LEFT_GROUP"
+	OWNERSHIP_TRANSFERRED  = "OWNERSHIP_TRANSFERRED"
+	GROUP_UPDATED          = "GROUP_UPDATED"
 )
 
 // what action had been executed server side will sent to client
@@ -80,8 +88,8 @@ func GroupActionsEventHandler(event chan GroupEvent, wg *sync.WaitGroup) {
 
 		copy(response[offset:], action)
 
-		groupEvent.NotificationService.PushNotification(groupEvent.Phonenumbers, action)
+		groupEvent.NotificationService.PushNotification(groupEvent.UserIDs, response)
 	}
 
-	log.Printf("[GROUP_EVENT_HANDLER]: stopped for %v because event channel was closed", (<-event).Phonenumbers)
+	log.Printf("[GROUP_EVENT_HANDLER]: stopped because event channel was closed")
 }
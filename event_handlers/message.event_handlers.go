@@ -19,26 +19,73 @@ type Message struct {
 	SenderUsername  string
 	GroupMemberID   uuid.UUID
 	GroupMemberName string
+	PinnedBy        uuid.UUID
+	Kind            string
+	Content         json.RawMessage
+	EditCount       int32
+	ForEveryone     bool
+	Forwarded       bool
+	ForwardCount    int32
+	Poll            *Poll
+	LinkPreview     *LinkPreview
+	Timer           int32
+	TimerStartsOn   string
 	CreatedAt       string
 	UpdatedAt       string
 }
 
-// Message data for NEW_MESSAGE | EDIT_MESSAGE event
+// Poll data with aggregated results, the voters are listed only for the polls which are not anonymous
+type Poll struct {
+	ID             uuid.UUID    `json:"id"`
+	Question       string       `json:"question"`
+	MultipleChoice bool         `json:"multiple_choice"`
+	Anonymous      bool         `json:"anonymous"`
+	ClosesAt       string       `json:"closes_at,omitempty"`
+	Closed         bool         `json:"closed"`
+	TotalVotes     int64        `json:"total_votes"`
+	Options        []PollOption `json:"options"`
+	MyVotes        []uuid.UUID  `json:"my_votes,omitempty"` // only set in the responses to the requesting user
+}
+
+type PollOption struct {
+	ID     uuid.UUID   `json:"id"`
+	Text   string      `json:"text"`
+	Votes  int64       `json:"votes"`
+	Voters []uuid.UUID `json:"voters,omitempty"`
+}
+
+// LinkPreview of the first link in a message
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// Message data for NEW_MESSAGE | MESSAGE_REQUEST | EDIT_MESSAGE event
 type newOrEditMessage struct {
-	ID             uuid.UUID `json:"id"`
-	GroupID        uuid.UUID `json:"group_id,omitempty"`
-	SenderID       uuid.UUID `json:"sender_id"`
-	SenderUsername string    `json:"sender_username,omitempty"`
-	Description    string    `json:"description"`
-	CreatedAt      string    `json:"created_at,omitempty"`
-	UpdatedAt      string    `json:"updated_at,omitempty"`
+	ID             uuid.UUID       `json:"id"`
+	GroupID        uuid.UUID       `json:"group_id,omitempty"`
+	SenderID       uuid.UUID       `json:"sender_id"`
+	SenderUsername string          `json:"sender_username,omitempty"`
+	Description    string          `json:"description"`
+	Kind           string          `json:"kind,omitempty"`
+	Content        json.RawMessage `json:"content,omitempty"`
+	Edited         bool            `json:"edited"`
+	EditCount      int32           `json:"edit_count"`
+	Forwarded      bool            `json:"forwarded"`
+	ForwardCount   int32           `json:"forward_count"`
+	Poll           *Poll           `json:"poll,omitempty"`
+	CreatedAt      string          `json:"created_at,omitempty"`
+	UpdatedAt      string          `json:"updated_at,omitempty"`
 }
 
 // Message data for DELETE_MESSAGE event
 type deleteMessage struct {
-	ID       uuid.UUID `json:"id"`
-	SenderID uuid.UUID `json:"sender_id"`
-	GroupID  uuid.UUID `json:"group_id,omitempty"`
+	ID          uuid.UUID `json:"id"`
+	SenderID    uuid.UUID `json:"sender_id"`
+	GroupID     uuid.UUID `json:"group_id,omitempty"`
+	ForEveryone bool      `json:"for_everyone"`
 }
 
 // Message data for MESSAGE_RECEIVED event
@@ -62,24 +109,97 @@ type markGroupMessageReadOrReceived struct {
 	GroupMemberUsername string    `json:"group_member_username"`
 }
 
+// Message data for MESSAGE_PINNED and MESSAGE_UNPINNED event
+type pinMessage struct {
+	ID       uuid.UUID `json:"id"`
+	SenderID uuid.UUID `json:"sender_id"`
+	GroupID  uuid.UUID `json:"group_id,omitempty"`
+	PinnedBy uuid.UUID `json:"pinned_by"`
+}
+
+// Message data for MESSAGE_TIMER_CHANGED event
+type messageTimer struct {
+	ChangedBy uuid.UUID `json:"changed_by"`
+	Duration  int32     `json:"duration"`
+	StartsOn  string    `json:"starts_on,omitempty"`
+}
+
+// Message data for POLL_UPDATED event
+type pollUpdated struct {
+	MessageID uuid.UUID `json:"message_id"`
+	GroupID   uuid.UUID `json:"group_id"`
+	Poll      *Poll     `json:"poll"`
+}
+
+// Message data for MESSAGE_PREVIEW_READY event
+type linkPreviewReady struct {
+	ID          uuid.UUID    `json:"id"`
+	GroupID     uuid.UUID    `json:"group_id,omitempty"`
+	SenderID    uuid.UUID    `json:"sender_id"`
+	LinkPreview *LinkPreview `json:"link_preview"`
+}
+
+// Message data for MENTIONED event
+type mention struct {
+	ID             uuid.UUID `json:"id"`
+	GroupID        uuid.UUID `json:"group_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	SenderUsername string    `json:"sender_username"`
+	Description    string    `json:"description"`
+	CreatedAt      string    `json:"created_at"`
+}
+
 const (
 	NEW_MESSAGE            = "NEW_MESSAGE"
+	MESSAGE_REQUEST        = "MESSAGE_REQUEST"
 	EDIT_MESSAGE           = "EDIT_MESSAGE"
 	DELETE_MESSAGE         = "DELETE_MESSAGE"
 	MESSAGE_RECEIVED       = "MARK_MESSAGE_RECEIVED"
 	MESSAGE_READ           = "MARK_MESSAGE_READ"
 	GROUP_MESSAGE_RECEIVED = "GROUP_MESSAGE_RECEIVED"
 	GROUP_MESSAGE_READ     = "GROUP_MESSAGE_READ"
+	MESSAGE_PINNED         = "MESSAGE_PINNED"
+	MESSAGE_UNPINNED       = "MESSAGE_UNPINNED"
+	MESSAGE_TIMER_CHANGED  = "MESSAGE_TIMER_CHANGED"
+	MENTIONED              = "MENTIONED"
+	POLL_UPDATED           = "POLL_UPDATED"
+	MESSAGE_PREVIEW_READY  = "MESSAGE_PREVIEW_READY"
 )
 
 type MessageEvent struct {
 	Name                string
-	Phonenumbers        []string
+	UserIDs             []uuid.UUID
+	MutedUserIDs        []uuid.UUID // recipients who muted the conversation, they get the event as a silent push
 	Message             Message
 	NotificationService *services.Notification
 	EmittedAt           time.Time
 }
 
+// pushMessageEvent pushes the response to the recipients of the event, silently to the ones who muted the conversation
+func pushMessageEvent(messageEvent MessageEvent, response []byte) {
+	if len(messageEvent.MutedUserIDs) == 0 {
+		messageEvent.NotificationService.PushNotification(messageEvent.UserIDs, response)
+		return
+	}
+
+	muted := make(map[uuid.UUID]bool, len(messageEvent.MutedUserIDs))
+	for _, userID := range messageEvent.MutedUserIDs {
+		muted[userID] = true
+	}
+
+	var recipients, mutedRecipients []uuid.UUID
+	for _, userID := range messageEvent.UserIDs {
+		if muted[userID] {
+			mutedRecipients = append(mutedRecipients, userID)
+		} else {
+			recipients = append(recipients, userID)
+		}
+	}
+
+	messageEvent.NotificationService.PushNotification(recipients, response)
+	messageEvent.NotificationService.PushSilentNotification(mutedRecipients, response)
+}
+
 /*
 This event handler will first check which event has been emitted then accordingly will
 create the response byte by using the respective instance of the response structs defined above
@@ -105,13 +225,18 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 		offset := 0 // offset will be used for sub-indexing the response byte slice when we create the final response
 
 		switch messageEvent.Name {
-		case NEW_MESSAGE:
+		case NEW_MESSAGE, MESSAGE_REQUEST:
 			msg, err := json.Marshal(newOrEditMessage{
 				ID:             messageEvent.Message.ID,
 				GroupID:        messageEvent.Message.GroupID,
 				SenderID:       messageEvent.Message.SenderID,
 				SenderUsername: messageEvent.Message.SenderUsername,
 				Description:    messageEvent.Message.Description,
+				Kind:           messageEvent.Message.Kind,
+				Content:        messageEvent.Message.Content,
+				Forwarded:      messageEvent.Message.Forwarded,
+				ForwardCount:   messageEvent.Message.ForwardCount,
+				Poll:           messageEvent.Message.Poll,
 				CreatedAt:      messageEvent.Message.CreatedAt,
 			})
 			if err != nil {
@@ -133,13 +258,15 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case EDIT_MESSAGE:
 			msg, err := json.Marshal(newOrEditMessage{
 				ID:          messageEvent.Message.ID,
 				GroupID:     messageEvent.Message.GroupID,
 				SenderID:    messageEvent.Message.SenderID,
 				Description: messageEvent.Message.Description,
+				Edited:      messageEvent.Message.EditCount > 0,
+				EditCount:   messageEvent.Message.EditCount,
 				UpdatedAt:   messageEvent.Message.UpdatedAt,
 			})
 			if err != nil {
@@ -161,12 +288,13 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case DELETE_MESSAGE:
 			msg, err := json.Marshal(deleteMessage{
-				ID:       messageEvent.Message.ID,
-				SenderID: messageEvent.Message.SenderID,
-				GroupID:  messageEvent.Message.GroupID,
+				ID:          messageEvent.Message.ID,
+				SenderID:    messageEvent.Message.SenderID,
+				GroupID:     messageEvent.Message.GroupID,
+				ForEveryone: messageEvent.Message.ForEveryone,
 			})
 			if err != nil {
 				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for delete_message: %v", err)
@@ -187,7 +315,7 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case MESSAGE_RECEIVED:
 			msg, err := json.Marshal(markMessageReceived{
 				ID:         messageEvent.Message.ID,
@@ -212,7 +340,7 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case MESSAGE_READ:
 			msg, err := json.Marshal(markMessageRead{
 				ID:         messageEvent.Message.ID,
@@ -238,7 +366,7 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case GROUP_MESSAGE_RECEIVED:
 			msg, err := json.Marshal(markGroupMessageReadOrReceived{
 				ID:      messageEvent.Message.ID,
@@ -261,7 +389,7 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message byte
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
 		case GROUP_MESSAGE_READ:
 			msg, err := json.Marshal(markGroupMessageReadOrReceived{
 				ID:      messageEvent.Message.ID,
@@ -286,10 +414,145 @@ func MessageEventHandler(event chan MessageEvent, wg *sync.WaitGroup) {
 			// copying the message
 			copy(response[offset:], msg)
 
-			messageEvent.NotificationService.PushNotification(messageEvent.Phonenumbers, response)
+			pushMessageEvent(messageEvent, response)
+		case MESSAGE_PINNED, MESSAGE_UNPINNED:
+			msg, err := json.Marshal(pinMessage{
+				ID:       messageEvent.Message.ID,
+				SenderID: messageEvent.Message.SenderID,
+				GroupID:  messageEvent.Message.GroupID,
+				PinnedBy: messageEvent.Message.PinnedBy,
+			})
+			if err != nil {
+				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for %s event: %v", messageEvent.Name, err)
+				continue
+			}
+
+			// final response
+			response := make([]byte, len(eventNameByte)+len(msg)+1)
+
+			// copying the event name into response
+			copy(response[offset:], eventNameByte)
+			offset += len(eventNameByte)
+
+			// copying the byte for separator
+			copy(response[offset:], separator)
+			offset++
+
+			// copying the message
+			copy(response[offset:], msg)
+
+			pushMessageEvent(messageEvent, response)
+		case MESSAGE_TIMER_CHANGED:
+			msg, err := json.Marshal(messageTimer{
+				ChangedBy: messageEvent.Message.SenderID,
+				Duration:  messageEvent.Message.Timer,
+				StartsOn:  messageEvent.Message.TimerStartsOn,
+			})
+			if err != nil {
+				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for MESSAGE_TIMER_CHANGED event: %v", err)
+				continue
+			}
+
+			// final response
+			response := make([]byte, len(eventNameByte)+len(msg)+1)
+
+			// copying the event name into response
+			copy(response[offset:], eventNameByte)
+			offset += len(eventNameByte)
+
+			// copying the byte for separator
+			copy(response[offset:], separator)
+			offset++
+
+			// copying the message
+			copy(response[offset:], msg)
+
+			pushMessageEvent(messageEvent, response)
+		case MENTIONED:
+			msg, err := json.Marshal(mention{
+				ID:             messageEvent.Message.ID,
+				GroupID:        messageEvent.Message.GroupID,
+				SenderID:       messageEvent.Message.SenderID,
+				SenderUsername: messageEvent.Message.SenderUsername,
+				Description:    messageEvent.Message.Description,
+				CreatedAt:      messageEvent.Message.CreatedAt,
+			})
+			if err != nil {
+				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for MENTIONED event: %v", err)
+				continue
+			}
+
+			// final response
+			response := make([]byte, len(eventNameByte)+len(msg)+1)
+
+			// copying the event name into response
+			copy(response[offset:], eventNameByte)
+			offset += len(eventNameByte)
+
+			// copying the byte for separator
+			copy(response[offset:], separator)
+			offset++
+
+			// copying the message
+			copy(response[offset:], msg)
+
+			pushMessageEvent(messageEvent, response)
+		case POLL_UPDATED:
+			msg, err := json.Marshal(pollUpdated{
+				MessageID: messageEvent.Message.ID,
+				GroupID:   messageEvent.Message.GroupID,
+				Poll:      messageEvent.Message.Poll,
+			})
+			if err != nil {
+				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for POLL_UPDATED event: %v", err)
+				continue
+			}
+
+			// final response
+			response := make([]byte, len(eventNameByte)+len(msg)+1)
+
+			// copying the event name into response
+			copy(response[offset:], eventNameByte)
+			offset += len(eventNameByte)
+
+			// copying the byte for separator
+			copy(response[offset:], separator)
+			offset++
+
+			// copying the message
+			copy(response[offset:], msg)
+
+			pushMessageEvent(messageEvent, response)
+		case MESSAGE_PREVIEW_READY:
+			msg, err := json.Marshal(linkPreviewReady{
+				ID:          messageEvent.Message.ID,
+				GroupID:     messageEvent.Message.GroupID,
+				SenderID:    messageEvent.Message.SenderID,
+				LinkPreview: messageEvent.Message.LinkPreview,
+			})
+			if err != nil {
+				log.Printf("[MESSAGE_EVENT_HANDLER]: error marshalling json for MESSAGE_PREVIEW_READY event: %v", err)
+				continue
+			}
+
+			// final response
+			response := make([]byte, len(eventNameByte)+len(msg)+1)
+
+			// copying the event name into response
+			copy(response[offset:], eventNameByte)
+			offset += len(eventNameByte)
+
+			// copying the byte for separator
+			copy(response[offset:], separator)
+			offset++
+
+			// copying the message
+			copy(response[offset:], msg)
+
+			pushMessageEvent(messageEvent, response)
 		}
 
 	}
 
-	log.Printf("[MESSAGE_EVENT_HANDLER]: Message event handler for %v stopped because event channel was closed", (<-event).Phonenumbers)
+	log.Printf("[MESSAGE_EVENT_HANDLER]: Message event handler stopped because event channel was closed")
 }
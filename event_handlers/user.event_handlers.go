@@ -0,0 +1,84 @@
+package eventhandlers
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/services"
+)
+
+// information about the user that will be provided by event emitted
+type User struct {
+	ID             uuid.UUID `json:"id"`
+	Username       string    `json:"username"`
+	Phonenumber    string    `json:"phonenumber"`
+	OldPhonenumber string    `json:"old_phonenumber,omitempty"`
+}
+
+// event information
+type UserEvent struct {
+	Name                string
+	User                User
+	UserIDs             []uuid.UUID
+	NotificationService *services.Notification
+	EmittedAt           time.Time
+}
+
+// event types
+const (
+	PHONENUMBER_CHANGED = "PHONENUMBER_CHANGED"
+)
+
+// what happened to the user server side will be sent to client
+type userAction struct {
+	Name      string `json:"name"`
+	User      User   `json:"user"`
+	EmittedAt string `json:"emittedAt"`
+}
+
+/*
+Structure of the response sent by this event handler:
+
+	it will be a byte array containing information about the event emitted
+	that is relevant for client side.
+	the byte array is divided into three parts:
+		1. event name
+		2. separator pipe '|'
+		3. instance of userAction struct
+*/
+func UserEventHandler(event chan UserEvent, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Println("[USER_EVENT_HANDLER]: event handler started")
+	for userEvent := range event {
+		log.Printf("[USER_EVENT_HANDLER]: %s event", userEvent.Name)
+		eventNameByte := []byte(userEvent.Name)
+		separatorByte := []byte("|")
+		offset := 0
+		action, err := json.Marshal(userAction{
+			Name:      userEvent.Name,
+			User:      userEvent.User,
+			EmittedAt: userEvent.EmittedAt.Format(time.RFC1123),
+		})
+		if err != nil {
+			log.Printf("[USER_EVENT_HANDLER]: Unable to marshal user event action %v", err)
+			continue
+		}
+
+		response := make([]byte, len(eventNameByte)+len(action)+1)
+
+		copy(response[offset:], eventNameByte)
+		offset += len(eventNameByte)
+
+		copy(response[offset:], separatorByte)
+		offset++
+
+		copy(response[offset:], action)
+
+		userEvent.NotificationService.PushNotification(userEvent.UserIDs, response)
+	}
+
+	log.Printf("[USER_EVENT_HANDLER]: stopped because event channel was closed")
+}
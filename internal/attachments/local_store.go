@@ -0,0 +1,98 @@
+package attachments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps the attachments as files inside a directory on the local filesystem
+type LocalStore struct {
+	directory string
+}
+
+func NewLocalStore(directory string) (*LocalStore, error) {
+	if err := os.MkdirAll(directory, 0o750); err != nil {
+		return nil, err
+	}
+
+	log.Printf("[ATTACHMENTS_STORE]: storing attachments in %s", directory)
+	return &LocalStore{
+		directory: directory,
+	}, nil
+}
+
+// path returns the location of the file for key making sure it stays inside the store directory
+func (ls *LocalStore) path(key string) (string, error) {
+	path := filepath.Join(ls.directory, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(ls.directory)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid attachment key: %s", key)
+	}
+
+	return path, nil
+}
+
+func (ls *LocalStore) Put(ctx context.Context, key string, content io.Reader) error {
+	path, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// writing to a temporary file first so that a failed upload never leaves a partial attachment
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (ls *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (ls *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
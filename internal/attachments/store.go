@@ -0,0 +1,16 @@
+package attachments
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("attachment not found")
+
+// Store is used to save and serve the files uploaded by users like avatars and message attachments
+type Store interface {
+	Put(ctx context.Context, key string, content io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
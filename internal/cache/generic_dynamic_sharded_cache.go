@@ -1,7 +1,11 @@
 package cache
 
 import (
+	"container/list"
 	"hash/fnv"
+	"math"
+	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,32 +14,120 @@ import (
 	"github.com/harshvardha/TerTerChat/internal/database"
 )
 
+const (
+	messageOverhead      = 256 // approximate memory used by a cached message apart from its description and content
+	conversationOverhead = 128 // approximate memory used by a cached conversation apart from its key and messages
+
+	scaleUpMissRatio = 0.2 // share of the lookups since the last check which missed above which the shards are doubled
+	minLookups       = 100 // number of lookups since the last check below which the shards are not resized
+)
+
+// Config sets the bounds of the cache
+type Config struct {
+	Window     int           // number of latest messages kept for every conversation
+	MaxEntries int           // number of conversations kept across all the shards
+	MaxBytes   int64         // approximate memory used by the cached messages across all the shards
+	TTL        time.Duration // duration after the last write after which a conversation is dropped
+}
+
+/*
+conversation holds the latest messages of a conversation sorted by their created_at time. Every message
+of the conversation created at or after from is cached, so a page of messages before any cursor can be
+served from the cache as long as the page does not reach past from. complete is set when the conversation
+has no messages before from at all
+*/
+type conversation struct {
+	key       string
+	messages  []database.Message
+	from      time.Time
+	complete  bool
+	size      int64
+	expiresAt time.Time
+	element   *list.Element // position of the conversation in the lru list
+}
+
+// migrated is set once the conversations of the shard were moved to the shards of a resized table
 type cacheShard struct {
-	items map[string][]database.Message
-	mutex sync.RWMutex
+	items    map[string]*conversation
+	migrated bool
+	mutex    sync.RWMutex
+}
+
+/*
+shardTable is the set of shards the keys are hashed over. While the cache is being resized previous
+holds the table being migrated from, a key lives in its shard of previous until that shard is migrated
+*/
+type shardTable struct {
+	shards   []*cacheShard
+	previous *shardTable
+}
+
+func newShardTable(shardCount int, previous *shardTable) *shardTable {
+	table := &shardTable{
+		shards:   make([]*cacheShard, shardCount),
+		previous: previous,
+	}
+	for i := range shardCount {
+		table.shards[i] = &cacheShard{
+			items: make(map[string]*conversation),
+		}
+	}
+
+	return table
+}
+
+func (table *shardTable) shard(hash uint32) *cacheShard {
+	return table.shards[hash%uint32(len(table.shards))]
 }
 
 type cacheMetrics struct {
-	hits          uint64
-	misses        uint64
-	evictions     uint64
-	resizeEvents  uint64
-	averageLoad   float64
-	lastCheckTime time.Time
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	resizeEvents  atomic.Uint64
+	missRatio     atomic.Uint64 // bits of the float64 miss ratio seen at the last check
+	lastCheckTime atomic.Int64  // unix nano time of the last check
 }
 
+// Stats is a snapshot of the metrics of the cache, the counters only ever grow
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	ResizeEvents  uint64
+	MissRatio     float64 // share of the lookups which missed between the last two checks
+	LastCheckTime time.Time
+	Shards        int
+	Conversations int
+	Bytes         int64
+	ShardEntries  []int // number of conversations in every shard of the current table
+}
+
+/*
+DynamicShardedCache caches the latest messages of the conversations. The conversations are spread
+over the shards by the hash of their key while the number of conversations and the memory used by
+them is bounded across all the shards, when a bound is crossed the least recently used conversations
+are evicted.
+
+The number of shards grows and shrinks with the load. A resize publishes the new table of shards
+atomically and then migrates the old shards into it one at a time, so lookups never wait for the
+whole cache, only for the one shard being migrated at that moment. Lock order is the mutex of a
+shard of the previous table, then the mutex of a shard of the current table, then lruMutex
+*/
 type DynamicShardedCache struct {
-	shards      []*cacheShard
-	shardCount  int32
-	minShards   int
-	maxShards   int
-	resizeMutex sync.RWMutex
-	stopChan    chan struct{}
-	metrics     *cacheMetrics
+	table     atomic.Pointer[shardTable]
+	minShards int
+	maxShards int
+	config    Config
+	lru       *list.List // conversations from the most to the least recently used
+	bytes     int64      // memory used by the conversations in the lru list
+	lruMutex  sync.Mutex
+	stopChan  chan struct{}
+	metrics   *cacheMetrics
 }
 
 // creating new sharded cache
-func NewDynamicShardedCache(minShards, maxShards int) *DynamicShardedCache {
+func NewDynamicShardedCache(minShards, maxShards int, config Config) *DynamicShardedCache {
 	if minShards < 1 {
 		minShards = 1
 	}
@@ -45,19 +137,14 @@ func NewDynamicShardedCache(minShards, maxShards int) *DynamicShardedCache {
 	}
 
 	cache := &DynamicShardedCache{
-		shards:     make([]*cacheShard, minShards),
-		shardCount: int32(minShards),
-		minShards:  minShards,
-		maxShards:  maxShards,
-		stopChan:   make(chan struct{}),
-		metrics:    &cacheMetrics{},
-	}
-
-	for i := range minShards {
-		cache.shards[i] = &cacheShard{
-			items: make(map[string][]database.Message),
-		}
+		minShards: minShards,
+		maxShards: maxShards,
+		config:    config,
+		lru:       list.New(),
+		stopChan:  make(chan struct{}),
+		metrics:   &cacheMetrics{},
 	}
+	cache.table.Store(newShardTable(minShards, nil))
 
 	go cache.monitorAndAdjust()
 	return cache
@@ -68,34 +155,41 @@ func (dsc *DynamicShardedCache) monitorAndAdjust() {
 	ticker := time.NewTicker(time.Second * 10)
 	defer ticker.Stop()
 
+	var lastHits, lastMisses uint64
 	for {
 		select {
 		case <-ticker.C:
-			dsc.checkAndResize()
+			dsc.removeExpired()
+
+			hits, misses := dsc.metrics.hits.Load(), dsc.metrics.misses.Load()
+			dsc.checkAndResize(hits-lastHits, misses-lastMisses)
+			lastHits, lastMisses = hits, misses
 		case <-dsc.stopChan: // signal to stop cache monitoring
 			return
 		}
 	}
 }
 
-func (dsc *DynamicShardedCache) checkAndResize() {
-	currentShardCount := atomic.LoadInt32(&dsc.shardCount)
+/*
+checkAndResize scales the shards based on the lookups since the last check. If more than 20% of
+them missed and there are less than maxShards shards then the shards are doubled, if less than 20%
+missed and there are more than minShards shards then they are halved
+*/
+func (dsc *DynamicShardedCache) checkAndResize(hits, misses uint64) {
+	lookups := hits + misses
+	if lookups < minLookups {
+		return
+	}
 
-	// calculating load factor(based on hits and misses)
-	// if loadFactor is more than 0.2(20%) and currentShardCount < maxShards then scale up
-	// if loadFactor is less than 0.2 then and currentShardCount > minShards scale down
-	if dsc.metrics.misses > 0 {
-		loadFactor := float64((dsc.metrics.misses) / (dsc.metrics.hits + dsc.metrics.misses))
-		dsc.metrics.averageLoad = loadFactor
-		dsc.metrics.lastCheckTime = time.Now()
+	missRatio := float64(misses) / float64(lookups)
+	dsc.metrics.missRatio.Store(math.Float64bits(missRatio))
+	dsc.metrics.lastCheckTime.Store(time.Now().UnixNano())
 
-		if loadFactor >= 0.2 && currentShardCount < int32(dsc.maxShards) {
-			newShardCount := min(int(currentShardCount)*2, dsc.maxShards)
-			dsc.resize(newShardCount)
-		} else if loadFactor < 0.2 && currentShardCount > int32(dsc.minShards) {
-			newShardCount := max(int(currentShardCount)/2, dsc.minShards)
-			dsc.resize(newShardCount)
-		}
+	currentShardCount := len(dsc.table.Load().shards)
+	if missRatio >= scaleUpMissRatio && currentShardCount < dsc.maxShards {
+		dsc.resize(min(currentShardCount*2, dsc.maxShards))
+	} else if missRatio < scaleUpMissRatio && currentShardCount > dsc.minShards {
+		dsc.resize(max(currentShardCount/2, dsc.minShards))
 	}
 }
 
@@ -104,193 +198,432 @@ func (dsc *DynamicShardedCache) StopCacheMonitoring() {
 	close(dsc.stopChan)
 }
 
+/*
+resize moves the conversations to a table with the given number of shards. Only the monitoring
+goroutine resizes so a resize never starts before the previous one has finished
+*/
 func (dsc *DynamicShardedCache) resize(newShardCount int) {
-	dsc.resizeMutex.Lock()
-	defer dsc.resizeMutex.Unlock()
-
-	currentShards := int(dsc.shardCount)
-	if currentShards == newShardCount {
+	current := dsc.table.Load()
+	if len(current.shards) == newShardCount {
 		return
 	}
 
-	// creating a new shards array
-	newShards := make([]*cacheShard, newShardCount)
-	for i := range newShardCount {
-		newShards[i] = &cacheShard{
-			items: make(map[string][]database.Message),
+	// from here on the keys of the migrated shards are looked up in the new table
+	next := newShardTable(newShardCount, current)
+	dsc.table.Store(next)
+
+	// migrating one old shard at a time, only the lookups of the shard being migrated wait for it
+	for _, old := range current.shards {
+		old.mutex.Lock()
+		for key, value := range old.items {
+			shard := next.shard(hashKey(key))
+			shard.mutex.Lock()
+			shard.items[key] = value
+			shard.mutex.Unlock()
 		}
+		old.items = nil
+		old.migrated = true
+		old.mutex.Unlock()
+
+		runtime.Gosched()
 	}
 
-	// rehashing and redistributing the existing items
-	for i := range currentShards {
-		dsc.shards[i].mutex.Lock()
-		for key, value := range dsc.shards[i].items {
-			shardIndex := dsc.hashKey(key, newShardCount)
-			newShards[shardIndex].mutex.Lock()
-			newShards[shardIndex].items[key] = value
-			newShards[shardIndex].mutex.Unlock()
-		}
+	dsc.table.Store(&shardTable{
+		shards: next.shards,
+	})
+	dsc.metrics.resizeEvents.Add(1)
+}
+
+/*
+ConversationKey returns the key of the one-to-one conversation between the two users. The ids are
+ordered so that both users of the conversation share the same cached messages, group conversations
+are keyed by the id of the group
+*/
+func ConversationKey(userID, otherUserID uuid.UUID) string {
+	first, second := userID.String(), otherUserID.String()
+	if second < first {
+		first, second = second, first
 	}
 
-	// updating shard count atomically
-	atomic.StoreInt32(&dsc.shardCount, int32(newShardCount))
-	dsc.shards = newShards
-	dsc.metrics.resizeEvents++
+	return first + second
 }
 
-func (dsc *DynamicShardedCache) hashKey(key string, shardCount int) int {
+func hashKey(key string) uint32 {
 	// 32-bit fnv 1-a hashing algorithm is used beacause it is light on cpu
 	// because it performs only two simple operations: multiplication and XOR
 	hasher := fnv.New32a()
 	hasher.Write([]byte(key))
-	return int(hasher.Sum32()) % shardCount
+	return hasher.Sum32()
+}
+
+/*
+lockShard returns the shard holding the key locked for reading or writing along with its unlock function.
+The key is in the shard of the previous table while that shard is not migrated, otherwise in the shard
+of the current table. If the table was resized again since it was loaded then the lookup starts over
+*/
+func (dsc *DynamicShardedCache) lockShard(key string, write bool) (*cacheShard, func()) {
+	hash := hashKey(key)
+	lock := func(shard *cacheShard) func() {
+		if write {
+			shard.mutex.Lock()
+			return shard.mutex.Unlock
+		}
+		shard.mutex.RLock()
+		return shard.mutex.RUnlock
+	}
+
+	for {
+		table := dsc.table.Load()
+		if table.previous != nil {
+			old := table.previous.shard(hash)
+			unlock := lock(old)
+			if !old.migrated {
+				return old, unlock
+			}
+			unlock()
+		}
+
+		shard := table.shard(hash)
+		unlock := lock(shard)
+		if !shard.migrated {
+			return shard, unlock
+		}
+		unlock()
+	}
+}
+
+// messageSize returns the approximate memory used by the cached message
+func messageSize(message database.Message) int64 {
+	return int64(messageOverhead + len(message.Description) + len(message.Content))
+}
+
+// resetSize recomputes the memory used by the conversation and accounts the change, the shard of the conversation has to be locked
+func (dsc *DynamicShardedCache) resetSize(c *conversation) {
+	size := int64(conversationOverhead + len(c.key))
+	for _, message := range c.messages {
+		size += messageSize(message)
+	}
+
+	dsc.lruMutex.Lock()
+	dsc.bytes += size - c.size
+	dsc.lruMutex.Unlock()
+	c.size = size
+}
+
+/*
+trimWindow drops the oldest messages of the conversation beyond the window. Messages created at the same time
+as the last dropped message are dropped as well so that every message created at or after from stays cached
+*/
+func (dsc *DynamicShardedCache) trimWindow(c *conversation) {
+	if len(c.messages) <= dsc.config.Window {
+		return
+	}
+
+	drop := len(c.messages) - dsc.config.Window
+	for drop < len(c.messages) && c.messages[drop].CreatedAt.Equal(c.messages[drop-1].CreatedAt) {
+		drop++
+	}
+
+	c.messages = slices.Delete(c.messages, 0, drop)
+	c.complete = false
+	if len(c.messages) > 0 {
+		c.from = c.messages[0].CreatedAt
+	} else {
+		// every cached message had the same created_at time, only the later messages are covered
+		c.from = c.from.Add(time.Nanosecond)
+	}
+}
+
+// insertMessage adds the message to the conversation keeping the order by created_at or replaces the cached copy of the message
+func insertMessage(c *conversation, message database.Message) {
+	if index := slices.IndexFunc(c.messages, func(cached database.Message) bool { return cached.ID == message.ID }); index >= 0 {
+		c.messages[index] = message
+		return
+	}
+
+	index, _ := slices.BinarySearchFunc(c.messages, message.CreatedAt, func(cached database.Message, createdAt time.Time) int {
+		if cached.CreatedAt.After(createdAt) {
+			return 1
+		}
+		return -1
+	})
+	c.messages = slices.Insert(c.messages, index, message)
+}
+
+// touch marks the conversation as the most recently used
+func (dsc *DynamicShardedCache) touch(c *conversation) {
+	dsc.lruMutex.Lock()
+	defer dsc.lruMutex.Unlock()
+
+	if c.element != nil {
+		dsc.lru.MoveToFront(c.element)
+	}
+}
+
+// removeLocked removes the conversation from its shard and the lru list, the shard has to be locked
+func (dsc *DynamicShardedCache) removeLocked(shard *cacheShard, c *conversation) {
+	delete(shard.items, c.key)
+
+	dsc.lruMutex.Lock()
+	dsc.lru.Remove(c.element)
+	dsc.bytes -= c.size
+	dsc.lruMutex.Unlock()
 }
 
-func (dsc *DynamicShardedCache) getShard(key string) *cacheShard {
-	shardIndex := dsc.hashKey(key, int(dsc.shardCount))
-	return dsc.shards[shardIndex]
+// evict removes the least recently used conversations until the cache is within its bounds, the caller must not hold the lock of any shard
+func (dsc *DynamicShardedCache) evict() {
+	for {
+		dsc.lruMutex.Lock()
+		if dsc.lru.Len() <= dsc.config.MaxEntries && dsc.bytes <= dsc.config.MaxBytes {
+			dsc.lruMutex.Unlock()
+			return
+		}
+		victim := dsc.lru.Back().Value.(*conversation)
+		dsc.lruMutex.Unlock()
+
+		shard, unlock := dsc.lockShard(victim.key, true)
+		if shard.items[victim.key] == victim {
+			dsc.removeLocked(shard, victim)
+			dsc.metrics.evictions.Add(1)
+		}
+		unlock()
+	}
+}
+
+// removeExpired removes the conversations which were not written to for the ttl
+func (dsc *DynamicShardedCache) removeExpired() {
+	now := time.Now()
+	table := dsc.table.Load()
+	for ; table != nil; table = table.previous {
+		for _, shard := range table.shards {
+			shard.mutex.Lock()
+			for _, c := range shard.items {
+				if now.After(c.expiresAt) {
+					dsc.removeLocked(shard, c)
+					dsc.metrics.evictions.Add(1)
+				}
+			}
+			shard.mutex.Unlock()
+		}
+	}
+}
+
+// Stats returns the current metrics of the cache
+func (dsc *DynamicShardedCache) Stats() Stats {
+	dsc.lruMutex.Lock()
+	conversations, bytes := dsc.lru.Len(), dsc.bytes
+	dsc.lruMutex.Unlock()
+
+	stats := Stats{
+		Hits:          dsc.metrics.hits.Load(),
+		Misses:        dsc.metrics.misses.Load(),
+		Evictions:     dsc.metrics.evictions.Load(),
+		ResizeEvents:  dsc.metrics.resizeEvents.Load(),
+		MissRatio:     math.Float64frombits(dsc.metrics.missRatio.Load()),
+		Conversations: conversations,
+		Bytes:         bytes,
+	}
+
+	// during a resize the conversations of the shards not yet migrated are counted in their new shard
+	table := dsc.table.Load()
+	stats.Shards = len(table.shards)
+	stats.ShardEntries = make([]int, len(table.shards))
+	for index, shard := range table.shards {
+		shard.mutex.RLock()
+		stats.ShardEntries[index] += len(shard.items)
+		shard.mutex.RUnlock()
+	}
+	if table.previous != nil {
+		for _, shard := range table.previous.shards {
+			shard.mutex.RLock()
+			for key := range shard.items {
+				stats.ShardEntries[hashKey(key)%uint32(len(table.shards))]++
+			}
+			shard.mutex.RUnlock()
+		}
+	}
+	if lastCheckTime := dsc.metrics.lastCheckTime.Load(); lastCheckTime != 0 {
+		stats.LastCheckTime = time.Unix(0, lastCheckTime)
+	}
+
+	return stats
 }
 
-func (dsc *DynamicShardedCache) Get(key string, createdAt time.Time) []database.Message {
-	// attaining read lock on cache
-	dsc.resizeMutex.RLock()
-	defer dsc.resizeMutex.RUnlock()
+/*
+Get returns the page of at most limit messages of the conversation created before the given time,
+oldest first, like the history queries of the database do. The second return value is false when the
+cache does not cover the whole page and the database has to be asked. The returned slice is a copy
+*/
+func (dsc *DynamicShardedCache) Get(key string, before time.Time, limit int) ([]database.Message, bool) {
+	if limit <= 0 {
+		return nil, false
+	}
 
 	// key can be a group id or concatenated string of user ids of both users involved in the conversation
 	// hashing the key using fnv 1-a then dividing this hash with current shard count and using this value
 	// to fetch the target shard and using the raw hash value to access the conversation
+	shard, unlock := dsc.lockShard(key, false)
+	defer unlock()
+
+	now := time.Now()
+	c, ok := shard.items[key]
+	if !ok || now.After(c.expiresAt) {
+		dsc.metrics.misses.Add(1)
+		return nil, false
+	}
 
-	shard := dsc.getShard(key)
-	shard.mutex.RLock()
-	defer shard.mutex.RUnlock()
+	// walking back from the cursor and skipping the disappearing messages whose timer has run out
+	end, _ := slices.BinarySearchFunc(c.messages, before, func(cached database.Message, before time.Time) int {
+		return cached.CreatedAt.Compare(before)
+	})
+	page := make([]database.Message, 0, limit)
+	for index := end - 1; index >= 0 && len(page) < limit; index-- {
+		message := c.messages[index]
+		if message.ExpiresAt.Valid && !message.ExpiresAt.Time.After(now) {
+			continue
+		}
+		page = append(page, message)
+	}
 
-	// if the createdAt time of latest message is <= createdAt argument then return messages
-	items, ok := shard.items[key]
-	if !ok {
-		atomic.AddUint64(&dsc.metrics.misses, 1)
-		return nil
+	// a short page is only correct when there is nothing older than the cached messages
+	if len(page) < limit && !c.complete {
+		dsc.metrics.misses.Add(1)
+		return nil, false
+	}
+
+	dsc.metrics.hits.Add(1)
+	dsc.touch(c)
+	slices.Reverse(page)
+	return page, true
+}
+
+/*
+Fill adds the page of messages created before the given time, fetched from the database with the given
+limit, to the cached conversation. The page is only added when it joins the cached messages without a gap,
+so conversations are never created by Fill, only by Set which sees every new message
+*/
+func (dsc *DynamicShardedCache) Fill(key string, page []database.Message, before time.Time, limit int) {
+	// without a limit it can not be told whether the page reached the start of the conversation
+	if limit <= 0 {
+		return
+	}
+
+	shard, unlock := dsc.lockShard(key, true)
+
+	c, ok := shard.items[key]
+	if !ok || before.Before(c.from) {
+		unlock()
+		return
 	}
 
-	if items[len(items)-1].CreatedAt.Before(createdAt) || items[len(items)-1].CreatedAt.Equal(createdAt) {
-		atomic.AddUint64(&dsc.metrics.hits, 1)
-		return items
+	// a full page may have left out some messages created at the same time as its oldest message
+	complete := len(page) < limit
+	if !complete {
+		oldest := page[0].CreatedAt
+		page = slices.DeleteFunc(slices.Clone(page), func(message database.Message) bool {
+			return message.CreatedAt.Equal(oldest)
+		})
+		if len(page) == 0 {
+			unlock()
+			return
+		}
 	}
 
-	return nil
+	for _, message := range page {
+		if message.CreatedAt.Before(c.from) {
+			insertMessage(c, message)
+		}
+	}
+	if complete {
+		c.complete = true
+	}
+	if len(c.messages) > 0 && c.messages[0].CreatedAt.Before(c.from) {
+		c.from = c.messages[0].CreatedAt
+	}
+
+	dsc.trimWindow(c)
+	dsc.resetSize(c)
+	c.expiresAt = time.Now().Add(dsc.config.TTL)
+	unlock()
+
+	dsc.evict()
 }
 
 func (dsc *DynamicShardedCache) Remove(key string) {
-	dsc.resizeMutex.RLock()
-	defer dsc.resizeMutex.RUnlock()
+	shard, unlock := dsc.lockShard(key, true)
+	defer unlock()
 
-	shard := dsc.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
-
-	_, ok := shard.items[key]
-	if ok {
-		delete(shard.items, key)
-		dsc.metrics.evictions++
+	if c, ok := shard.items[key]; ok {
+		dsc.removeLocked(shard, c)
+		dsc.metrics.evictions.Add(1)
 	}
 }
 
+// RemoveMessage removes the message from the cached conversation, used for the messages deleted from the database
 func (dsc *DynamicShardedCache) RemoveMessage(key string, messageID uuid.UUID) {
-	dsc.resizeMutex.RLock()
-	defer dsc.resizeMutex.RUnlock()
-
-	shard := dsc.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
-
-	messages := shard.items[key]
-	for index, message := range messages {
-		if message.ID == messageID {
-			newMessagesSlice := make([]database.Message, 10)
-			// if index == 0 then just copy all the elements other than the first one
-			// if index == len(messages) - 1 then copy all the elements other than last one
-			// if index > 0 && index < len(messages) - 1 then copy elements before index and after index
-			// after the required operation update the shard map key with new message slice
-			if index == 0 {
-				newMessagesSlice = append(newMessagesSlice, messages[1:]...)
-			} else if index == len(messages)-1 {
-				newMessagesSlice = append(newMessagesSlice, messages[0:index+1]...)
-			} else {
-				newMessagesSlice = append(newMessagesSlice, messages[0:index+1]...)
-				newMessagesSlice = append(newMessagesSlice, messages[index+1:]...)
-			}
-			shard.items[key] = newMessagesSlice
-			break
-		}
+	shard, unlock := dsc.lockShard(key, true)
+	defer unlock()
+
+	c, ok := shard.items[key]
+	if !ok {
+		return
 	}
-}
 
-func (dsc *DynamicShardedCache) Update(
-	key string,
-	messageID uuid.UUID,
-	description string,
-	received bool,
-	read bool,
-	isReceiverAllowedToSee bool,
-	updatedAt time.Time) {
+	c.messages = slices.DeleteFunc(c.messages, func(message database.Message) bool {
+		return message.ID == messageID
+	})
+	dsc.resetSize(c)
+}
 
-	dsc.resizeMutex.RLock()
-	defer dsc.resizeMutex.RUnlock()
+// Update applies the change to the cached copy of the message if the message is cached
+func (dsc *DynamicShardedCache) Update(key string, messageID uuid.UUID, update func(message *database.Message)) {
+	shard, unlock := dsc.lockShard(key, true)
+	defer unlock()
 
-	shard := dsc.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
+	c, ok := shard.items[key]
+	if !ok {
+		return
+	}
 
-	// updating the existing message
-	messages := shard.items[key]
-	for _, message := range messages {
-		if message.ID == messageID {
-			if len(description) > 0 && message.Description != description {
-				message.Description = description
-			}
-			if !message.Recieved && received {
-				message.Recieved = true
-			}
-			if updatedAt.After(message.UpdatedAt) {
-				message.UpdatedAt = updatedAt
-			}
-			if !message.Read && read {
-				message.Read = true
-			}
-			if !message.IsReceiverAllowedToSee && isReceiverAllowedToSee {
-				message.IsReceiverAllowedToSee = true
-			}
-			break
+	for index := range c.messages {
+		if c.messages[index].ID == messageID {
+			update(&c.messages[index])
+			dsc.resetSize(c)
+			return
 		}
 	}
 }
 
+// Set adds the newly created message to the cached conversation, creating the conversation if it is not cached
 func (dsc *DynamicShardedCache) Set(key string, value database.Message) {
-	dsc.resizeMutex.RLock()
-	defer dsc.resizeMutex.RUnlock()
-
-	shard := dsc.getShard(key)
-	shard.mutex.Lock()
-	defer shard.mutex.Unlock()
-
-	// checking if the key already exist in cache
-	// if key already exist then pop out the oldest message and insert the new one
-	// if key does not exist then create a new []database.Message and insert into cache with new key
-	messages, ok := shard.items[key]
-	if ok {
-		if len(messages) == 10 {
-			values := make([]database.Message, 10)
-			values = append(values, messages[1:]...)
-			values = append(values, value)
-			shard.items[key] = values
-		} else {
-			messages = append(messages, value)
-			shard.items[key] = messages
+	shard, unlock := dsc.lockShard(key, true)
+
+	// a new conversation covers the messages from this one onwards
+	c, ok := shard.items[key]
+	if !ok {
+		c = &conversation{
+			key:  key,
+			from: value.CreatedAt,
 		}
+		shard.items[key] = c
+
+		dsc.lruMutex.Lock()
+		c.element = dsc.lru.PushFront(c)
+		dsc.lruMutex.Unlock()
 	} else {
-		values := make([]database.Message, 10)
-		values[0] = value
-		shard.items[key] = values
+		dsc.touch(c)
+	}
+
+	// a message older than the cached range would leave a gap in front of the cached messages
+	if !value.CreatedAt.Before(c.from) {
+		insertMessage(c, value)
+		dsc.trimWindow(c)
 	}
+	dsc.resetSize(c)
+	c.expiresAt = time.Now().Add(dsc.config.TTL)
+	unlock()
+
+	dsc.evict()
 }
 
 func min(a int, b int) int {
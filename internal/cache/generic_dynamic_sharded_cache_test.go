@@ -0,0 +1,521 @@
+package cache
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+var testEpoch = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestCache(t testing.TB, config Config) *DynamicShardedCache {
+	t.Helper()
+
+	cache := NewDynamicShardedCache(4, 16, config)
+	t.Cleanup(cache.StopCacheMonitoring)
+	return cache
+}
+
+// newTestMessage returns a message created the given number of seconds after testEpoch
+func newTestMessage(second int) database.Message {
+	return database.Message{
+		ID:          uuid.New(),
+		Description: "message",
+		CreatedAt:   testEpoch.Add(time.Duration(second) * time.Second),
+	}
+}
+
+// historyPage returns the page the history queries return, the latest limit messages before the cursor oldest first
+func historyPage(history []database.Message, before time.Time, limit int) []database.Message {
+	page := []database.Message{}
+	for index := len(history) - 1; index >= 0 && len(page) < limit; index-- {
+		message := history[index]
+		if !message.CreatedAt.Before(before) {
+			continue
+		}
+		if message.ExpiresAt.Valid && !message.ExpiresAt.Time.After(time.Now()) {
+			continue
+		}
+		page = append(page, message)
+	}
+
+	slices.Reverse(page)
+	return page
+}
+
+func messageIDs(messages []database.Message) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+/*
+TestPagesMatchHistory walks every conversation back from the latest message the way the conversation
+endpoints do, asking the cache first and filling it from the history on a miss, and checks that every
+page is the page the database would have returned
+*/
+func TestPagesMatchHistory(t *testing.T) {
+	tests := []struct {
+		name       string
+		seconds    []int // created_at of the messages of the conversation in seconds after testEpoch
+		expired    []int // indexes of the disappearing messages whose timer has run out
+		cachedFrom int   // index of the first message which was sent while the conversation was cached
+		window     int
+		limit      int
+		wantHits   int
+	}{
+		{
+			name:       "whole conversation cached",
+			seconds:    []int{1, 2, 3, 4, 5, 6},
+			cachedFrom: 0,
+			window:     10,
+			limit:      2,
+			wantHits:   3,
+		},
+		{
+			name:       "older messages filled from history",
+			seconds:    []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			cachedFrom: 6,
+			window:     20,
+			limit:      3,
+			wantHits:   1,
+		},
+		{
+			name:       "window trims the oldest messages",
+			seconds:    []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			cachedFrom: 0,
+			window:     4,
+			limit:      2,
+			wantHits:   2,
+		},
+		{
+			name:       "window boundary between messages with the same created_at",
+			seconds:    []int{1, 2, 2, 2, 3, 4},
+			cachedFrom: 0,
+			window:     4,
+			limit:      2,
+			wantHits:   1,
+		},
+		{
+			name:       "page boundary between messages with the same created_at",
+			seconds:    []int{1, 2, 3, 3, 3, 4, 5},
+			cachedFrom: 5,
+			window:     10,
+			limit:      2,
+			wantHits:   1,
+		},
+		{
+			name:       "expired disappearing messages are skipped",
+			seconds:    []int{1, 2, 3, 4, 5, 6},
+			expired:    []int{2, 4},
+			cachedFrom: 0,
+			window:     10,
+			limit:      2,
+			wantHits:   2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newTestCache(t, Config{
+				Window:     test.window,
+				MaxEntries: 10,
+				MaxBytes:   1 << 20,
+				TTL:        time.Minute,
+			})
+
+			history := []database.Message{}
+			for _, second := range test.seconds {
+				history = append(history, newTestMessage(second))
+			}
+			for _, index := range test.expired {
+				history[index].ExpiresAt = sql.NullTime{
+					Time:  time.Now().Add(-time.Second),
+					Valid: true,
+				}
+			}
+			for _, message := range history[test.cachedFrom:] {
+				cache.Set("conversation", message)
+			}
+
+			hits := 0
+			before := testEpoch.Add(time.Hour)
+			for range len(history) + 1 {
+				page, ok := cache.Get("conversation", before, test.limit)
+				if ok {
+					hits++
+				} else {
+					page = historyPage(history, before, test.limit)
+					cache.Fill("conversation", page, before, test.limit)
+				}
+
+				want := historyPage(history, before, test.limit)
+				if !slices.Equal(messageIDs(page), messageIDs(want)) {
+					t.Fatalf("page before %v: got %v, want %v (served from cache: %v)", before, messageIDs(page), messageIDs(want), ok)
+				}
+				if len(page) == 0 {
+					break
+				}
+				before = page[0].CreatedAt
+			}
+
+			if hits != test.wantHits {
+				t.Errorf("got %d pages from the cache, want %d", hits, test.wantHits)
+			}
+		})
+	}
+}
+
+func TestFillWithoutLimit(t *testing.T) {
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: 10,
+		MaxBytes:   1 << 20,
+		TTL:        time.Minute,
+	})
+	cache.Set("conversation", newTestMessage(10))
+
+	cache.Fill("conversation", nil, testEpoch.Add(time.Hour), 0)
+	if _, ok := cache.Get("conversation", testEpoch.Add(time.Hour), 0); ok {
+		t.Error("got a page without a limit, want a miss")
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: 10,
+		MaxBytes:   1 << 20,
+		TTL:        time.Minute,
+	})
+	cache.Set("conversation", newTestMessage(1))
+
+	page, _ := cache.Get("conversation", testEpoch.Add(time.Hour), 1)
+	page[0].Description = "changed"
+
+	page, _ = cache.Get("conversation", testEpoch.Add(time.Hour), 1)
+	if page[0].Description != "message" {
+		t.Errorf("got description %q, want the cached copy to stay unchanged", page[0].Description)
+	}
+}
+
+func TestUpdateAndRemoveMessage(t *testing.T) {
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: 10,
+		MaxBytes:   1 << 20,
+		TTL:        time.Minute,
+	})
+	first, second := newTestMessage(1), newTestMessage(2)
+	cache.Set("conversation", first)
+	cache.Set("conversation", second)
+
+	cache.Update("conversation", first.ID, func(message *database.Message) {
+		message.Description = "edited message"
+	})
+	cache.RemoveMessage("conversation", second.ID)
+
+	page, ok := cache.Get("conversation", testEpoch.Add(time.Hour), 1)
+	if !ok || len(page) != 1 || page[0].ID != first.ID || page[0].Description != "edited message" {
+		t.Fatalf("got %v, %v, want the edited first message", page, ok)
+	}
+
+	if bytes := cache.Stats().Bytes; bytes != int64(conversationOverhead+len("conversation")+messageOverhead+len("edited message")) {
+		t.Errorf("got %d bytes, want the size of the edited message", bytes)
+	}
+}
+
+func TestExpiredConversationsAreDropped(t *testing.T) {
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: 10,
+		MaxBytes:   1 << 20,
+		TTL:        50 * time.Millisecond,
+	})
+	cache.Set("conversation", newTestMessage(1))
+
+	if _, ok := cache.Get("conversation", testEpoch.Add(time.Hour), 1); !ok {
+		t.Fatal("got a miss before the ttl, want a hit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := cache.Get("conversation", testEpoch.Add(time.Hour), 1); ok {
+		t.Error("got a hit after the ttl, want a miss")
+	}
+
+	cache.removeExpired()
+	if stats := cache.Stats(); stats.Conversations != 0 || stats.Bytes != 0 {
+		t.Errorf("got %d conversations using %d bytes after removing the expired ones, want none", stats.Conversations, stats.Bytes)
+	}
+}
+
+func TestLeastRecentlyUsedConversationsAreEvicted(t *testing.T) {
+	conversationSize := int64(conversationOverhead + len("a") + messageOverhead + len("message"))
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{
+			name: "entry limit",
+			config: Config{
+				Window:     10,
+				MaxEntries: 2,
+				MaxBytes:   1 << 20,
+				TTL:        time.Minute,
+			},
+		},
+		{
+			name: "byte limit",
+			config: Config{
+				Window:     10,
+				MaxEntries: 10,
+				MaxBytes:   2 * conversationSize,
+				TTL:        time.Minute,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newTestCache(t, test.config)
+			cache.Set("a", newTestMessage(1))
+			cache.Set("b", newTestMessage(2))
+
+			// reading a makes b the least recently used conversation
+			cache.Get("a", testEpoch.Add(time.Hour), 1)
+			cache.Set("c", newTestMessage(3))
+
+			for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+				if _, ok := cache.Get(key, testEpoch.Add(time.Hour), 1); ok != want {
+					t.Errorf("conversation %s cached: got %v, want %v", key, ok, want)
+				}
+			}
+			if stats := cache.Stats(); stats.Evictions != 1 || stats.Bytes != 2*conversationSize {
+				t.Errorf("got %d evictions and %d bytes, want 1 eviction and %d bytes", stats.Evictions, stats.Bytes, 2*conversationSize)
+			}
+		})
+	}
+}
+
+// keepResizing resizes the cache back and forth between the given shard counts until stop is closed
+func keepResizing(cache *DynamicShardedCache, shardCounts []int, stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for index := 0; ; index++ {
+			select {
+			case <-stop:
+				return
+			default:
+				cache.resize(shardCounts[index%len(shardCounts)])
+			}
+		}
+	}()
+
+	return done
+}
+
+/*
+TestResizeKeepsEntries runs lookups and writes of every kind while the shards are resized and checks
+that no conversation or message written before or during the resizes is lost. Run it with -race
+*/
+func TestResizeKeepsEntries(t *testing.T) {
+	const (
+		writers       = 8
+		conversations = 200
+	)
+
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: writers * conversations,
+		MaxBytes:   1 << 30,
+		TTL:        time.Minute,
+	})
+
+	stop := make(chan struct{})
+	resizing := keepResizing(cache, []int{8, 3, 16, 1, 5}, stop)
+
+	var wg sync.WaitGroup
+	for writer := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conversation := range conversations {
+				key := fmt.Sprintf("conversation-%d-%d", writer, conversation)
+				kept, removed := newTestMessage(2), newTestMessage(3)
+				cache.Set(key, kept)
+				cache.Set(key, removed)
+				cache.Fill(key, []database.Message{newTestMessage(1)}, kept.CreatedAt, 2)
+				cache.RemoveMessage(key, removed.ID)
+				cache.Update(key, kept.ID, func(message *database.Message) {
+					message.Description = key
+				})
+
+				page, ok := cache.Get(key, testEpoch.Add(time.Hour), 2)
+				if !ok || len(page) != 2 || page[1].Description != key {
+					t.Errorf("conversation %s: got %v, %v during resize, want its two messages", key, page, ok)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-resizing
+
+	for writer := range writers {
+		for conversation := range conversations {
+			key := fmt.Sprintf("conversation-%d-%d", writer, conversation)
+			if page, ok := cache.Get(key, testEpoch.Add(time.Hour), 2); !ok || len(page) != 2 || page[1].Description != key {
+				t.Fatalf("conversation %s: got %v, %v after resize, want its two messages", key, page, ok)
+			}
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Conversations != writers*conversations || stats.ResizeEvents == 0 {
+		t.Errorf("got %d conversations after %d resizes, want %d", stats.Conversations, stats.ResizeEvents, writers*conversations)
+	}
+
+	entries := 0
+	for _, shardEntries := range stats.ShardEntries {
+		entries += shardEntries
+	}
+	if entries != writers*conversations {
+		t.Errorf("got %d conversations in the shards, want %d", entries, writers*conversations)
+	}
+}
+
+func TestResizeEvictsWithinBounds(t *testing.T) {
+	cache := newTestCache(t, Config{
+		Window:     10,
+		MaxEntries: 50,
+		MaxBytes:   1 << 30,
+		TTL:        time.Minute,
+	})
+
+	stop := make(chan struct{})
+	resizing := keepResizing(cache, []int{2, 7, 4}, stop)
+
+	var wg sync.WaitGroup
+	for writer := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for conversation := range 500 {
+				cache.Set(fmt.Sprintf("conversation-%d-%d", writer, conversation), newTestMessage(conversation))
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-resizing
+
+	if stats := cache.Stats(); stats.Conversations != 50 {
+		t.Errorf("got %d conversations, want the limit of 50", stats.Conversations)
+	}
+}
+
+func TestCheckAndResize(t *testing.T) {
+	tests := []struct {
+		name       string
+		hits       uint64
+		misses     uint64
+		wantShards int
+	}{
+		{name: "too few lookups", hits: 10, misses: 50, wantShards: 4},
+		{name: "many misses", hits: 700, misses: 300, wantShards: 8},
+		{name: "few misses", hits: 950, misses: 50, wantShards: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache := newTestCache(t, Config{
+				Window:     10,
+				MaxEntries: 10,
+				MaxBytes:   1 << 20,
+				TTL:        time.Minute,
+			})
+
+			cache.checkAndResize(test.hits, test.misses)
+			if shards := cache.Stats().Shards; shards != test.wantShards {
+				t.Errorf("got %d shards, want %d", shards, test.wantShards)
+			}
+		})
+	}
+}
+
+// benchmarkCache returns a cache holding the given number of conversations with one message each
+func benchmarkCache(b *testing.B, conversations int) (*DynamicShardedCache, []string) {
+	cache := newTestCache(b, Config{
+		Window:     50,
+		MaxEntries: conversations,
+		MaxBytes:   1 << 30,
+		TTL:        time.Hour,
+	})
+
+	keys := make([]string, conversations)
+	for index := range keys {
+		keys[index] = fmt.Sprintf("conversation-%d", index)
+		cache.Set(keys[index], newTestMessage(index))
+	}
+
+	return cache, keys
+}
+
+// runWithResizes runs the benchmark once on a cache at rest and once while the cache is being resized
+func runWithResizes(b *testing.B, operation func(cache *DynamicShardedCache, keys []string, index int)) {
+	for _, resizing := range []bool{false, true} {
+		name := "idle"
+		if resizing {
+			name = "resizing"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			cache, keys := benchmarkCache(b, 10000)
+
+			stop := make(chan struct{})
+			idle := make(chan struct{})
+			close(idle)
+			var done <-chan struct{} = idle
+			if resizing {
+				done = keepResizing(cache, []int{4, 16, 8, 32}, stop)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				index := 0
+				for pb.Next() {
+					operation(cache, keys, index)
+					index++
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			<-done
+			b.ReportMetric(float64(cache.Stats().ResizeEvents), "resizes")
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	before := testEpoch.Add(time.Hour)
+	runWithResizes(b, func(cache *DynamicShardedCache, keys []string, index int) {
+		cache.Get(keys[index%len(keys)], before, 1)
+	})
+}
+
+func BenchmarkSet(b *testing.B) {
+	runWithResizes(b, func(cache *DynamicShardedCache, keys []string, index int) {
+		cache.Set(keys[index%len(keys)], newTestMessage(index))
+	})
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"strconv"
+
+	"github.com/harshvardha/TerTerChat/internal/metrics"
+)
+
+// RegisterMetrics exposes the metrics of the cache under the given name, the stats are read on every scrape
+func (dsc *DynamicShardedCache) RegisterMetrics(name string) {
+	prefix := "terterchat_" + name + "_cache_"
+	metrics.NewCounterFunc(prefix+"hits_total", "Lookups served from the cache.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(dsc.metrics.hits.Load())}}
+	})
+	metrics.NewCounterFunc(prefix+"misses_total", "Lookups which had to be served from the database.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(dsc.metrics.misses.Load())}}
+	})
+	metrics.NewCounterFunc(prefix+"evictions_total", "Conversations evicted for the bounds or the ttl of the cache.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(dsc.metrics.evictions.Load())}}
+	})
+	metrics.NewCounterFunc(prefix+"resize_events_total", "Times the shards of the cache were resized.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(dsc.metrics.resizeEvents.Load())}}
+	})
+	metrics.NewGaugeFunc(prefix+"hit_ratio", "Share of all the lookups which were served from the cache.", nil, func() []metrics.Sample {
+		hits, misses := dsc.metrics.hits.Load(), dsc.metrics.misses.Load()
+		if hits+misses == 0 {
+			return []metrics.Sample{{Value: 0}}
+		}
+		return []metrics.Sample{{Value: float64(hits) / float64(hits+misses)}}
+	})
+
+	metrics.NewGaugeFunc(prefix+"shards", "Number of shards of the cache.", nil, func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(len(dsc.table.Load().shards))}}
+	})
+	metrics.NewGaugeFunc(prefix+"shard_entries", "Number of conversations in every shard of the cache.", []string{"shard"}, func() []metrics.Sample {
+		stats := dsc.Stats()
+		samples := make([]metrics.Sample, 0, len(stats.ShardEntries))
+		for index, entries := range stats.ShardEntries {
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{strconv.Itoa(index)},
+				Value:       float64(entries),
+			})
+		}
+		return samples
+	})
+	metrics.NewGaugeFunc(prefix+"entries", "Number of conversations in the cache.", nil, func() []metrics.Sample {
+		dsc.lruMutex.Lock()
+		defer dsc.lruMutex.Unlock()
+		return []metrics.Sample{{Value: float64(dsc.lru.Len())}}
+	})
+	metrics.NewGaugeFunc(prefix+"bytes", "Approximate memory used by the cached messages.", nil, func() []metrics.Sample {
+		dsc.lruMutex.Lock()
+		defer dsc.lruMutex.Unlock()
+		return []metrics.Sample{{Value: float64(dsc.bytes)}}
+	})
+}
@@ -7,30 +7,64 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 const addUserToGroup = `-- name: AddUserToGroup :exec
-insert into users_groups(user_id, group_id, created_at)
-values($1, $2, NOW())
+insert into users_groups(user_id, group_id, role, created_at)
+values($1, $2, $3, NOW())
 `
 
 type AddUserToGroupParams struct {
 	UserID  uuid.UUID
 	GroupID uuid.UUID
+	Role    string
 }
 
 func (q *Queries) AddUserToGroup(ctx context.Context, arg AddUserToGroupParams) error {
-	_, err := q.db.ExecContext(ctx, addUserToGroup, arg.UserID, arg.GroupID)
+	_, err := q.db.ExecContext(ctx, addUserToGroup, arg.UserID, arg.GroupID, arg.Role)
 	return err
 }
 
+const createChannel = `-- name: CreateChannel :one
+insert into groups(id, name, description, type, handle, only_admins_can_post, created_at, updated_at)
+values(gen_random_uuid(), $1, $2, 'channel', $3, true, NOW(), NOW())
+returning id, name, created_at, updated_at, description, avatar_key, member_limit, only_admins_can_post, only_admins_can_edit_info, type, handle, message_timer
+`
+
+type CreateChannelParams struct {
+	Name        string
+	Description sql.NullString
+	Handle      sql.NullString
+}
+
+func (q *Queries) CreateChannel(ctx context.Context, arg CreateChannelParams) (Group, error) {
+	row := q.db.QueryRowContext(ctx, createChannel, arg.Name, arg.Description, arg.Handle)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.AvatarKey,
+		&i.MemberLimit,
+		&i.OnlyAdminsCanPost,
+		&i.OnlyAdminsCanEditInfo,
+		&i.Type,
+		&i.Handle,
+		&i.MessageTimer,
+	)
+	return i, err
+}
+
 const createGroup = `-- name: CreateGroup :one
 insert into groups(id, name, created_at, updated_at)
 values(gen_random_uuid(), $1, NOW(), NOW())
-returning id, name, created_at, updated_at
+returning id, name, created_at, updated_at, description, avatar_key, member_limit, only_admins_can_post, only_admins_can_edit_info, type, handle, message_timer
 `
 
 func (q *Queries) CreateGroup(ctx context.Context, name string) (Group, error) {
@@ -41,6 +75,14 @@ func (q *Queries) CreateGroup(ctx context.Context, name string) (Group, error) {
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Description,
+		&i.AvatarKey,
+		&i.MemberLimit,
+		&i.OnlyAdminsCanPost,
+		&i.OnlyAdminsCanEditInfo,
+		&i.Type,
+		&i.Handle,
+		&i.MessageTimer,
 	)
 	return i, err
 }
@@ -54,17 +96,55 @@ func (q *Queries) DeleteGroup(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteGroupIfEmpty = `-- name: DeleteGroupIfEmpty :execrows
+delete from groups where id = $1 and not exists (select 1 from users_groups where group_id = $1)
+`
+
+func (q *Queries) DeleteGroupIfEmpty(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteGroupIfEmpty, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getGroup = `-- name: GetGroup :one
+select id, name, created_at, updated_at, description, avatar_key, member_limit, only_admins_can_post, only_admins_can_edit_info, type, handle, message_timer from groups where id = $1
+`
+
+func (q *Queries) GetGroup(ctx context.Context, id uuid.UUID) (Group, error) {
+	row := q.db.QueryRowContext(ctx, getGroup, id)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.AvatarKey,
+		&i.MemberLimit,
+		&i.OnlyAdminsCanPost,
+		&i.OnlyAdminsCanEditInfo,
+		&i.Type,
+		&i.Handle,
+		&i.MessageTimer,
+	)
+	return i, err
+}
+
 const getGroupAdmins = `-- name: GetGroupAdmins :many
-select groups.name, users.username from groups join group_admins on groups.id = group_admins.group_id join users on users.id = group_admins.user_id where groups.id = $1
+select users.id, users.username, users_groups.role from users_groups join users on users.id = users_groups.user_id
+where users_groups.group_id = $1 and users_groups.role in ('owner', 'admin')
 `
 
 type GetGroupAdminsRow struct {
-	Name     string
+	ID       uuid.UUID
 	Username string
+	Role     string
 }
 
-func (q *Queries) GetGroupAdmins(ctx context.Context, id uuid.UUID) ([]GetGroupAdminsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getGroupAdmins, id)
+func (q *Queries) GetGroupAdmins(ctx context.Context, groupID uuid.UUID) ([]GetGroupAdminsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getGroupAdmins, groupID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +152,7 @@ func (q *Queries) GetGroupAdmins(ctx context.Context, id uuid.UUID) ([]GetGroupA
 	var items []GetGroupAdminsRow
 	for rows.Next() {
 		var i GetGroupAdminsRow
-		if err := rows.Scan(&i.Name, &i.Username); err != nil {
+		if err := rows.Scan(&i.ID, &i.Username, &i.Role); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -86,8 +166,48 @@ func (q *Queries) GetGroupAdmins(ctx context.Context, id uuid.UUID) ([]GetGroupA
 	return items, nil
 }
 
+const getGroupByHandle = `-- name: GetGroupByHandle :one
+select id, name, created_at, updated_at, description, avatar_key, member_limit, only_admins_can_post, only_admins_can_edit_info, type, handle, message_timer from groups where handle = $1
+`
+
+func (q *Queries) GetGroupByHandle(ctx context.Context, handle sql.NullString) (Group, error) {
+	row := q.db.QueryRowContext(ctx, getGroupByHandle, handle)
+	var i Group
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Description,
+		&i.AvatarKey,
+		&i.MemberLimit,
+		&i.OnlyAdminsCanPost,
+		&i.OnlyAdminsCanEditInfo,
+		&i.Type,
+		&i.Handle,
+		&i.MessageTimer,
+	)
+	return i, err
+}
+
+const getGroupMemberRole = `-- name: GetGroupMemberRole :one
+select role from users_groups where user_id = $1 and group_id = $2
+`
+
+type GetGroupMemberRoleParams struct {
+	UserID  uuid.UUID
+	GroupID uuid.UUID
+}
+
+func (q *Queries) GetGroupMemberRole(ctx context.Context, arg GetGroupMemberRoleParams) (string, error) {
+	row := q.db.QueryRowContext(ctx, getGroupMemberRole, arg.UserID, arg.GroupID)
+	var role string
+	err := row.Scan(&role)
+	return role, err
+}
+
 const getGroupMembers = `-- name: GetGroupMembers :many
-select users.id, users.username from users
+select users.id, users.username, users_groups.role from users
 join users_groups on users.id = users_groups.user_id
 where users_groups.group_id = $1 and users.id != $2
 `
@@ -100,6 +220,7 @@ type GetGroupMembersParams struct {
 type GetGroupMembersRow struct {
 	ID       uuid.UUID
 	Username string
+	Role     string
 }
 
 func (q *Queries) GetGroupMembers(ctx context.Context, arg GetGroupMembersParams) ([]GetGroupMembersRow, error) {
@@ -111,7 +232,7 @@ func (q *Queries) GetGroupMembers(ctx context.Context, arg GetGroupMembersParams
 	var items []GetGroupMembersRow
 	for rows.Next() {
 		var i GetGroupMembersRow
-		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+		if err := rows.Scan(&i.ID, &i.Username, &i.Role); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -125,23 +246,84 @@ func (q *Queries) GetGroupMembers(ctx context.Context, arg GetGroupMembersParams
 	return items, nil
 }
 
-const getGroupMembersPhonenumbers = `-- name: GetGroupMembersPhonenumbers :many
-select users.phonenumber from users_groups join users on users_groups.user_id = users.id where users_groups.group_id = $1
+const getGroupMembersIDs = `-- name: GetGroupMembersIDs :many
+select user_id from users_groups where group_id = $1
 `
 
-func (q *Queries) GetGroupMembersPhonenumbers(ctx context.Context, groupID uuid.UUID) ([]string, error) {
-	rows, err := q.db.QueryContext(ctx, getGroupMembersPhonenumbers, groupID)
+func (q *Queries) GetGroupMembersIDs(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getGroupMembersIDs, groupID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []string
+	var items []uuid.UUID
 	for rows.Next() {
-		var phonenumber string
-		if err := rows.Scan(&phonenumber); err != nil {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
 			return nil, err
 		}
-		items = append(items, phonenumber)
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getOwnedGroupsIDs = `-- name: GetOwnedGroupsIDs :many
+select group_id from users_groups where user_id = $1 and role = 'owner'
+`
+
+func (q *Queries) GetOwnedGroupsIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getOwnedGroupsIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var group_id uuid.UUID
+		if err := rows.Scan(&group_id); err != nil {
+			return nil, err
+		}
+		items = append(items, group_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserGroupsForExport = `-- name: GetUserGroupsForExport :many
+select groups.id, groups.name, users_groups.created_at as joined_at from groups
+join users_groups on groups.id = users_groups.group_id where users_groups.user_id = $1
+`
+
+type GetUserGroupsForExportRow struct {
+	ID       uuid.UUID
+	Name     string
+	JoinedAt time.Time
+}
+
+func (q *Queries) GetUserGroupsForExport(ctx context.Context, userID uuid.UUID) ([]GetUserGroupsForExportRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserGroupsForExport, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserGroupsForExportRow
+	for rows.Next() {
+		var i GetUserGroupsForExportRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.JoinedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -163,83 +345,147 @@ func (q *Queries) GroupMembersCount(ctx context.Context, groupID uuid.UUID) (int
 	return count, err
 }
 
-const isUserAdmin = `-- name: IsUserAdmin :one
-select 1 from group_admins where user_id = $1 and group_id = $2
+const lockGroup = `-- name: LockGroup :one
+select id from groups where id = $1 for update
 `
 
-type IsUserAdminParams struct {
-	UserID  uuid.UUID
-	GroupID uuid.UUID
+func (q *Queries) LockGroup(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, lockGroup, id)
+	err := row.Scan(&id)
+	return id, err
 }
 
-func (q *Queries) IsUserAdmin(ctx context.Context, arg IsUserAdminParams) (int32, error) {
-	row := q.db.QueryRowContext(ctx, isUserAdmin, arg.UserID, arg.GroupID)
-	var column_1 int32
-	err := row.Scan(&column_1)
-	return column_1, err
+const promoteGroupSuccessor = `-- name: PromoteGroupSuccessor :one
+update users_groups set role = 'owner'
+where group_id = $1 and user_id = (
+    select members.user_id from users_groups as members where members.group_id = $1
+    order by case members.role when 'admin' then 1 when 'moderator' then 2 else 3 end, members.created_at
+    limit 1
+) and not exists (select 1 from users_groups as owners where owners.group_id = $1 and owners.role = 'owner')
+returning user_id
+`
+
+func (q *Queries) PromoteGroupSuccessor(ctx context.Context, groupID uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, promoteGroupSuccessor, groupID)
+	var user_id uuid.UUID
+	err := row.Scan(&user_id)
+	return user_id, err
 }
 
-const makeUserAdmin = `-- name: MakeUserAdmin :exec
-insert into group_admins(user_id, group_id, created_at)
-values($1, $2, NOW())
+const removeUserFromGroup = `-- name: RemoveUserFromGroup :exec
+delete from users_groups where user_id = $1 and group_id = $2
 `
 
-type MakeUserAdminParams struct {
+type RemoveUserFromGroupParams struct {
 	UserID  uuid.UUID
 	GroupID uuid.UUID
 }
 
-func (q *Queries) MakeUserAdmin(ctx context.Context, arg MakeUserAdminParams) error {
-	_, err := q.db.ExecContext(ctx, makeUserAdmin, arg.UserID, arg.GroupID)
+func (q *Queries) RemoveUserFromGroup(ctx context.Context, arg RemoveUserFromGroupParams) error {
+	_, err := q.db.ExecContext(ctx, removeUserFromGroup, arg.UserID, arg.GroupID)
 	return err
 }
 
-const removeUserFromAdmin = `-- name: RemoveUserFromAdmin :exec
-delete from group_admins where user_id = $1 and group_id = $2
+const updateGroup = `-- name: UpdateGroup :one
+update groups set name = $1, description = $2, updated_at = NOW() where id = $3
+returning name, description, updated_at
 `
 
-type RemoveUserFromAdminParams struct {
-	UserID  uuid.UUID
-	GroupID uuid.UUID
+type UpdateGroupParams struct {
+	Name        string
+	Description sql.NullString
+	ID          uuid.UUID
+}
+
+type UpdateGroupRow struct {
+	Name        string
+	Description sql.NullString
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdateGroup(ctx context.Context, arg UpdateGroupParams) (UpdateGroupRow, error) {
+	row := q.db.QueryRowContext(ctx, updateGroup, arg.Name, arg.Description, arg.ID)
+	var i UpdateGroupRow
+	err := row.Scan(&i.Name, &i.Description, &i.UpdatedAt)
+	return i, err
+}
+
+const updateGroupAvatar = `-- name: UpdateGroupAvatar :exec
+update groups set avatar_key = $1, updated_at = NOW() where id = $2
+`
+
+type UpdateGroupAvatarParams struct {
+	AvatarKey sql.NullString
+	ID        uuid.UUID
 }
 
-func (q *Queries) RemoveUserFromAdmin(ctx context.Context, arg RemoveUserFromAdminParams) error {
-	_, err := q.db.ExecContext(ctx, removeUserFromAdmin, arg.UserID, arg.GroupID)
+func (q *Queries) UpdateGroupAvatar(ctx context.Context, arg UpdateGroupAvatarParams) error {
+	_, err := q.db.ExecContext(ctx, updateGroupAvatar, arg.AvatarKey, arg.ID)
 	return err
 }
 
-const removeUserFromGroup = `-- name: RemoveUserFromGroup :exec
-delete from users_groups where user_id = $1 and group_id = $2
+const updateGroupMemberRole = `-- name: UpdateGroupMemberRole :exec
+update users_groups set role = $1 where user_id = $2 and group_id = $3
 `
 
-type RemoveUserFromGroupParams struct {
+type UpdateGroupMemberRoleParams struct {
+	Role    string
 	UserID  uuid.UUID
 	GroupID uuid.UUID
 }
 
-func (q *Queries) RemoveUserFromGroup(ctx context.Context, arg RemoveUserFromGroupParams) error {
-	_, err := q.db.ExecContext(ctx, removeUserFromGroup, arg.UserID, arg.GroupID)
+func (q *Queries) UpdateGroupMemberRole(ctx context.Context, arg UpdateGroupMemberRoleParams) error {
+	_, err := q.db.ExecContext(ctx, updateGroupMemberRole, arg.Role, arg.UserID, arg.GroupID)
 	return err
 }
 
-const updateGroup = `-- name: UpdateGroup :one
-update groups set name = $1 where id = $2
-returning name, updated_at
+const updateGroupMessageTimer = `-- name: UpdateGroupMessageTimer :exec
+update groups set message_timer = $1, updated_at = NOW() where id = $2
 `
 
-type UpdateGroupParams struct {
-	Name string
-	ID   uuid.UUID
+type UpdateGroupMessageTimerParams struct {
+	MessageTimer sql.NullInt32
+	ID           uuid.UUID
 }
 
-type UpdateGroupRow struct {
-	Name      string
-	UpdatedAt time.Time
+func (q *Queries) UpdateGroupMessageTimer(ctx context.Context, arg UpdateGroupMessageTimerParams) error {
+	_, err := q.db.ExecContext(ctx, updateGroupMessageTimer, arg.MessageTimer, arg.ID)
+	return err
 }
 
-func (q *Queries) UpdateGroup(ctx context.Context, arg UpdateGroupParams) (UpdateGroupRow, error) {
-	row := q.db.QueryRowContext(ctx, updateGroup, arg.Name, arg.ID)
-	var i UpdateGroupRow
-	err := row.Scan(&i.Name, &i.UpdatedAt)
+const updateGroupSettings = `-- name: UpdateGroupSettings :one
+update groups set member_limit = $1, only_admins_can_post = $2, only_admins_can_edit_info = $3, updated_at = NOW()
+where id = $4
+returning member_limit, only_admins_can_post, only_admins_can_edit_info, updated_at
+`
+
+type UpdateGroupSettingsParams struct {
+	MemberLimit           int32
+	OnlyAdminsCanPost     bool
+	OnlyAdminsCanEditInfo bool
+	ID                    uuid.UUID
+}
+
+type UpdateGroupSettingsRow struct {
+	MemberLimit           int32
+	OnlyAdminsCanPost     bool
+	OnlyAdminsCanEditInfo bool
+	UpdatedAt             time.Time
+}
+
+func (q *Queries) UpdateGroupSettings(ctx context.Context, arg UpdateGroupSettingsParams) (UpdateGroupSettingsRow, error) {
+	row := q.db.QueryRowContext(ctx, updateGroupSettings,
+		arg.MemberLimit,
+		arg.OnlyAdminsCanPost,
+		arg.OnlyAdminsCanEditInfo,
+		arg.ID,
+	)
+	var i UpdateGroupSettingsRow
+	err := row.Scan(
+		&i.MemberLimit,
+		&i.OnlyAdminsCanPost,
+		&i.OnlyAdminsCanEditInfo,
+		&i.UpdatedAt,
+	)
 	return i, err
 }
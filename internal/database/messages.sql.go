@@ -7,9 +7,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const addReceiverToGroupMessage = `-- name: AddReceiverToGroupMessage :exec
@@ -31,6 +34,34 @@ func (q *Queries) AddReceiverToGroupMessage(ctx context.Context, arg AddReceiver
 	return err
 }
 
+const anonymiseReceivedMessages = `-- name: AnonymiseReceivedMessages :exec
+update messages set reciever_id = $1 where reciever_id = $2
+`
+
+type AnonymiseReceivedMessagesParams struct {
+	DeletedUserID uuid.UUID
+	UserID        uuid.UUID
+}
+
+func (q *Queries) AnonymiseReceivedMessages(ctx context.Context, arg AnonymiseReceivedMessagesParams) error {
+	_, err := q.db.ExecContext(ctx, anonymiseReceivedMessages, arg.DeletedUserID, arg.UserID)
+	return err
+}
+
+const anonymiseSentMessages = `-- name: AnonymiseSentMessages :exec
+update messages set sender_id = $1 where sender_id = $2
+`
+
+type AnonymiseSentMessagesParams struct {
+	DeletedUserID uuid.UUID
+	UserID        uuid.UUID
+}
+
+func (q *Queries) AnonymiseSentMessages(ctx context.Context, arg AnonymiseSentMessagesParams) error {
+	_, err := q.db.ExecContext(ctx, anonymiseSentMessages, arg.DeletedUserID, arg.UserID)
+	return err
+}
+
 const countOfGroupMembersWhoReadMessage = `-- name: CountOfGroupMembersWhoReadMessage :one
 select count(*) from group_message_read where message_id = $1 and group_member_id = $2 and group_id = $3
 `
@@ -68,21 +99,28 @@ func (q *Queries) CountOfGroupMembersWhoReceivedMessage(ctx context.Context, arg
 const createMessage = `-- name: CreateMessage :one
 insert into messages(
     id, description, sender_id, reciever_id,
-    group_id, sent, created_at, updated_at
+    group_id, sent, expires_at, expires_after, forwarded, forward_count,
+    kind, content, created_at, updated_at
 )
 values(
     gen_random_uuid(),
-    $1, $2, $3, $4, $5, NOW(), NOW()
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW()
 )
-returning id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see
+returning id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see, expires_at, expires_after, edit_count, deleted_at, forwarded, forward_count, kind, content
 `
 
 type CreateMessageParams struct {
-	Description string
-	SenderID    uuid.UUID
-	RecieverID  uuid.NullUUID
-	GroupID     uuid.NullUUID
-	Sent        bool
+	Description  string
+	SenderID     uuid.UUID
+	RecieverID   uuid.NullUUID
+	GroupID      uuid.NullUUID
+	Sent         bool
+	ExpiresAt    sql.NullTime
+	ExpiresAfter sql.NullInt32
+	Forwarded    bool
+	ForwardCount int32
+	Kind         string
+	Content      json.RawMessage
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
@@ -92,6 +130,12 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		arg.RecieverID,
 		arg.GroupID,
 		arg.Sent,
+		arg.ExpiresAt,
+		arg.ExpiresAfter,
+		arg.Forwarded,
+		arg.ForwardCount,
+		arg.Kind,
+		arg.Content,
 	)
 	var i Message
 	err := row.Scan(
@@ -107,10 +151,74 @@ func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (M
 		&i.Read,
 		&i.IsSenderAllowedToSee,
 		&i.IsReceiverAllowedToSee,
+		&i.ExpiresAt,
+		&i.ExpiresAfter,
+		&i.EditCount,
+		&i.DeletedAt,
+		&i.Forwarded,
+		&i.ForwardCount,
+		&i.Kind,
+		&i.Content,
 	)
 	return i, err
 }
 
+const deleteExpiredMessages = `-- name: DeleteExpiredMessages :many
+delete from messages where id in (
+    select id from messages where expires_at <= NOW()
+    order by expires_at limit $1
+    for update skip locked
+)
+returning id, sender_id, reciever_id, group_id
+`
+
+type DeleteExpiredMessagesRow struct {
+	ID         uuid.UUID
+	SenderID   uuid.UUID
+	RecieverID uuid.NullUUID
+	GroupID    uuid.NullUUID
+}
+
+func (q *Queries) DeleteExpiredMessages(ctx context.Context, limit int32) ([]DeleteExpiredMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, deleteExpiredMessages, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DeleteExpiredMessagesRow
+	for rows.Next() {
+		var i DeleteExpiredMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SenderID,
+			&i.RecieverID,
+			&i.GroupID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteMessageForEveryone = `-- name: DeleteMessageForEveryone :execrows
+update messages set description = '', content = '{}', deleted_at = NOW(), updated_at = NOW() where id = $1 and deleted_at is null
+`
+
+func (q *Queries) DeleteMessageForEveryone(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteMessageForEveryone, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getAllGroupConversations = `-- name: GetAllGroupConversations :many
 select distinct messages.group_id as group_id, groups.name as group_name from messages join groups on messages.group_id = groups.id where messages.sender_id = $1
 `
@@ -144,7 +252,11 @@ func (q *Queries) GetAllGroupConversations(ctx context.Context, senderID uuid.UU
 }
 
 const getAllGroupMessages = `-- name: GetAllGroupMessages :many
-select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see from messages where group_id = $1 and created_at < $2 order by created_at limit 10
+select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see, expires_at, expires_after, edit_count, deleted_at, forwarded, forward_count, kind, content from (
+    select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see, expires_at, expires_after, edit_count, deleted_at, forwarded, forward_count, kind, content from messages where group_id = $1 and created_at < $2
+    and (expires_at is null or expires_at > NOW())
+    order by created_at desc limit 10
+) as page order by created_at
 `
 
 type GetAllGroupMessagesParams struct {
@@ -174,6 +286,14 @@ func (q *Queries) GetAllGroupMessages(ctx context.Context, arg GetAllGroupMessag
 			&i.Read,
 			&i.IsSenderAllowedToSee,
 			&i.IsReceiverAllowedToSee,
+			&i.ExpiresAt,
+			&i.ExpiresAfter,
+			&i.EditCount,
+			&i.DeletedAt,
+			&i.Forwarded,
+			&i.ForwardCount,
+			&i.Kind,
+			&i.Content,
 		); err != nil {
 			return nil, err
 		}
@@ -189,17 +309,22 @@ func (q *Queries) GetAllGroupMessages(ctx context.Context, arg GetAllGroupMessag
 }
 
 const getAllMessages = `-- name: GetAllMessages :many
-select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see from messages where sender_id = $1 and reciever_id = $2 and created_at < $3 order by created_at limit 10
+select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see, expires_at, expires_after, edit_count, deleted_at, forwarded, forward_count, kind, content from (
+    select id, description, sender_id, reciever_id, group_id, sent, recieved, created_at, updated_at, read, is_sender_allowed_to_see, is_receiver_allowed_to_see, expires_at, expires_after, edit_count, deleted_at, forwarded, forward_count, kind, content from messages
+    where ((sender_id = $1::uuid and reciever_id = $2::uuid) or (sender_id = $2::uuid and reciever_id = $1::uuid))
+    and created_at < $3 and (expires_at is null or expires_at > NOW())
+    order by created_at desc limit 10
+) as page order by created_at
 `
 
 type GetAllMessagesParams struct {
-	SenderID   uuid.UUID
-	RecieverID uuid.NullUUID
-	CreatedAt  time.Time
+	UserID      uuid.UUID
+	OtherUserID uuid.UUID
+	Before      time.Time
 }
 
 func (q *Queries) GetAllMessages(ctx context.Context, arg GetAllMessagesParams) ([]Message, error) {
-	rows, err := q.db.QueryContext(ctx, getAllMessages, arg.SenderID, arg.RecieverID, arg.CreatedAt)
+	rows, err := q.db.QueryContext(ctx, getAllMessages, arg.UserID, arg.OtherUserID, arg.Before)
 	if err != nil {
 		return nil, err
 	}
@@ -220,6 +345,14 @@ func (q *Queries) GetAllMessages(ctx context.Context, arg GetAllMessagesParams)
 			&i.Read,
 			&i.IsSenderAllowedToSee,
 			&i.IsReceiverAllowedToSee,
+			&i.ExpiresAt,
+			&i.ExpiresAfter,
+			&i.EditCount,
+			&i.DeletedAt,
+			&i.Forwarded,
+			&i.ForwardCount,
+			&i.Kind,
+			&i.Content,
 		); err != nil {
 			return nil, err
 		}
@@ -341,6 +474,74 @@ func (q *Queries) GetLatestMessagesByRecieverID(ctx context.Context, arg GetLate
 	return items, nil
 }
 
+const getMessageForDelete = `-- name: GetMessageForDelete :one
+select sender_id, reciever_id, group_id, kind, deleted_at,
+created_at > NOW() - make_interval(secs => $1::integer) as deletable_for_everyone
+from messages where id = $2
+`
+
+type GetMessageForDeleteParams struct {
+	DeleteWindow int32
+	ID           uuid.UUID
+}
+
+type GetMessageForDeleteRow struct {
+	SenderID             uuid.UUID
+	RecieverID           uuid.NullUUID
+	GroupID              uuid.NullUUID
+	Kind                 string
+	DeletedAt            sql.NullTime
+	DeletableForEveryone bool
+}
+
+func (q *Queries) GetMessageForDelete(ctx context.Context, arg GetMessageForDeleteParams) (GetMessageForDeleteRow, error) {
+	row := q.db.QueryRowContext(ctx, getMessageForDelete, arg.DeleteWindow, arg.ID)
+	var i GetMessageForDeleteRow
+	err := row.Scan(
+		&i.SenderID,
+		&i.RecieverID,
+		&i.GroupID,
+		&i.Kind,
+		&i.DeletedAt,
+		&i.DeletableForEveryone,
+	)
+	return i, err
+}
+
+const getMessageForForward = `-- name: GetMessageForForward :one
+select description, sender_id, reciever_id, group_id, is_sender_allowed_to_see, is_receiver_allowed_to_see, forward_count, kind, content
+from messages where id = $1 and deleted_at is null and (expires_at is null or expires_at > NOW())
+`
+
+type GetMessageForForwardRow struct {
+	Description            string
+	SenderID               uuid.UUID
+	RecieverID             uuid.NullUUID
+	GroupID                uuid.NullUUID
+	IsSenderAllowedToSee   bool
+	IsReceiverAllowedToSee bool
+	ForwardCount           int32
+	Kind                   string
+	Content                json.RawMessage
+}
+
+func (q *Queries) GetMessageForForward(ctx context.Context, id uuid.UUID) (GetMessageForForwardRow, error) {
+	row := q.db.QueryRowContext(ctx, getMessageForForward, id)
+	var i GetMessageForForwardRow
+	err := row.Scan(
+		&i.Description,
+		&i.SenderID,
+		&i.RecieverID,
+		&i.GroupID,
+		&i.IsSenderAllowedToSee,
+		&i.IsReceiverAllowedToSee,
+		&i.ForwardCount,
+		&i.Kind,
+		&i.Content,
+	)
+	return i, err
+}
+
 const getMessageSenderReceiverAndGroupID = `-- name: GetMessageSenderReceiverAndGroupID :one
 select sender_id, reciever_id, group_id from messages where id = $1
 `
@@ -358,6 +559,170 @@ func (q *Queries) GetMessageSenderReceiverAndGroupID(ctx context.Context, id uui
 	return i, err
 }
 
+const getMessagesHiddenFromGroupMember = `-- name: GetMessagesHiddenFromGroupMember :many
+select message_id from group_message_receivers
+where group_id = $1 and member_id = $2 and message_id = any($3::uuid[]) and is_allowed_to_see = false
+`
+
+type GetMessagesHiddenFromGroupMemberParams struct {
+	GroupID    uuid.UUID
+	MemberID   uuid.UUID
+	MessageIds []uuid.UUID
+}
+
+func (q *Queries) GetMessagesHiddenFromGroupMember(ctx context.Context, arg GetMessagesHiddenFromGroupMemberParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getMessagesHiddenFromGroupMember, arg.GroupID, arg.MemberID, pq.Array(arg.MessageIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var message_id uuid.UUID
+		if err := rows.Scan(&message_id); err != nil {
+			return nil, err
+		}
+		items = append(items, message_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserConversations = `-- name: GetUserConversations :many
+select reciever_id as other_user_id, group_id from messages where sender_id = $1::uuid
+union
+select sender_id as other_user_id, group_id from messages where reciever_id = $1::uuid
+`
+
+type GetUserConversationsRow struct {
+	OtherUserID uuid.NullUUID
+	GroupID     uuid.NullUUID
+}
+
+func (q *Queries) GetUserConversations(ctx context.Context, userID uuid.UUID) ([]GetUserConversationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserConversations, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserConversationsRow
+	for rows.Next() {
+		var i GetUserConversationsRow
+		if err := rows.Scan(&i.OtherUserID, &i.GroupID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserMessagesForExport = `-- name: GetUserMessagesForExport :many
+select id, description, sender_id, reciever_id, group_id, created_at, updated_at from messages
+where sender_id = $1 or reciever_id = $1 order by created_at
+`
+
+type GetUserMessagesForExportRow struct {
+	ID          uuid.UUID
+	Description string
+	SenderID    uuid.UUID
+	RecieverID  uuid.NullUUID
+	GroupID     uuid.NullUUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) GetUserMessagesForExport(ctx context.Context, senderID uuid.UUID) ([]GetUserMessagesForExportRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserMessagesForExport, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserMessagesForExportRow
+	for rows.Next() {
+		var i GetUserMessagesForExportRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Description,
+			&i.SenderID,
+			&i.RecieverID,
+			&i.GroupID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hasSentMessageTo = `-- name: HasSentMessageTo :one
+select exists(select 1 from messages where sender_id = $1 and reciever_id = $2)
+`
+
+type HasSentMessageToParams struct {
+	SenderID   uuid.UUID
+	RecieverID uuid.NullUUID
+}
+
+func (q *Queries) HasSentMessageTo(ctx context.Context, arg HasSentMessageToParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, hasSentMessageTo, arg.SenderID, arg.RecieverID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const hideMessageForReceiver = `-- name: HideMessageForReceiver :execrows
+update messages set is_receiver_allowed_to_see = false where id = $1 and reciever_id = $2
+`
+
+type HideMessageForReceiverParams struct {
+	ID         uuid.UUID
+	RecieverID uuid.NullUUID
+}
+
+func (q *Queries) HideMessageForReceiver(ctx context.Context, arg HideMessageForReceiverParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideMessageForReceiver, arg.ID, arg.RecieverID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const hideMessageForSender = `-- name: HideMessageForSender :execrows
+update messages set is_sender_allowed_to_see = false where id = $1 and sender_id = $2
+`
+
+type HideMessageForSenderParams struct {
+	ID       uuid.UUID
+	SenderID uuid.UUID
+}
+
+func (q *Queries) HideMessageForSender(ctx context.Context, arg HideMessageForSenderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, hideMessageForSender, arg.ID, arg.SenderID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const isGroupMemberAllowedToSeeMessage = `-- name: IsGroupMemberAllowedToSeeMessage :one
 select is_allowed_to_see from group_message_receivers where message_id = $1 and group_id = $2 and member_id = $3
 `
@@ -493,15 +858,22 @@ func (q *Queries) MarkIsSenderAllowedToSeeFalse(ctx context.Context, arg MarkIsS
 }
 
 const markMessageRead = `-- name: MarkMessageRead :one
-update messages set read = true and updated_at = NOW() where id = $1
-returning updated_at
+update messages set read = true, updated_at = NOW(),
+expires_at = coalesce(expires_at, NOW() + make_interval(secs => expires_after))
+where id = $1
+returning updated_at, expires_at
 `
 
-func (q *Queries) MarkMessageRead(ctx context.Context, id uuid.UUID) (time.Time, error) {
+type MarkMessageReadRow struct {
+	UpdatedAt time.Time
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) MarkMessageRead(ctx context.Context, id uuid.UUID) (MarkMessageReadRow, error) {
 	row := q.db.QueryRowContext(ctx, markMessageRead, id)
-	var updated_at time.Time
-	err := row.Scan(&updated_at)
-	return updated_at, err
+	var i MarkMessageReadRow
+	err := row.Scan(&i.UpdatedAt, &i.ExpiresAt)
+	return i, err
 }
 
 const markMessageReceived = `-- name: MarkMessageReceived :one
@@ -517,15 +889,14 @@ func (q *Queries) MarkMessageReceived(ctx context.Context, id uuid.UUID) (time.T
 }
 
 const updateMessage = `-- name: UpdateMessage :one
-update messages set description = $1, updated_at = NOW() where id = $2 and sender_id = $3 and group_id = $4
-returning description, sender_id, reciever_id, group_id, sent, recieved, read, is_receiver_allowed_to_see, created_at, updated_at
+update messages set description = $1, edit_count = edit_count + 1, updated_at = NOW() where id = $2 and sender_id = $3
+returning description, sender_id, reciever_id, group_id, sent, recieved, read, is_receiver_allowed_to_see, edit_count, created_at, updated_at
 `
 
 type UpdateMessageParams struct {
 	Description string
 	ID          uuid.UUID
 	SenderID    uuid.UUID
-	GroupID     uuid.NullUUID
 }
 
 type UpdateMessageRow struct {
@@ -537,17 +908,13 @@ type UpdateMessageRow struct {
 	Recieved               bool
 	Read                   bool
 	IsReceiverAllowedToSee bool
+	EditCount              int32
 	CreatedAt              time.Time
 	UpdatedAt              time.Time
 }
 
 func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) (UpdateMessageRow, error) {
-	row := q.db.QueryRowContext(ctx, updateMessage,
-		arg.Description,
-		arg.ID,
-		arg.SenderID,
-		arg.GroupID,
-	)
+	row := q.db.QueryRowContext(ctx, updateMessage, arg.Description, arg.ID, arg.SenderID)
 	var i UpdateMessageRow
 	err := row.Scan(
 		&i.Description,
@@ -558,6 +925,7 @@ func (q *Queries) UpdateMessage(ctx context.Context, arg UpdateMessageParams) (U
 		&i.Recieved,
 		&i.Read,
 		&i.IsReceiverAllowedToSee,
+		&i.EditCount,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 	)
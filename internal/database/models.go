@@ -6,21 +6,76 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Group struct {
-	ID        uuid.UUID
-	Name      string
+type BlockedUser struct {
+	UserID        uuid.UUID
+	BlockedUserID uuid.UUID
+	CreatedAt     time.Time
+}
+
+type Contact struct {
+	UserID    uuid.UUID
+	ContactID uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
 }
 
-type GroupAdmin struct {
+type ConversationSetting struct {
+	UserID         uuid.UUID
+	OtherUserID    uuid.NullUUID
+	GroupID        uuid.NullUUID
+	ConversationID uuid.UUID
+	MutedUntil     sql.NullTime
+	Archived       bool
+	PinnedAt       sql.NullTime
+	UpdatedAt      time.Time
+}
+
+type ConversationTimer struct {
+	UserID      uuid.UUID
+	OtherUserID uuid.UUID
+	Duration    int32
+	StartsOn    string
+	UpdatedBy   uuid.UUID
+	UpdatedAt   time.Time
+}
+
+type Group struct {
+	ID                    uuid.UUID
+	Name                  string
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Description           sql.NullString
+	AvatarKey             sql.NullString
+	MemberLimit           int32
+	OnlyAdminsCanPost     bool
+	OnlyAdminsCanEditInfo bool
+	Type                  string
+	Handle                sql.NullString
+	MessageTimer          sql.NullInt32
+}
+
+type GroupInvite struct {
+	ID               uuid.UUID
+	GroupID          uuid.UUID
+	Code             string
+	CreatedBy        uuid.UUID
+	ExpiresAt        sql.NullTime
+	MaxUses          sql.NullInt32
+	Uses             int32
+	RequiresApproval bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type GroupJoinRequest struct {
 	GroupID   uuid.UUID
 	UserID    uuid.UUID
+	InviteID  uuid.NullUUID
 	CreatedAt time.Time
 }
 
@@ -58,6 +113,92 @@ type Message struct {
 	Read                   bool
 	IsSenderAllowedToSee   bool
 	IsReceiverAllowedToSee bool
+	ExpiresAt              sql.NullTime
+	ExpiresAfter           sql.NullInt32
+	EditCount              int32
+	DeletedAt              sql.NullTime
+	Forwarded              bool
+	ForwardCount           int32
+	Kind                   string
+	Content                json.RawMessage
+}
+
+type MessageEdit struct {
+	ID          uuid.UUID
+	MessageID   uuid.UUID
+	Description string
+	EditedAt    time.Time
+}
+
+type MessageLinkPreview struct {
+	MessageID   uuid.UUID
+	Url         string
+	Title       string
+	Description string
+	ImageUrl    string
+	CreatedAt   time.Time
+}
+
+type MessageMention struct {
+	MessageID uuid.UUID
+	UserID    uuid.UUID
+	GroupID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type MessageRequest struct {
+	SenderID   uuid.UUID
+	ReceiverID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type PasswordResetToken struct {
+	Token     string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+type PhonenumberChangeRequest struct {
+	UserID         uuid.UUID
+	NewPhonenumber string
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
+
+type PinnedMessage struct {
+	MessageID uuid.UUID
+	PinnedBy  uuid.UUID
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ID             uuid.UUID
+	MessageID      uuid.UUID
+	GroupID        uuid.UUID
+	CreatedBy      uuid.UUID
+	Question       string
+	MultipleChoice bool
+	Anonymous      bool
+	ClosesAt       sql.NullTime
+	ClosedAt       sql.NullTime
+	CreatedAt      time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
+	OptionID  uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -67,18 +208,41 @@ type RefreshToken struct {
 	ExpiresAt time.Time
 }
 
+type ScheduledMessage struct {
+	ID          uuid.UUID
+	SenderID    uuid.UUID
+	ReceiverID  uuid.NullUUID
+	GroupID     uuid.NullUUID
+	Description string
+	SendAt      time.Time
+	Status      string
+	MessageID   uuid.NullUUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
 type User struct {
-	ID            uuid.UUID
-	Phonenumber   string
-	Username      string
-	Password      string
-	LastAvailable sql.NullTime
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	ID                  uuid.UUID
+	Phonenumber         string
+	Username            string
+	Password            string
+	LastAvailable       sql.NullTime
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	DeletionScheduledAt sql.NullTime
+	DisplayName         sql.NullString
+	Status              sql.NullString
+	AvatarKey           sql.NullString
+	LastSeenPrivacy     string
+	ReadReceiptsPrivacy string
+	ProfilePhotoPrivacy string
+	PhonenumberHash     sql.NullString
+	Deleted             bool
 }
 
 type UsersGroup struct {
 	UserID    uuid.UUID
 	GroupID   uuid.UUID
 	CreatedAt time.Time
+	Role      string
 }
@@ -13,18 +13,31 @@ import (
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :execrows
+update users set deletion_scheduled_at = null, updated_at = NOW() where id = $1 and deletion_scheduled_at is not null
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createUser = `-- name: CreateUser :one
-insert into users(id, phonenumber, username, password, created_at, updated_at)
+insert into users(id, phonenumber, phonenumber_hash, username, password, created_at, updated_at)
 values(
-    gen_random_uuid(), $1, $2, $3, NOW(), NOW()
+    gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW()
 )
 returning id, phonenumber, username, created_at, updated_at
 `
 
 type CreateUserParams struct {
-	Phonenumber string
-	Username    string
-	Password    string
+	Phonenumber     string
+	PhonenumberHash sql.NullString
+	Username        string
+	Password        string
 }
 
 type CreateUserRow struct {
@@ -36,7 +49,12 @@ type CreateUserRow struct {
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Phonenumber, arg.Username, arg.Password)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Phonenumber,
+		arg.PhonenumberHash,
+		arg.Username,
+		arg.Password,
+	)
 	var i CreateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -48,6 +66,20 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 	return i, err
 }
 
+const createDeletedUser = `-- name: CreateDeletedUser :one
+insert into users(id, phonenumber, username, password, deleted, created_at, updated_at)
+select new_user.id, 'deleted:' || new_user.id::text, 'deleted user', '', true, NOW(), NOW()
+from (select gen_random_uuid() as id) as new_user
+returning id
+`
+
+func (q *Queries) CreateDeletedUser(ctx context.Context) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, createDeletedUser)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
 const doesUserExist = `-- name: DoesUserExist :one
 select 1 from users where phonenumber = $1
 `
@@ -107,6 +139,17 @@ func (q *Queries) GetUserByPhonenumber(ctx context.Context, phonenumber string)
 	return i, err
 }
 
+const getUserPasswordByID = `-- name: GetUserPasswordByID :one
+select password from users where id = $1
+`
+
+func (q *Queries) GetUserPasswordByID(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getUserPasswordByID, id)
+	var password string
+	err := row.Scan(&password)
+	return password, err
+}
+
 const getUserPhonenumberByID = `-- name: GetUserPhonenumberByID :one
 select phonenumber from users where id = $1
 `
@@ -118,6 +161,163 @@ func (q *Queries) GetUserPhonenumberByID(ctx context.Context, id uuid.UUID) (str
 	return phonenumber, err
 }
 
+const getUserPrivacySettings = `-- name: GetUserPrivacySettings :one
+select last_seen_privacy, read_receipts_privacy, profile_photo_privacy from users where id = $1
+`
+
+type GetUserPrivacySettingsRow struct {
+	LastSeenPrivacy     string
+	ReadReceiptsPrivacy string
+	ProfilePhotoPrivacy string
+}
+
+func (q *Queries) GetUserPrivacySettings(ctx context.Context, id uuid.UUID) (GetUserPrivacySettingsRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserPrivacySettings, id)
+	var i GetUserPrivacySettingsRow
+	err := row.Scan(&i.LastSeenPrivacy, &i.ReadReceiptsPrivacy, &i.ProfilePhotoPrivacy)
+	return i, err
+}
+
+const getUserProfile = `-- name: GetUserProfile :one
+select id, username, display_name, status, avatar_key, last_available, last_seen_privacy,
+read_receipts_privacy, profile_photo_privacy, created_at from users where id = $1
+`
+
+type GetUserProfileRow struct {
+	ID                  uuid.UUID
+	Username            string
+	DisplayName         sql.NullString
+	Status              sql.NullString
+	AvatarKey           sql.NullString
+	LastAvailable       sql.NullTime
+	LastSeenPrivacy     string
+	ReadReceiptsPrivacy string
+	ProfilePhotoPrivacy string
+	CreatedAt           time.Time
+}
+
+func (q *Queries) GetUserProfile(ctx context.Context, id uuid.UUID) (GetUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfile, id)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Status,
+		&i.AvatarKey,
+		&i.LastAvailable,
+		&i.LastSeenPrivacy,
+		&i.ReadReceiptsPrivacy,
+		&i.ProfilePhotoPrivacy,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserProfileForExport = `-- name: GetUserProfileForExport :one
+select id, phonenumber, username, last_available, deletion_scheduled_at, created_at, updated_at from users where id = $1
+`
+
+type GetUserProfileForExportRow struct {
+	ID                  uuid.UUID
+	Phonenumber         string
+	Username            string
+	LastAvailable       sql.NullTime
+	DeletionScheduledAt sql.NullTime
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+func (q *Queries) GetUserProfileForExport(ctx context.Context, id uuid.UUID) (GetUserProfileForExportRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfileForExport, id)
+	var i GetUserProfileForExportRow
+	err := row.Scan(
+		&i.ID,
+		&i.Phonenumber,
+		&i.Username,
+		&i.LastAvailable,
+		&i.DeletionScheduledAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUsersDueForDeletion = `-- name: GetUsersDueForDeletion :many
+select id from users where deletion_scheduled_at <= NOW()
+`
+
+func (q *Queries) GetUsersDueForDeletion(ctx context.Context) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDueForDeletion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersWithoutPhonenumberHash = `-- name: GetUsersWithoutPhonenumberHash :many
+select id, phonenumber from users where phonenumber_hash is null and not deleted
+`
+
+type GetUsersWithoutPhonenumberHashRow struct {
+	ID          uuid.UUID
+	Phonenumber string
+}
+
+func (q *Queries) GetUsersWithoutPhonenumberHash(ctx context.Context) ([]GetUsersWithoutPhonenumberHashRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersWithoutPhonenumberHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersWithoutPhonenumberHashRow
+	for rows.Next() {
+		var i GetUsersWithoutPhonenumberHashRow
+		if err := rows.Scan(&i.ID, &i.Phonenumber); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isUserContact = `-- name: IsUserContact :one
+select exists(select 1 from contacts where user_id = $1 and contact_id = $2)
+`
+
+type IsUserContactParams struct {
+	UserID    uuid.UUID
+	ContactID uuid.UUID
+}
+
+func (q *Queries) IsUserContact(ctx context.Context, arg IsUserContactParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isUserContact, arg.UserID, arg.ContactID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const removeUser = `-- name: RemoveUser :exec
 delete from users where id = $1
 `
@@ -127,12 +327,40 @@ func (q *Queries) RemoveUser(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
+update users set deletion_scheduled_at = $1, updated_at = NOW() where id = $2
+`
+
+type ScheduleUserDeletionParams struct {
+	DeletionScheduledAt sql.NullTime
+	ID                  uuid.UUID
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleUserDeletion, arg.DeletionScheduledAt, arg.ID)
+	return err
+}
+
 const setLastAvailable = `-- name: SetLastAvailable :exec
-update users set last_available = NOW() where phonenumber = $1
+update users set last_available = NOW() where id = $1
+`
+
+func (q *Queries) SetLastAvailable(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, setLastAvailable, id)
+	return err
+}
+
+const setPhonenumberHash = `-- name: SetPhonenumberHash :exec
+update users set phonenumber_hash = $1 where id = $2
 `
 
-func (q *Queries) SetLastAvailable(ctx context.Context, phonenumber string) error {
-	_, err := q.db.ExecContext(ctx, setLastAvailable, phonenumber)
+type SetPhonenumberHashParams struct {
+	PhonenumberHash sql.NullString
+	ID              uuid.UUID
+}
+
+func (q *Queries) SetPhonenumberHash(ctx context.Context, arg SetPhonenumberHashParams) error {
+	_, err := q.db.ExecContext(ctx, setPhonenumberHash, arg.PhonenumberHash, arg.ID)
 	return err
 }
 
@@ -151,19 +379,89 @@ func (q *Queries) UpdatePassword(ctx context.Context, arg UpdatePasswordParams)
 }
 
 const updatePhonenumber = `-- name: UpdatePhonenumber :exec
-update users set phonenumber = $1 where id = $2
+update users set phonenumber = $1, phonenumber_hash = $2 where id = $3
 `
 
 type UpdatePhonenumberParams struct {
-	Phonenumber string
-	ID          uuid.UUID
+	Phonenumber     string
+	PhonenumberHash sql.NullString
+	ID              uuid.UUID
 }
 
 func (q *Queries) UpdatePhonenumber(ctx context.Context, arg UpdatePhonenumberParams) error {
-	_, err := q.db.ExecContext(ctx, updatePhonenumber, arg.Phonenumber, arg.ID)
+	_, err := q.db.ExecContext(ctx, updatePhonenumber, arg.Phonenumber, arg.PhonenumberHash, arg.ID)
 	return err
 }
 
+const updateUserAvatar = `-- name: UpdateUserAvatar :exec
+update users set avatar_key = $1, updated_at = NOW() where id = $2
+`
+
+type UpdateUserAvatarParams struct {
+	AvatarKey sql.NullString
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserAvatar, arg.AvatarKey, arg.ID)
+	return err
+}
+
+const updateUserPrivacySettings = `-- name: UpdateUserPrivacySettings :one
+update users set last_seen_privacy = $1, read_receipts_privacy = $2, profile_photo_privacy = $3, updated_at = NOW()
+where id = $4
+returning last_seen_privacy, read_receipts_privacy, profile_photo_privacy
+`
+
+type UpdateUserPrivacySettingsParams struct {
+	LastSeenPrivacy     string
+	ReadReceiptsPrivacy string
+	ProfilePhotoPrivacy string
+	ID                  uuid.UUID
+}
+
+type UpdateUserPrivacySettingsRow struct {
+	LastSeenPrivacy     string
+	ReadReceiptsPrivacy string
+	ProfilePhotoPrivacy string
+}
+
+func (q *Queries) UpdateUserPrivacySettings(ctx context.Context, arg UpdateUserPrivacySettingsParams) (UpdateUserPrivacySettingsRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUserPrivacySettings,
+		arg.LastSeenPrivacy,
+		arg.ReadReceiptsPrivacy,
+		arg.ProfilePhotoPrivacy,
+		arg.ID,
+	)
+	var i UpdateUserPrivacySettingsRow
+	err := row.Scan(&i.LastSeenPrivacy, &i.ReadReceiptsPrivacy, &i.ProfilePhotoPrivacy)
+	return i, err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+update users set display_name = $1, status = $2, updated_at = NOW() where id = $3
+returning display_name, status, updated_at
+`
+
+type UpdateUserProfileParams struct {
+	DisplayName sql.NullString
+	Status      sql.NullString
+	ID          uuid.UUID
+}
+
+type UpdateUserProfileRow struct {
+	DisplayName sql.NullString
+	Status      sql.NullString
+	UpdatedAt   time.Time
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (UpdateUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile, arg.DisplayName, arg.Status, arg.ID)
+	var i UpdateUserProfileRow
+	err := row.Scan(&i.DisplayName, &i.Status, &i.UpdatedAt)
+	return i, err
+}
+
 const updateUsername = `-- name: UpdateUsername :one
 update users set username = $1 where id = $2
 returning username
@@ -0,0 +1,80 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type cachedPreview struct {
+	preview   Preview
+	err       error
+	expiresAt time.Time
+}
+
+/*
+CachingFetcher remembers the previews generated by another fetcher so that a link shared in many
+conversations is fetched once. Links without a preview are remembered as well so they are not
+fetched again for every message. When the cache is full the expired entries are dropped and if
+none expired the whole cache is cleared
+*/
+type CachingFetcher struct {
+	fetcher    Fetcher
+	ttl        time.Duration
+	maxEntries int
+	previews   map[string]cachedPreview
+	mutex      sync.Mutex
+}
+
+func NewCachingFetcher(fetcher Fetcher, ttl time.Duration, maxEntries int) *CachingFetcher {
+	return &CachingFetcher{
+		fetcher:    fetcher,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		previews:   make(map[string]cachedPreview),
+	}
+}
+
+func (cf *CachingFetcher) Fetch(ctx context.Context, url string) (Preview, error) {
+	cf.mutex.Lock()
+	cached, ok := cf.previews[url]
+	cf.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.preview, cached.err
+	}
+
+	preview, err := cf.fetcher.Fetch(ctx, url)
+
+	// only the final answers are cached, a timeout or a network error may not happen again
+	if err == nil || errors.Is(err, ErrNoPreview) || errors.Is(err, ErrForbiddenHost) ||
+		errors.Is(err, ErrInvalidURL) || errors.Is(err, ErrUnsupportedURL) {
+		cf.store(url, cachedPreview{
+			preview:   preview,
+			err:       err,
+			expiresAt: time.Now().Add(cf.ttl),
+		})
+	}
+
+	return preview, err
+}
+
+func (cf *CachingFetcher) store(url string, cached cachedPreview) {
+	cf.mutex.Lock()
+	defer cf.mutex.Unlock()
+
+	if len(cf.previews) >= cf.maxEntries {
+		now := time.Now()
+		for key, value := range cf.previews {
+			if now.After(value.expiresAt) {
+				delete(cf.previews, key)
+			}
+		}
+
+		if len(cf.previews) >= cf.maxEntries {
+			clear(cf.previews)
+		}
+	}
+
+	cf.previews[url] = cached
+}
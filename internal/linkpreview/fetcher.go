@@ -0,0 +1,36 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNoPreview      = errors.New("page has no preview")
+	ErrForbiddenHost  = errors.New("host is not allowed")
+	ErrInvalidURL     = errors.New("invalid url")
+	ErrUnsupportedURL = errors.New("only http and https urls are supported")
+)
+
+// Preview is the OpenGraph style summary of a web page shown below a message containing its link
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	Image       string
+}
+
+// Fetcher is used to generate the preview of a link
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (Preview, error)
+}
+
+var linkRegex = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// FirstURL returns the first http or https link in the text without the punctuation following it, or an empty string
+func FirstURL(text string) string {
+	link := linkRegex.FindString(text)
+	return strings.TrimRight(link, `.,;:!?'")]}`)
+}
@@ -0,0 +1,214 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	maxRedirects         = 3
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+)
+
+var (
+	metaTagRegex   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributeRegex = regexp.MustCompile(`(?is)([a-z:_-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	titleTagRegex  = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	// shared address space used by carrier grade NAT which netip does not treat as private
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+/*
+HTTPFetcher fetches the page of the link and reads its OpenGraph tags. To protect against server side
+request forgery every address is checked when the connection is made, after the host name was resolved,
+so that neither redirects nor DNS rebinding can reach loopback, private or link local addresses.
+The time taken and the bytes read from a page are limited
+*/
+type HTTPFetcher struct {
+	client  *http.Client
+	maxSize int64
+
+	// allowAddress is called with the resolved "ip:port" of every connection, the tests replace it to reach their local server
+	allowAddress func(address string) error
+}
+
+func NewHTTPFetcher(timeout time.Duration, maxSize int64) *HTTPFetcher {
+	hf := &HTTPFetcher{
+		maxSize:      maxSize,
+		allowAddress: checkAddress,
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return hf.allowAddress(address)
+		},
+	}
+
+	hf.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would make the connection instead of the dialer so it is never used
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("too many redirects")
+			}
+			if request.URL.Scheme != "http" && request.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+
+	return hf
+}
+
+// checkAddress allows connections only to public unicast addresses on the http and https ports
+func checkAddress(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if port != "80" && port != "443" {
+		return fmt.Errorf("%w: port %s", ErrForbiddenHost, port)
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() ||
+		ip.IsUnspecified() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenHost, ip)
+	}
+
+	return nil
+}
+
+func (hf *HTTPFetcher) Fetch(ctx context.Context, link string) (Preview, error) {
+	pageURL, err := url.Parse(link)
+	if err != nil || pageURL.Host == "" {
+		return Preview{}, ErrInvalidURL
+	}
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return Preview{}, ErrUnsupportedURL
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	request.Header.Set("Accept", "text/html")
+	request.Header.Set("User-Agent", "TerTerChat-LinkPreview/1.0")
+
+	response, err := hf.client.Do(request)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("%w: status %d", ErrNoPreview, response.StatusCode)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return Preview{}, fmt.Errorf("%w: content type %s", ErrNoPreview, mediaType)
+	}
+
+	// reading only the beginning of the page, the meta tags are inside the head
+	page, err := io.ReadAll(io.LimitReader(response.Body, hf.maxSize))
+	if err != nil {
+		return Preview{}, err
+	}
+
+	// the url after the redirects is the one the relative image url is resolved against
+	preview := parsePreview(string(page), response.Request.URL)
+	if preview.Title == "" {
+		return Preview{}, ErrNoPreview
+	}
+	preview.URL = link
+
+	return preview, nil
+}
+
+// parsePreview reads the OpenGraph tags of the page falling back to the title and the description meta tags
+func parsePreview(page string, pageURL *url.URL) Preview {
+	tags := make(map[string]string)
+	for _, metaTag := range metaTagRegex.FindAllString(page, -1) {
+		attributes := make(map[string]string)
+		for _, attribute := range attributeRegex.FindAllStringSubmatch(metaTag, -1) {
+			attributes[strings.ToLower(attribute[1])] = attribute[2] + attribute[3]
+		}
+
+		name := attributes["property"]
+		if name == "" {
+			name = attributes["name"]
+		}
+		name = strings.ToLower(name)
+		if _, ok := tags[name]; name != "" && !ok {
+			tags[name] = clean(attributes["content"])
+		}
+	}
+
+	preview := Preview{
+		Title:       tags["og:title"],
+		Description: tags["og:description"],
+	}
+	if preview.Title == "" {
+		if title := titleTagRegex.FindStringSubmatch(page); title != nil {
+			preview.Title = clean(title[1])
+		}
+	}
+	if preview.Description == "" {
+		preview.Description = tags["description"]
+	}
+	preview.Title = truncate(preview.Title, maxTitleLength)
+	preview.Description = truncate(preview.Description, maxDescriptionLength)
+
+	if image, err := pageURL.Parse(tags["og:image"]); err == nil && tags["og:image"] != "" &&
+		(image.Scheme == "http" || image.Scheme == "https") {
+		preview.Image = image.String()
+	}
+
+	return preview
+}
+
+// clean unescapes the html entities and collapses the whitespace of the text
+func clean(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// truncate shortens the text to at most length runes
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+
+	return string(runes[:length])
+}
@@ -0,0 +1,324 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+newTestFetcher returns a fetcher which can connect to the local test server, every other address
+still goes through checkAddress so that the redirects leaving the server are checked as in production
+*/
+func newTestFetcher(t *testing.T, server *httptest.Server, maxSize int64) *HTTPFetcher {
+	t.Helper()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := NewHTTPFetcher(5*time.Second, maxSize)
+	fetcher.allowAddress = func(address string) error {
+		if address == serverURL.Host {
+			return nil
+		}
+		return checkAddress(address)
+	}
+	return fetcher
+}
+
+func servePage(page string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		allowed bool
+	}{
+		{name: "public http", address: "93.184.216.34:80", allowed: true},
+		{name: "public https", address: "93.184.216.34:443", allowed: true},
+		{name: "public ipv6", address: "[2606:2800:220:1:248:1893:25c8:1946]:443", allowed: true},
+		{name: "public on another port", address: "93.184.216.34:8080"},
+		{name: "loopback", address: "127.0.0.1:80"},
+		{name: "loopback range", address: "127.10.0.1:443"},
+		{name: "ipv6 loopback", address: "[::1]:80"},
+		{name: "ipv4 mapped loopback", address: "[::ffff:127.0.0.1]:80"},
+		{name: "private 10/8", address: "10.0.0.1:80"},
+		{name: "private 172.16/12", address: "172.16.5.4:443"},
+		{name: "private 192.168/16", address: "192.168.1.1:80"},
+		{name: "ipv6 unique local", address: "[fd00::1]:443"},
+		{name: "carrier grade nat", address: "100.64.0.1:80"},
+		{name: "carrier grade nat end", address: "100.127.255.254:443"},
+		{name: "link local metadata", address: "169.254.169.254:80"},
+		{name: "ipv6 link local", address: "[fe80::1]:80"},
+		{name: "unspecified", address: "0.0.0.0:80"},
+		{name: "broadcast", address: "255.255.255.255:80"},
+		{name: "multicast", address: "224.0.0.1:80"},
+		{name: "host name", address: "example.com:80"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkAddress(test.address)
+			if test.allowed && err != nil {
+				t.Fatalf("checkAddress(%q) = %v, want allowed", test.address, err)
+			}
+			if !test.allowed && err == nil {
+				t.Fatalf("checkAddress(%q) allowed the address", test.address)
+			}
+		})
+	}
+}
+
+func TestFetchRejectsLocalServer(t *testing.T) {
+	server := httptest.NewServer(servePage(`<title>local</title>`))
+	defer server.Close()
+
+	// without the test check the loopback address of the server must be refused
+	fetcher := NewHTTPFetcher(5*time.Second, 1024)
+	if _, err := fetcher.Fetch(context.Background(), server.URL); !errors.Is(err, ErrForbiddenHost) {
+		t.Fatalf("Fetch(%s) = %v, want %v", server.URL, err, ErrForbiddenHost)
+	}
+}
+
+func TestFetchURLs(t *testing.T) {
+	tests := []struct {
+		link string
+		err  error
+	}{
+		{link: "not a url", err: ErrInvalidURL},
+		{link: "ftp://example.com/file", err: ErrUnsupportedURL},
+		{link: "file:///etc/passwd", err: ErrInvalidURL},
+		{link: "http://10.0.0.1/", err: ErrForbiddenHost},
+		{link: "http://100.64.0.1/", err: ErrForbiddenHost},
+		{link: "http://[::1]/", err: ErrForbiddenHost},
+	}
+
+	fetcher := NewHTTPFetcher(5*time.Second, 1024)
+	for _, test := range tests {
+		t.Run(test.link, func(t *testing.T) {
+			if _, err := fetcher.Fetch(context.Background(), test.link); !errors.Is(err, test.err) {
+				t.Fatalf("Fetch(%q) = %v, want %v", test.link, err, test.err)
+			}
+		})
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", servePage(`<meta property="og:title" content="Redirected"><meta property="og:image" content="/images/cover.png">`))
+	mux.Handle("/once", http.RedirectHandler("/articles/page", http.StatusFound))
+	mux.Handle("/articles/page", http.RedirectHandler("/page", http.StatusMovedPermanently))
+	mux.Handle("/loop", http.RedirectHandler("/loop", http.StatusFound))
+	mux.Handle("/private", http.RedirectHandler("http://10.0.0.1/admin", http.StatusFound))
+	mux.Handle("/metadata", http.RedirectHandler("http://169.254.169.254/latest/meta-data", http.StatusFound))
+	mux.Handle("/cgnat", http.RedirectHandler("http://100.64.0.1/", http.StatusFound))
+	mux.Handle("/loopback", http.RedirectHandler("http://127.0.0.1/", http.StatusFound))
+	mux.Handle("/scheme", http.RedirectHandler("ftp://example.com/file", http.StatusFound))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newTestFetcher(t, server, 1024)
+
+	t.Run("followed", func(t *testing.T) {
+		link := server.URL + "/once"
+		preview, err := fetcher.Fetch(context.Background(), link)
+		if err != nil {
+			t.Fatalf("Fetch(%s) = %v", link, err)
+		}
+
+		// the preview keeps the link of the message while the image is resolved against the last page
+		want := Preview{
+			URL:   link,
+			Title: "Redirected",
+			Image: server.URL + "/images/cover.png",
+		}
+		if preview != want {
+			t.Fatalf("Fetch(%s) = %+v, want %+v", link, preview, want)
+		}
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		if _, err := fetcher.Fetch(context.Background(), server.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "too many redirects") {
+			t.Fatalf("Fetch followed a redirect loop: %v", err)
+		}
+	})
+
+	for _, path := range []string{"/private", "/metadata", "/cgnat", "/loopback"} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := fetcher.Fetch(context.Background(), server.URL+path); !errors.Is(err, ErrForbiddenHost) {
+				t.Fatalf("Fetch(%s) = %v, want %v", path, err, ErrForbiddenHost)
+			}
+		})
+	}
+
+	t.Run("scheme", func(t *testing.T) {
+		if _, err := fetcher.Fetch(context.Background(), server.URL+"/scheme"); !errors.Is(err, ErrUnsupportedURL) {
+			t.Fatalf("Fetch followed a redirect to ftp: %v", err)
+		}
+	})
+}
+
+func TestFetchResponses(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `<title>not found</title>`, http.StatusNotFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"title": "json"}`))
+	})
+	mux.HandleFunc("/untitled", servePage(`<html><head></head><body>no title</body></html>`))
+	mux.HandleFunc("/xhtml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml")
+		w.Write([]byte(`<title>xhtml</title>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newTestFetcher(t, server, 1024)
+
+	tests := []struct {
+		path  string
+		title string
+		err   error
+	}{
+		{path: "/missing", err: ErrNoPreview},
+		{path: "/json", err: ErrNoPreview},
+		{path: "/untitled", err: ErrNoPreview},
+		{path: "/xhtml", title: "xhtml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			preview, err := fetcher.Fetch(context.Background(), server.URL+test.path)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Fetch(%s) = %v, want %v", test.path, err, test.err)
+			}
+			if preview.Title != test.title {
+				t.Fatalf("Fetch(%s) title = %q, want %q", test.path, preview.Title, test.title)
+			}
+		})
+	}
+}
+
+func TestFetchOversizedBody(t *testing.T) {
+	const maxSize = 1024
+	padding := strings.Repeat("a", 4*maxSize)
+
+	mux := http.NewServeMux()
+	// the title is past the bytes which are read
+	mux.HandleFunc("/late", servePage(`<html><head><!--`+padding+`--><title>late</title></head></html>`))
+	// the page goes on long after the head, only its beginning is read
+	mux.HandleFunc("/endless", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><meta property="og:title" content="Endless"></head><body>`))
+		for range 1024 {
+			if _, err := w.Write([]byte(padding)); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newTestFetcher(t, server, maxSize)
+
+	if _, err := fetcher.Fetch(context.Background(), server.URL+"/late"); !errors.Is(err, ErrNoPreview) {
+		t.Fatalf("Fetch read past the size limit: %v", err)
+	}
+
+	preview, err := fetcher.Fetch(context.Background(), server.URL+"/endless")
+	if err != nil {
+		t.Fatalf("Fetch(/endless) = %v", err)
+	}
+	if preview.Title != "Endless" {
+		t.Fatalf("Fetch(/endless) title = %q, want %q", preview.Title, "Endless")
+	}
+}
+
+func TestParsePreview(t *testing.T) {
+	pageURL, err := url.Parse("https://example.com/blog/post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		page string
+		want Preview
+	}{
+		{
+			name: "open graph tags",
+			page: `<head>
+				<title>Page title</title>
+				<meta name="description" content="Page description">
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:image" content="https://cdn.example.com/cover.png">
+			</head>`,
+			want: Preview{Title: "OG title", Description: "OG description", Image: "https://cdn.example.com/cover.png"},
+		},
+		{
+			name: "fallback to title and description",
+			page: `<title>
+				Page   title
+			</title><meta name="description" content="Page description">`,
+			want: Preview{Title: "Page title", Description: "Page description"},
+		},
+		{
+			name: "attributes in any order, case and quotes",
+			page: `<META CONTENT='Reordered' PROPERTY='OG:TITLE'><meta content="Described" name="og:description">`,
+			want: Preview{Title: "Reordered", Description: "Described"},
+		},
+		{
+			name: "first tag wins",
+			page: `<meta property="og:title" content="First"><meta property="og:title" content="Second">`,
+			want: Preview{Title: "First"},
+		},
+		{
+			name: "entities are unescaped",
+			page: `<meta property="og:title" content="Tom &amp; Jerry &quot;live&quot;"><title>ignored</title>`,
+			want: Preview{Title: `Tom & Jerry "live"`},
+		},
+		{
+			name: "relative image",
+			page: `<meta property="og:title" content="Relative"><meta property="og:image" content="../images/cover.png">`,
+			want: Preview{Title: "Relative", Image: "https://example.com/images/cover.png"},
+		},
+		{
+			name: "image with another scheme",
+			page: `<meta property="og:title" content="Script"><meta property="og:image" content="javascript:alert(1)">`,
+			want: Preview{Title: "Script"},
+		},
+		{
+			name: "long text is truncated",
+			page: `<meta property="og:title" content="` + strings.Repeat("t", 300) + `"><meta property="og:description" content="` + strings.Repeat("d", 600) + `">`,
+			want: Preview{Title: strings.Repeat("t", maxTitleLength), Description: strings.Repeat("d", maxDescriptionLength)},
+		},
+		{
+			name: "no tags",
+			page: `<p>just text</p>`,
+			want: Preview{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parsePreview(test.page, pageURL); got != test.want {
+				t.Fatalf("parsePreview() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
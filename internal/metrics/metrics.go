@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of the latency histograms
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Sample is a value of a metric with the values of its labels in the order of the label names of the metric
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// family is a metric with all of its labelled series which can write itself in the prometheus text format
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+type registry struct {
+	families map[string]family
+	mutex    sync.RWMutex
+}
+
+// metrics are registered once at startup and exposed by Handler
+var defaultRegistry = &registry{
+	families: make(map[string]family),
+}
+
+func register(f family) {
+	defaultRegistry.mutex.Lock()
+	defer defaultRegistry.mutex.Unlock()
+
+	if _, ok := defaultRegistry.families[f.name()]; ok {
+		panic("metrics: duplicate metric " + f.name())
+	}
+	defaultRegistry.families[f.name()] = f
+}
+
+// Handler serves all the registered metrics in the prometheus text format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defaultRegistry.mutex.RLock()
+		families := make([]family, 0, len(defaultRegistry.families))
+		for _, f := range defaultRegistry.families {
+			families = append(families, f)
+		}
+		defaultRegistry.mutex.RUnlock()
+
+		sort.Slice(families, func(i, j int) bool {
+			return families[i].name() < families[j].name()
+		})
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writer := bufio.NewWriter(w)
+		for _, f := range families {
+			f.write(writer)
+		}
+		writer.Flush()
+	})
+}
+
+type description struct {
+	metricName string
+	help       string
+	metricType string
+	labelNames []string
+}
+
+func (d description) name() string {
+	return d.metricName
+}
+
+func (d description) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, d.metricType)
+}
+
+// writeSample writes one line of the series, extraLabel is added after the labels of the metric when it is not empty
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	labels := []string{}
+	for index, labelName := range labelNames {
+		labels = append(labels, labelName+`="`+escaper.Replace(labelValues[index])+`"`)
+	}
+	if extraLabel != "" {
+		labels = append(labels, extraLabel+`="`+extraValue+`"`)
+	}
+	if len(labels) > 0 {
+		w.WriteString("{" + strings.Join(labels, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// seriesKey joins the label values into the key of their series
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func (d description) checkLabelValues(labelValues []string) {
+	if len(labelValues) != len(d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.metricName, len(d.labelNames), len(labelValues)))
+	}
+}
+
+// CounterVec is a counter with a series for every combination of the values of its labels
+type CounterVec struct {
+	description
+	series map[string]*Sample
+	mutex  sync.Mutex
+}
+
+// NewCounterVec creates and registers a counter
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{
+		description: description{
+			metricName: name,
+			help:       help,
+			metricType: "counter",
+			labelNames: labelNames,
+		},
+		series: make(map[string]*Sample),
+	}
+	register(counter)
+
+	return counter
+}
+
+// Inc adds one to the series with the given label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.checkLabelValues(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := seriesKey(labelValues)
+	sample, ok := c.series[key]
+	if !ok {
+		sample = &Sample{
+			LabelValues: slices.Clone(labelValues),
+		}
+		c.series[key] = sample
+	}
+	sample.Value++
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mutex.Lock()
+	samples := make([]Sample, 0, len(c.series))
+	for _, sample := range c.series {
+		samples = append(samples, *sample)
+	}
+	c.mutex.Unlock()
+
+	c.writeHeader(w)
+	sortSamples(samples)
+	for _, sample := range samples {
+		writeSample(w, c.metricName, c.labelNames, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // observations per bucket, not cumulative
+	sum         float64
+	count       uint64
+}
+
+// HistogramVec is a histogram with a series for every combination of the values of its labels
+type HistogramVec struct {
+	description
+	buckets []float64
+	series  map[string]*histogramSeries
+	mutex   sync.Mutex
+}
+
+// NewHistogramVec creates and registers a histogram with the given bucket upper bounds in increasing order
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	histogram := &HistogramVec{
+		description: description{
+			metricName: name,
+			help:       help,
+			metricType: "histogram",
+			labelNames: labelNames,
+		},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	register(histogram)
+
+	return histogram
+}
+
+// Observe adds the value to the series with the given label values
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.checkLabelValues(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := seriesKey(labelValues)
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogramSeries{
+			labelValues: slices.Clone(labelValues),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = series
+	}
+
+	if index := sort.SearchFloat64s(h.buckets, value); index < len(h.buckets) {
+		series.counts[index]++
+	}
+	series.sum += value
+	series.count++
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mutex.Lock()
+	series := make([]histogramSeries, 0, len(h.series))
+	for _, s := range h.series {
+		copied := *s
+		copied.counts = slices.Clone(s.counts)
+		series = append(series, copied)
+	}
+	h.mutex.Unlock()
+
+	h.writeHeader(w)
+	sort.Slice(series, func(i, j int) bool {
+		return seriesKey(series[i].labelValues) < seriesKey(series[j].labelValues)
+	})
+	for _, s := range series {
+		var cumulative uint64
+		for index, upperBound := range h.buckets {
+			cumulative += s.counts[index]
+			writeSample(w, h.metricName+"_bucket", h.labelNames, s.labelValues, "le", formatFloat(upperBound), float64(cumulative))
+		}
+		writeSample(w, h.metricName+"_bucket", h.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.metricName+"_sum", h.labelNames, s.labelValues, "", "", s.sum)
+		writeSample(w, h.metricName+"_count", h.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// funcFamily is a metric whose samples are read from their source on every scrape
+type funcFamily struct {
+	description
+	collect func() []Sample
+}
+
+func (f *funcFamily) write(w *bufio.Writer) {
+	samples := f.collect()
+
+	f.writeHeader(w)
+	sortSamples(samples)
+	for _, sample := range samples {
+		f.checkLabelValues(sample.LabelValues)
+		writeSample(w, f.metricName, f.labelNames, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+// NewGaugeFunc registers a gauge whose samples are returned by collect on every scrape
+func NewGaugeFunc(name, help string, labelNames []string, collect func() []Sample) {
+	register(&funcFamily{
+		description: description{
+			metricName: name,
+			help:       help,
+			metricType: "gauge",
+			labelNames: labelNames,
+		},
+		collect: collect,
+	})
+}
+
+// NewCounterFunc registers a counter kept elsewhere whose samples are returned by collect on every scrape
+func NewCounterFunc(name, help string, labelNames []string, collect func() []Sample) {
+	register(&funcFamily{
+		description: description{
+			metricName: name,
+			help:       help,
+			metricType: "counter",
+			labelNames: labelNames,
+		},
+		collect: collect,
+	})
+}
+
+func sortSamples(samples []Sample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return seriesKey(samples[i].LabelValues) < seriesKey(samples[j].LabelValues)
+	})
+}
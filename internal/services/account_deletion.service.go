@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
+	"github.com/harshvardha/TerTerChat/internal/cache"
+	"github.com/harshvardha/TerTerChat/internal/database"
+)
+
+const (
+	AccountDeletionGracePeriod = 30 * 24 * time.Hour // duration after which a scheduled account deletion is carried out
+	accountDeletionInterval    = time.Hour           // duration after which the accounts due for deletion are checked
+)
+
+type AccountDeletion struct {
+	db                  *database.Queries
+	dbConnection        *sql.DB
+	notificationService *Notification
+	attachments         attachments.Store
+	messageCache        *cache.DynamicShardedCache
+	stop                chan struct{} // channel to recieve signal to stop the deletion worker
+}
+
+func NewAccountDeletionService(db *database.Queries, dbConnection *sql.DB, notificationService *Notification, attachments attachments.Store, messageCache *cache.DynamicShardedCache) *AccountDeletion {
+	log.Printf("[ACCOUNT_DELETION_SERVICE]: started account deletion service")
+	accountDeletion := &AccountDeletion{
+		db:                  db,
+		dbConnection:        dbConnection,
+		notificationService: notificationService,
+		attachments:         attachments,
+		messageCache:        messageCache,
+		stop:                make(chan struct{}),
+	}
+
+	go accountDeletion.monitorAndDelete()
+	return accountDeletion
+}
+
+/*
+monitorAndDelete function will check every hour for the accounts whose grace period
+has ended and delete them
+*/
+func (ad *AccountDeletion) monitorAndDelete() {
+	monitoringInterval := time.NewTicker(accountDeletionInterval)
+	defer monitoringInterval.Stop()
+
+	for {
+		select {
+		case <-monitoringInterval.C:
+			ad.deleteDueAccounts()
+		case <-ad.stop:
+			return
+		}
+	}
+}
+
+func (ad *AccountDeletion) deleteDueAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	userIDs, err := ad.db.GetUsersDueForDeletion(ctx)
+	if err != nil {
+		log.Printf("[ACCOUNT_DELETION_SERVICE]: error fetching accounts due for deletion: %v", err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		if err = ad.deleteAccount(ctx, userID); err != nil {
+			log.Printf("[ACCOUNT_DELETION_SERVICE]: error deleting account %s: %v", userID, err)
+			continue
+		}
+
+		// closing the live socket of the deleted user
+		ad.notificationService.DisconnectUser(userID)
+		log.Printf("[ACCOUNT_DELETION_SERVICE]: deleted account %s", userID)
+	}
+}
+
+/*
+deleteAccount reassigns the messages of the user to a placeholder account created for it so that
+they remain in the conversations of other users and then removes the user. Every deleted account
+gets its own placeholder so that the conversations of different deleted accounts stay apart
+*/
+func (ad *AccountDeletion) deleteAccount(ctx context.Context, userID uuid.UUID) error {
+	profile, err := ad.db.GetUserProfile(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ad.dbConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := ad.db.WithTx(tx)
+
+	// the conversations of the user are evicted from the cache once its messages are reassigned
+	conversations, err := qtx.GetUserConversations(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	deletedUserID, err := qtx.CreateDeletedUser(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = qtx.AnonymiseSentMessages(ctx, database.AnonymiseSentMessagesParams{
+		DeletedUserID: deletedUserID,
+		UserID:        userID,
+	}); err != nil {
+		return err
+	}
+
+	if err = qtx.AnonymiseReceivedMessages(ctx, database.AnonymiseReceivedMessagesParams{
+		DeletedUserID: deletedUserID,
+		UserID:        userID,
+	}); err != nil {
+		return err
+	}
+
+	// groups owned by the user get a new owner or are dissolved when no member is left
+	ownedGroups, err := qtx.GetOwnedGroupsIDs(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, groupID := range ownedGroups {
+		if _, err = qtx.LockGroup(ctx, groupID); err != nil {
+			return err
+		}
+	}
+
+	if err = qtx.RemoveUser(ctx, userID); err != nil {
+		return err
+	}
+
+	// the deletion is rolled back when a group can not be handed over or dissolved and retried on the next check
+	for _, groupID := range ownedGroups {
+		if _, _, err = KeepGroupOwned(ctx, qtx, groupID); err != nil {
+			return fmt.Errorf("handing over group %s: %w", groupID, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	// the cached messages still name the deleted user, the placeholder is new so nothing of it is cached
+	for _, c := range conversations {
+		if c.GroupID.Valid {
+			ad.messageCache.Remove(c.GroupID.UUID.String())
+			continue
+		}
+		ad.messageCache.Remove(cache.ConversationKey(userID, c.OtherUserID.UUID))
+	}
+
+	// removing avatar of the deleted user
+	if profile.AvatarKey.Valid {
+		if err = ad.attachments.Delete(ctx, profile.AvatarKey.String); err != nil {
+			log.Printf("[ACCOUNT_DELETION_SERVICE]: error removing avatar of account %s: %v", userID, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+KeepGroupOwned restores the invariant that every group has an owner after a member left it.
+The member who has the highest role and has been in the group the longest becomes the owner,
+a group without members is dissolved. It must be called inside the transaction which locked
+the group with LockGroup and removed the member
+*/
+func KeepGroupOwned(ctx context.Context, qtx *database.Queries, groupID uuid.UUID) (successorID uuid.UUID, dissolved bool, err error) {
+	successorID, err = qtx.PromoteGroupSuccessor(ctx, groupID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, false, err
+	}
+
+	removed, err := qtx.DeleteGroupIfEmpty(ctx, groupID)
+	if err != nil {
+		return uuid.Nil, false, err
+	}
+
+	return successorID, removed > 0, nil
+}
+
+func (ad *AccountDeletion) StopAccountDeletion() {
+	close(ad.stop)
+}
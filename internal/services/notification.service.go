@@ -7,47 +7,127 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/metrics"
+)
+
+// time taken to write a push to the socket of a user
+var pushLatency = metrics.NewHistogramVec(
+	"terterchat_notification_push_duration_seconds",
+	"Time taken to write a push to the socket of a user.",
+	metrics.DefaultBuckets,
+	"outcome",
 )
 
 type Notification struct {
-	connections map[string]net.Conn // this will store user_phonenumber -> tls_tcp_connection_object
+	connections map[uuid.UUID]net.Conn // this will store user_id -> tls_tcp_connection_object
 	mutex       sync.RWMutex
 }
 
 func NewNotificaitonService() *Notification {
 	log.Printf("[NOTIFICATION_SERVICE]: started notification service")
 	return &Notification{
-		connections: make(map[string]net.Conn),
+		connections: make(map[uuid.UUID]net.Conn),
+	}
+}
+
+// prefix of the pushes which the client must deliver without sound or alert, like the pushes from muted conversations
+var silentTag = []byte("SILENT|")
+
+func (conn *Notification) PushNotification(userIDs []uuid.UUID, message []byte) {
+	conn.push(userIDs, message)
+}
+
+// PushSilentNotification pushes the message tagged as silent
+func (conn *Notification) PushSilentNotification(userIDs []uuid.UUID, message []byte) {
+	if len(userIDs) == 0 {
+		return
 	}
+
+	silentMessage := make([]byte, 0, len(silentTag)+len(message))
+	silentMessage = append(silentMessage, silentTag...)
+	silentMessage = append(silentMessage, message...)
+	conn.push(userIDs, silentMessage)
 }
 
-func (conn *Notification) PushNotification(phonenumbers []string, message []byte) {
+func (conn *Notification) push(userIDs []uuid.UUID, message []byte) {
 	conn.mutex.RLock()
 	defer conn.mutex.RUnlock()
 
-	for _, phonenumber := range phonenumbers {
-		connection := conn.connections[phonenumber]
-		connection.Write(message)
+	for _, userID := range userIDs {
+		// user is not connected right now
+		connection, ok := conn.connections[userID]
+		if !ok {
+			continue
+		}
+
+		startedAt := time.Now()
+		if _, err := connection.Write(message); err != nil {
+			pushLatency.Observe(time.Since(startedAt).Seconds(), "error")
+			log.Printf("[NOTIFICATION_SERVICE]: error pushing notification to %s: %v", userID, err)
+			continue
+		}
+		pushLatency.Observe(time.Since(startedAt).Seconds(), "ok")
 	}
 }
 
-func (conn *Notification) AddUserConnection(phonenumber string, connection net.Conn) {
+// RegisterMetrics exposes the number of the live sockets, it is read on every scrape
+func (conn *Notification) RegisterMetrics() {
+	metrics.NewGaugeFunc("terterchat_notification_active_connections", "Number of users connected to the socket server.", nil, func() []metrics.Sample {
+		conn.mutex.RLock()
+		defer conn.mutex.RUnlock()
+		return []metrics.Sample{{Value: float64(len(conn.connections))}}
+	})
+}
+
+func (conn *Notification) AddUserConnection(userID uuid.UUID, connection net.Conn) {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
 
-	conn.connections[phonenumber] = connection
+	conn.connections[userID] = connection
 }
 
-func (conn *Notification) RemoveUserConnection(phonenumber string, db *database.Queries) {
+func (conn *Notification) IsUserOnline(userID uuid.UUID) bool {
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+
+	_, ok := conn.connections[userID]
+	return ok
+}
+
+func (conn *Notification) RemoveUserConnection(userID uuid.UUID, connection net.Conn, db *database.Queries) {
 	conn.mutex.Lock()
 	defer conn.mutex.Unlock()
 
+	// if the user already reconnected then the new connection must not be removed
+	if connection != nil && conn.connections[userID] != connection {
+		return
+	}
+
 	// marking user's last logout time
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
 	defer cancel()
-	if err := db.SetLastAvailable(ctx, phonenumber); err != nil {
+	if err := db.SetLastAvailable(ctx, userID); err != nil {
 		log.Printf("[EVENT]: unable to set last available time for disconnected user: %v", err)
 	}
-	delete(conn.connections, phonenumber)
+	delete(conn.connections, userID)
+}
+
+// closing the live socket of the user so that the socket server goroutines
+// emit the DISCONNECTED event and clean up after themselves, the connection
+// stays registered until RemoveUserConnection so that it still records when
+// the user was last available
+func (conn *Notification) DisconnectUser(userID uuid.UUID) {
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	connection, ok := conn.connections[userID]
+	if !ok {
+		return
+	}
+
+	if err := connection.Close(); err != nil {
+		log.Printf("[NOTIFICATION_SERVICE]: error closing connection for %s: %v", userID, err)
+	}
 }
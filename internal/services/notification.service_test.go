@@ -0,0 +1,26 @@
+package services
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDisconnectUserKeepsConnectionForRemoval(t *testing.T) {
+	notification := NewNotificaitonService()
+	userID := uuid.New()
+	server, client := net.Pipe()
+	defer client.Close()
+	notification.AddUserConnection(userID, server)
+
+	notification.DisconnectUser(userID)
+
+	// the socket server only sees the close, it removes the connection and records the last available time itself
+	if _, err := server.Write([]byte("ping")); err == nil {
+		t.Error("connection was not closed")
+	}
+	if !notification.IsUserOnline(userID) {
+		t.Error("connection was removed before RemoveUserConnection could record the last available time")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/harshvardha/TerTerChat/internal/metrics"
 	"github.com/twilio/twilio-go"
 	openapi "github.com/twilio/twilio-go/rest/verify/v2"
 )
@@ -15,6 +16,12 @@ const (
 	expiresAfter = 10 // duration for which OTP is valid after this it becomes invalid or expired
 )
 
+// outcomes of sending and verifying the otps
+var (
+	otpSends         = metrics.NewCounterVec("terterchat_otp_sends_total", "OTPs requested from twilio by outcome.", "outcome")
+	otpVerifications = metrics.NewCounterVec("terterchat_otp_verifications_total", "OTP verifications by outcome.", "outcome")
+)
+
 // this struct is used to cache the expiration time for the otp sent to user phonenumber
 type otpCache struct {
 	otpcache map[string]time.Time // key: phonenumber of user, value: time at which otp will expire
@@ -157,8 +164,10 @@ func (tc *TwilioConfig) SendOTP(phonenumber string) error {
 	response, err := tc.client.VerifyV2.CreateVerification(tc.verifyServiceSid, params)
 	if err != nil {
 		log.Printf("[OTP_SERVICE]: Error sending otp to user %v", err)
+		otpSends.Inc("error")
 		return err
 	}
+	otpSends.Inc("sent")
 
 	// setting new entry in the otpcache
 	tc.otpcache.set(phonenumber, response.DateCreated)
@@ -185,19 +194,40 @@ func (tc *TwilioConfig) VerifyOTP(phonenumber string, code string) error {
 	response, err := tc.client.VerifyV2.CreateVerificationCheck(tc.verifyServiceSid, params)
 	if err != nil {
 		log.Printf("[OTP_SERVICE]: Error requesting twilio api for sending otp to user phonenumber %s, %v", phonenumber, err)
+		otpVerifications.Inc("error")
 		return err
 	}
 
-	switch *response.Status {
+	status := ""
+	if response.Status != nil {
+		status = *response.Status
+	}
+
+	return tc.checkVerificationStatus(phonenumber, status)
+}
+
+/*
+checkVerificationStatus returns nil only when twilio approved the code and an error for every other status,
+twilio answers with pending while the code is wrong and the verification can still be retried
+*/
+func (tc *TwilioConfig) checkVerificationStatus(phonenumber string, status string) error {
+	switch status {
 	case "approved":
+		otpVerifications.Inc("approved")
+
 		// removing otp entry from cache
-		if err = tc.otpcache.remove(phonenumber); err != nil {
+		if err := tc.otpcache.remove(phonenumber); err != nil {
 			log.Printf("[OTP_SERVICE]: Error removing otp entry from otpcache after successfull approval %v", err)
 			return err
 		}
 
 		return nil
+	case "pending":
+		otpVerifications.Inc("incorrect")
+		return errors.New("incorrect otp")
 	case "failed":
+		otpVerifications.Inc("incorrect")
+
 		// checking if otp is expired then removing it from cache
 		expirationTime, err := tc.otpcache.get(phonenumber)
 		if err != nil && expirationTime.IsZero() {
@@ -213,14 +243,27 @@ func (tc *TwilioConfig) VerifyOTP(phonenumber string, code string) error {
 		}
 		return errors.New("incorrect otp")
 	case "expired":
-		if err = tc.otpcache.remove(phonenumber); err != nil {
+		otpVerifications.Inc("expired")
+		if err := tc.otpcache.remove(phonenumber); err != nil {
 			log.Printf("[OTP_CACHE]: Error removing otp entry from otpcache after it is expired %v", err)
 			return err
 		}
 		return errors.New("otp expired")
 	}
 
-	return nil
+	// a status twilio may add later is never taken as an approval
+	otpVerifications.Inc("other")
+	log.Printf("[OTP_SERVICE]: unexpected verification status %q for phonenumber %s", status, phonenumber)
+	return fmt.Errorf("otp not verified: %s", status)
+}
+
+// RegisterMetrics exposes the number of the otps waiting to be verified, it is read on every scrape
+func (tc *TwilioConfig) RegisterMetrics() {
+	metrics.NewGaugeFunc("terterchat_otp_cache_entries", "Number of sent otps which were not verified or cleaned up yet.", nil, func() []metrics.Sample {
+		tc.otpcache.mutex.RLock()
+		defer tc.otpcache.mutex.RUnlock()
+		return []metrics.Sample{{Value: float64(len(tc.otpcache.otpcache))}}
+	})
 }
 
 // method to stop cache monitoring
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTwilioConfig(phonenumber string) *TwilioConfig {
+	tc := &TwilioConfig{
+		otpcache: &otpCache{
+			otpcache: make(map[string]time.Time),
+			stop:     make(chan struct{}),
+		},
+	}
+
+	createdAt := time.Now()
+	tc.otpcache.set(phonenumber, &createdAt)
+	return tc
+}
+
+func TestCheckVerificationStatus(t *testing.T) {
+	const phonenumber = "+911234567890"
+
+	tests := []struct {
+		status   string
+		verified bool
+	}{
+		{status: "approved", verified: true},
+		// twilio keeps a verification pending when a wrong code is checked
+		{status: "pending"},
+		{status: "failed"},
+		{status: "expired"},
+		{status: "canceled"},
+		{status: "max_attempts_reached"},
+		{status: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.status, func(t *testing.T) {
+			tc := newTestTwilioConfig(phonenumber)
+
+			err := tc.checkVerificationStatus(phonenumber, test.status)
+			if test.verified && err != nil {
+				t.Fatalf("checkVerificationStatus(%q) = %v, want verified", test.status, err)
+			}
+			if !test.verified && err == nil {
+				t.Fatalf("checkVerificationStatus(%q) verified the otp", test.status)
+			}
+		})
+	}
+}
@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/harshvardha/TerTerChat/controllers"
 	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
+	"github.com/harshvardha/TerTerChat/internal/attachments"
 	"github.com/harshvardha/TerTerChat/internal/cache"
 	"github.com/harshvardha/TerTerChat/internal/database"
+	"github.com/harshvardha/TerTerChat/internal/linkpreview"
+	"github.com/harshvardha/TerTerChat/internal/metrics"
 	"github.com/harshvardha/TerTerChat/internal/services"
 	"github.com/harshvardha/TerTerChat/servers"
 	"github.com/harshvardha/TerTerChat/utility"
@@ -35,6 +41,9 @@ func main() {
 		log.Fatal("[ENV_VARIABLES]: REST api port not set")
 	}
 
+	// loading admin port env variable, the metrics are not served when it is not set
+	adminPort := os.Getenv("ADMIN_PORT")
+
 	// loading jwt secret env variable
 	jwtSecret := os.Getenv("ACCESS_TOKEN_SECRET")
 	if jwtSecret == "" {
@@ -71,6 +80,18 @@ func main() {
 		log.Fatal("[ENV_VARIABLES]: CHANNEL not set")
 	}
 
+	// loading attachments directory variable
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+	if attachmentsDir == "" {
+		log.Fatal("[ENV_VARIABLES]: ATTACHMENTS_DIR not set")
+	}
+
+	// loading contact discovery pepper, the secret key of the phonenumber hashes matched by contact discovery
+	contactDiscoveryPepper := os.Getenv("CONTACT_DISCOVERY_PEPPER")
+	if contactDiscoveryPepper == "" {
+		log.Fatal("[ENV_VARIABLES]: CONTACT_DISCOVERY_PEPPER not set")
+	}
+
 	// setting twilio config
 	twilioConfig := services.NewOTPService(
 		twilioAccountSID,
@@ -79,6 +100,68 @@ func main() {
 		otpChannel,
 	)
 
+	// setting up attachments store
+	attachmentsStore, err := attachments.NewLocalStore(attachmentsDir)
+	if err != nil {
+		log.Fatal("Error setting up attachments store: ", err)
+	}
+
+	// loading message edit window variable, messages can be edited for 15 minutes by default
+	messageEditWindow := 15 * time.Minute
+	if editWindow := os.Getenv("MESSAGE_EDIT_WINDOW"); editWindow != "" {
+		messageEditWindow, err = time.ParseDuration(editWindow)
+		if err != nil || messageEditWindow <= 0 {
+			log.Fatal("[ENV_VARIABLES]: MESSAGE_EDIT_WINDOW must be a positive duration like 15m")
+		}
+	}
+
+	// loading delete for everyone window variable, messages can be deleted for everyone for 48 hours by default
+	deleteForEveryoneWindow := 48 * time.Hour
+	if deleteWindow := os.Getenv("DELETE_FOR_EVERYONE_WINDOW"); deleteWindow != "" {
+		deleteForEveryoneWindow, err = time.ParseDuration(deleteWindow)
+		if err != nil || deleteForEveryoneWindow <= 0 {
+			log.Fatal("[ENV_VARIABLES]: DELETE_FOR_EVERYONE_WINDOW must be a positive duration like 48h")
+		}
+	}
+
+	// loading message cache variables, by default the latest 50 messages of at most 10000 conversations
+	// using at most 64MB are cached and a conversation is dropped 30 minutes after its last message
+	messageCacheConfig := cache.Config{
+		Window:     50,
+		MaxEntries: 10000,
+		MaxBytes:   64 << 20,
+		TTL:        30 * time.Minute,
+	}
+	if window := os.Getenv("MESSAGE_CACHE_WINDOW"); window != "" {
+		messageCacheConfig.Window, err = strconv.Atoi(window)
+		if err != nil || messageCacheConfig.Window <= 0 {
+			log.Fatal("[ENV_VARIABLES]: MESSAGE_CACHE_WINDOW must be a positive number of messages")
+		}
+	}
+	if maxConversations := os.Getenv("MESSAGE_CACHE_MAX_CONVERSATIONS"); maxConversations != "" {
+		messageCacheConfig.MaxEntries, err = strconv.Atoi(maxConversations)
+		if err != nil || messageCacheConfig.MaxEntries <= 0 {
+			log.Fatal("[ENV_VARIABLES]: MESSAGE_CACHE_MAX_CONVERSATIONS must be a positive number of conversations")
+		}
+	}
+	if maxMB := os.Getenv("MESSAGE_CACHE_MAX_MB"); maxMB != "" {
+		megabytes, err := strconv.Atoi(maxMB)
+		if err != nil || megabytes <= 0 {
+			log.Fatal("[ENV_VARIABLES]: MESSAGE_CACHE_MAX_MB must be a positive number of megabytes")
+		}
+		messageCacheConfig.MaxBytes = int64(megabytes) << 20
+	}
+	if ttl := os.Getenv("MESSAGE_CACHE_TTL"); ttl != "" {
+		messageCacheConfig.TTL, err = time.ParseDuration(ttl)
+		if err != nil || messageCacheConfig.TTL <= 0 {
+			log.Fatal("[ENV_VARIABLES]: MESSAGE_CACHE_TTL must be a positive duration like 30m")
+		}
+	}
+
+	// setting up link preview fetcher, pages are fetched for at most 5 seconds, only their first 512KB
+	// are read and the previews are remembered for an hour
+	linkPreviews := linkpreview.NewCachingFetcher(linkpreview.NewHTTPFetcher(5*time.Second, 512*1024), time.Hour, 1000)
+
 	// creating database connection
 	dbConnection, err := sql.Open("postgres", databaseURI)
 	if err != nil {
@@ -92,31 +175,72 @@ func main() {
 	dataValidator.RegisterValidation("username", utility.UsernameAndGroupnameValidator)
 	dataValidator.RegisterValidation("groupname", utility.UsernameAndGroupnameValidator)
 	dataValidator.RegisterValidation("phonenumber", utility.PhonenumberValidator)
+	dataValidator.RegisterValidation("channelhandle", utility.ChannelHandleValidator)
+
+	// events are buffered so that a burst of events does not block the handlers emitting them,
+	// the number of events waiting in every channel is exposed as a metric
+	const eventChannelSize = 256
 
 	// communication channel for message event handler and rest api server
-	messageEventEmitterChannel := make(chan eventhandlers.MessageEvent)
+	messageEventEmitterChannel := make(chan eventhandlers.MessageEvent, eventChannelSize)
 
 	// communication channel for group actions event handler and rest api server
-	groupActionsEventEmitterChannel := make(chan eventhandlers.GroupEvent)
+	groupActionsEventEmitterChannel := make(chan eventhandlers.GroupEvent, eventChannelSize)
+
+	// communication channel for user event handler and rest api server
+	userEventEmitterChannel := make(chan eventhandlers.UserEvent, eventChannelSize)
 
 	// communication channel for connection event handler and tcp server
-	connectionEventEmitterChannel := make(chan eventhandlers.ConnectionEvent)
+	connectionEventEmitterChannel := make(chan eventhandlers.ConnectionEvent, eventChannelSize)
 
 	// notification service for pushing real time updates to users based on events
 	notificationService := services.NewNotificaitonService()
 
+	// message cache for serving the latest messages of the conversations
+	messageCache := cache.NewDynamicShardedCache(4, 16, messageCacheConfig)
+
+	// account deletion service for deleting the accounts whose grace period has ended
+	accountDeletionService := services.NewAccountDeletionService(db, dbConnection, notificationService, attachmentsStore, messageCache)
+
 	// setting up the apiConfig struct for REST server
 	apiConfig := controllers.ApiConfig{
 		DB:                              db,
+		DBConnection:                    dbConnection,
 		JwtSecret:                       jwtSecret,
 		TwilioConfig:                    twilioConfig,
 		NotificationService:             notificationService,
 		DataValidator:                   dataValidator,
 		MessageEventEmitterChannel:      messageEventEmitterChannel,
 		GroupActionsEventEmitterChannel: groupActionsEventEmitterChannel,
-		MessageCache:                    cache.NewDynamicShardedCache(4, 16),
+		UserEventEmitterChannel:         userEventEmitterChannel,
+		MessageCache:                    messageCache,
+		Attachments:                     attachmentsStore,
+		MessageEditWindow:               messageEditWindow,
+		DeleteForEveryoneWindow:         deleteForEveryoneWindow,
+		LinkPreviews:                    linkPreviews,
+		LinkPreviewQueue:                make(chan database.Message, 100),
+		ContactDiscoveryPepper:          []byte(contactDiscoveryPepper),
+	}
+
+	// hashing the phonenumbers of the users registered before the hashes were stored
+	if err = apiConfig.BackfillPhonenumberHashes(context.Background()); err != nil {
+		log.Fatal("Error hashing phonenumbers for contact discovery: ", err)
 	}
 
+	// registering the metrics served by the admin server
+	apiConfig.MessageCache.RegisterMetrics("message")
+	notificationService.RegisterMetrics()
+	twilioConfig.RegisterMetrics()
+	metrics.NewGaugeFunc("terterchat_event_channel_depth", "Number of events waiting to be handled in every event channel.", []string{"channel"}, func() []metrics.Sample {
+		return []metrics.Sample{
+			{LabelValues: []string{"message"}, Value: float64(len(messageEventEmitterChannel))},
+			{LabelValues: []string{"group_actions"}, Value: float64(len(groupActionsEventEmitterChannel))},
+			{LabelValues: []string{"user"}, Value: float64(len(userEventEmitterChannel))},
+			{LabelValues: []string{"connection"}, Value: float64(len(connectionEventEmitterChannel))},
+			{LabelValues: []string{"link_preview"}, Value: float64(len(apiConfig.LinkPreviewQueue))},
+		}
+	})
+
 	var wg sync.WaitGroup
 
 	// launching message event handler
@@ -127,27 +251,65 @@ func main() {
 	wg.Add(1)
 	go eventhandlers.GroupActionsEventHandler(groupActionsEventEmitterChannel, &wg)
 
+	// launching user event handler
+	wg.Add(1)
+	go eventhandlers.UserEventHandler(userEventEmitterChannel, &wg)
+
 	// launching connections event handler
 	wg.Add(1)
 	go eventhandlers.ConnectionEventHandler(connectionEventEmitterChannel, &wg)
 
+	// the workers emit events so they are waited for separately before the event channels are closed
+	var workersWg sync.WaitGroup
+
+	// launching message scheduler
+	messageSchedulerStop := make(chan struct{})
+	workersWg.Add(1)
+	go apiConfig.MessageScheduler(messageSchedulerStop, &workersWg)
+
+	// launching message reaper
+	messageReaperStop := make(chan struct{})
+	workersWg.Add(1)
+	go apiConfig.MessageReaper(messageReaperStop, &workersWg)
+
+	// launching link preview worker
+	linkPreviewWorkerStop := make(chan struct{})
+	workersWg.Add(1)
+	go apiConfig.LinkPreviewWorker(linkPreviewWorkerStop, &workersWg)
+
 	// creating a quit channel to listen for os signal for shutting down servers
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
 	// starting tcp server
 	wg.Add(1)
-	go servers.StartTCPServer(tcpPort, notificationService, db, connectionEventEmitterChannel, quit, &wg)
+	go servers.StartTCPServer(tcpPort, jwtSecret, notificationService, db, connectionEventEmitterChannel, quit, &wg)
 
 	// starting rest api server
 	wg.Add(1)
 	go servers.StartRESTApiServer(restApiPort, &apiConfig, quit, &wg)
 
+	// starting admin server
+	adminServerStop := make(chan struct{})
+	if adminPort != "" {
+		wg.Add(1)
+		go servers.StartAdminServer(adminPort, adminServerStop, &wg)
+	} else {
+		log.Println("[ENV_VARIABLES]: ADMIN_PORT not set, metrics will not be served")
+	}
+
 	// waiting for servers to shutdown
 	<-quit
 	log.Println("Shutting down servers...")
+	accountDeletionService.StopAccountDeletion()
+	close(messageSchedulerStop)
+	close(messageReaperStop)
+	close(linkPreviewWorkerStop)
+	close(adminServerStop)
+	workersWg.Wait()
 	close(messageEventEmitterChannel)
 	close(groupActionsEventEmitterChannel)
+	close(userEventEmitterChannel)
 	close(connectionEventEmitterChannel)
 	close(quit)
 	wg.Wait()
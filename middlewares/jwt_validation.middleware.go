@@ -93,3 +93,23 @@ func ValidateJWT(handler authenticatedEndpointHandler, tokenSecret string, db *d
 		handler(w, r, userID, "")
 	}
 }
+
+/*
+UserIDFromAccessToken returns the id of the user the access token was issued to. Unlike ValidateJWT an
+expired token is not refreshed, the client has to refresh it through the rest api before using it again
+*/
+func UserIDFromAccessToken(accessToken string, tokenSecret string) (uuid.UUID, error) {
+	jwtClaims := jwt.RegisteredClaims{}
+	if _, err := jwt.ParseWithClaims(accessToken, &jwtClaims, func(token *jwt.Token) (any, error) {
+		return []byte(tokenSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS512.Alg()}), jwt.WithExpirationRequired()); err != nil {
+		return uuid.Nil, err
+	}
+
+	parsedSubjects, err := getSubjects(jwtClaims.Subject)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return uuid.Parse(parsedSubjects["user_id"])
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/harshvardha/TerTerChat/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"terterchat_http_requests_total",
+		"Requests served by the rest api by route and status code.",
+		"route",
+		"status",
+	)
+	httpRequestLatency = metrics.NewHistogramVec(
+		"terterchat_http_request_duration_seconds",
+		"Time taken to serve the requests of the rest api by route.",
+		metrics.DefaultBuckets,
+		"route",
+	)
+)
+
+// statusRecorder remembers the status code written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if recorder.status == 0 {
+		recorder.status = status
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *statusRecorder) Write(body []byte) (int, error) {
+	if recorder.status == 0 {
+		recorder.status = http.StatusOK
+	}
+	return recorder.ResponseWriter.Write(body)
+}
+
+func (recorder *statusRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
+
+/*
+RecordMetrics counts the requests and measures their latency per route of the router. The route is the
+pattern matched by the router, like "GET /api/v1/healthz", so that ids in the paths do not create new
+series, requests which matched no route are counted under "unmatched"
+*/
+func RecordMetrics(router *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		startedAt := time.Now()
+		recorder := &statusRecorder{
+			ResponseWriter: w,
+		}
+
+		// the router sets the matched pattern on the request
+		router.ServeHTTP(recorder, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+
+		httpRequests.Inc(route, strconv.Itoa(recorder.status))
+		httpRequestLatency.Observe(time.Since(startedAt).Seconds(), route)
+	})
+}
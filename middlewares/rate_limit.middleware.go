@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/harshvardha/TerTerChat/utility"
+)
+
+// rateLimitWindow is the number of requests a user made in the window starting at startedAt
+type rateLimitWindow struct {
+	startedAt time.Time
+	requests  int
+}
+
+/*
+RateLimiter allows every user at most limit requests in every window of the given duration. The windows
+are kept in the memory of the server, so when several replicas serve the api every replica counts the
+requests it received on its own and a user can make up to limit requests on each of them
+*/
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	windows map[uuid.UUID]*rateLimitWindow
+	mutex   sync.Mutex
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[uuid.UUID]*rateLimitWindow),
+	}
+}
+
+// allow records a request of the user and returns how long the user has to wait when the limit is reached
+func (limiter *RateLimiter) allow(userID uuid.UUID, now time.Time) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	window, ok := limiter.windows[userID]
+	if !ok || now.Sub(window.startedAt) >= limiter.window {
+		// forgetting the windows which have ended so that the map does not grow with every user ever seen
+		if !ok {
+			for id, other := range limiter.windows {
+				if now.Sub(other.startedAt) >= limiter.window {
+					delete(limiter.windows, id)
+				}
+			}
+		}
+
+		limiter.windows[userID] = &rateLimitWindow{
+			startedAt: now,
+			requests:  1,
+		}
+		return true, 0
+	}
+
+	if window.requests >= limiter.limit {
+		return false, window.startedAt.Add(limiter.window).Sub(now)
+	}
+	window.requests++
+
+	return true, 0
+}
+
+// RateLimit responds with 429 Too Many Requests once the user has used up the requests of the current window
+func RateLimit(handler authenticatedEndpointHandler, limiter *RateLimiter) authenticatedEndpointHandler {
+	return func(w http.ResponseWriter, r *http.Request, userID uuid.UUID, newAccessToken string) {
+		if allowed, retryAfter := limiter.allow(userID, time.Now()); !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			utility.RespondWithError(w, http.StatusTooManyRequests, "too many requests, try again later")
+			return
+		}
+
+		handler(w, r, userID, newAccessToken)
+	}
+}
@@ -0,0 +1,48 @@
+package servers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/harshvardha/TerTerChat/internal/metrics"
+)
+
+/*
+StartAdminServer serves the prometheus metrics on /metrics. It listens on its own port so that the
+metrics are not reachable through the public rest api and the port can be kept inside the network
+*/
+func StartAdminServer(port string, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	log.Printf("[ADMIN SERVER]: Starting server")
+
+	router := http.NewServeMux()
+	router.Handle("GET /metrics", metrics.Handler())
+
+	server := &http.Server{
+		Addr:        ":" + port,
+		Handler:     router,
+		ReadTimeout: time.Second * 5,
+	}
+
+	// launching ListenAndServe in a separate go-routine
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("[ADMIN SERVER]: server failed %v", err)
+		}
+	}()
+
+	// waiting for the servers to shut down
+	<-stop
+
+	context, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(context); err != nil {
+		log.Printf("[ADMIN SERVER]: server shutdown failed %v", err)
+	}
+
+	log.Printf("[ADMIN SERVER]: server shutdown successfull")
+}
@@ -28,40 +28,112 @@ func StartRESTApiServer(port string, apiConfig *controllers.ApiConfig, quit <-ch
 	router.HandleFunc("POST /api/v1/auth/otp/send/registeredPhonenumber", middlewares.ValidateJWT(apiConfig.HandleSendOTPTORegisteredPhonenumber, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("POST /api/v1/auth/register", apiConfig.HandleRegisterUser)
 	router.HandleFunc("POST /api/v1/auth/login", apiConfig.HandleLoginUser)
+	router.HandleFunc("POST /api/v1/auth/password/forgot/otp/send", apiConfig.HandleSendPasswordResetOTP)
+	router.HandleFunc("POST /api/v1/auth/password/forgot/otp/verify", apiConfig.HandleVerifyPasswordResetOTP)
+	router.HandleFunc("PUT /api/v1/auth/password/reset", apiConfig.HandleResetPassword)
+
+	// the endpoints finding users by their phonenumbers share a limit of 10 requests every 15 minutes per user
+	// so that the registered phonenumbers can not be enumerated through either of them
+	phonenumberLookupLimiter := middlewares.NewRateLimiter(10, 15*time.Minute)
 
 	// api endpoints for users
 	router.HandleFunc("PUT /api/v1/users/update/username", middlewares.ValidateJWT(apiConfig.UpdateUsername, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/users/update/phonenumber/otp/send", middlewares.ValidateJWT(apiConfig.HandleSendOTPToNewPhonenumber, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/users/update/phonenumber", middlewares.ValidateJWT(apiConfig.UpdatePhonenumber, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/users/update/password", middlewares.ValidateJWT(apiConfig.UpdatePassword, apiConfig.JwtSecret, apiConfig.DB))
-	router.HandleFunc("GET /api/v1/users/info", middlewares.ValidateJWT(apiConfig.GetUserByPhonenumber, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/update/profile", middlewares.ValidateJWT(apiConfig.UpdateProfile, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/update/privacy", middlewares.ValidateJWT(apiConfig.UpdatePrivacySettings, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/update/avatar", middlewares.ValidateJWT(apiConfig.UpdateAvatar, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/users/info", middlewares.ValidateJWT(middlewares.RateLimit(apiConfig.GetUserByPhonenumber, phonenumberLookupLimiter), apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/users/avatar", middlewares.ValidateJWT(apiConfig.GetAvatar, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/users/remove/avatar", middlewares.ValidateJWT(apiConfig.RemoveAvatar, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/block", middlewares.ValidateJWT(apiConfig.HandleBlockUser, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/unblock", middlewares.ValidateJWT(apiConfig.HandleUnblockUser, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/users/blocked", middlewares.ValidateJWT(apiConfig.HandleGetBlockedUsers, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("DELETE /api/v1/users/remove", middlewares.ValidateJWT(apiConfig.RemoveUser, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/users/remove/cancel", middlewares.ValidateJWT(apiConfig.CancelRemoveUser, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/users/export", middlewares.ValidateJWT(apiConfig.ExportUserData, apiConfig.JwtSecret, apiConfig.DB))
+
+	// api endpoints for contacts
+	router.HandleFunc("POST /api/v1/contacts/add", middlewares.ValidateJWT(apiConfig.HandleAddContact, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/contacts/remove", middlewares.ValidateJWT(apiConfig.HandleRemoveContact, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/contacts", middlewares.ValidateJWT(apiConfig.HandleGetContacts, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/contacts/discover", middlewares.ValidateJWT(middlewares.RateLimit(apiConfig.HandleDiscoverContacts, phonenumberLookupLimiter), apiConfig.JwtSecret, apiConfig.DB))
 
 	// api endpoints for messages
 	router.HandleFunc("POST /api/v1/message/create", middlewares.ValidateJWT(apiConfig.HandleCreateNewMessage, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/message/update", middlewares.ValidateJWT(apiConfig.HandleUpdateMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/edits", middlewares.ValidateJWT(apiConfig.HandleGetMessageEdits, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/mentions", middlewares.ValidateJWT(apiConfig.HandleGetMentions, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/message/poll/create", middlewares.ValidateJWT(apiConfig.HandleCreatePoll, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/poll/vote", middlewares.ValidateJWT(apiConfig.HandleVotePoll, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/message/poll/vote", middlewares.ValidateJWT(apiConfig.HandleRetractPollVote, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/poll/close", middlewares.ValidateJWT(apiConfig.HandleClosePoll, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/message/forward", middlewares.ValidateJWT(apiConfig.HandleForwardMessages, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("DELETE /api/v1/message/delete", middlewares.ValidateJWT(apiConfig.HandleDeleteMessage, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("GET /api/v1/message/conversation", middlewares.ValidateJWT(apiConfig.HandleGetConversation, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("DELETE /api/v1/message/conversation/delete", middlewares.ValidateJWT(apiConfig.HandleDeleteConversation, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("GET /api/v1/message/conversations", middlewares.ValidateJWT(apiConfig.HandleGetAllConversations, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/conversation/mute", middlewares.ValidateJWT(apiConfig.HandleMuteConversation, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/conversation/archive", middlewares.ValidateJWT(apiConfig.HandleArchiveConversation, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/conversation/pin", middlewares.ValidateJWT(apiConfig.HandlePinConversation, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("GET /api/v1/message/group/all", middlewares.ValidateJWT(apiConfig.HandleGetAllGroupMessages, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/message/mark/received", middlewares.ValidateJWT(apiConfig.HandleMarkMessageReceived, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/message/mark/read", middlewares.ValidateJWT(apiConfig.HandleMarkMessageRead, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/message/group/mark/received", middlewares.ValidateJWT(apiConfig.HandleMarkGroupMessageReceived, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/message/group/mark/read", middlewares.ValidateJWT(apiConfig.HandleMarkGroupMessageRead, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/requests", middlewares.ValidateJWT(apiConfig.HandleGetMessageRequests, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/requests/accept", middlewares.ValidateJWT(apiConfig.HandleAcceptMessageRequest, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/requests/decline", middlewares.ValidateJWT(apiConfig.HandleDeclineMessageRequest, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/pin", middlewares.ValidateJWT(apiConfig.HandlePinMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/unpin", middlewares.ValidateJWT(apiConfig.HandleUnpinMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/pinned", middlewares.ValidateJWT(apiConfig.HandleGetPinnedMessages, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/message/schedule", middlewares.ValidateJWT(apiConfig.HandleScheduleMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/scheduled", middlewares.ValidateJWT(apiConfig.HandleGetScheduledMessages, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/schedule/update", middlewares.ValidateJWT(apiConfig.HandleUpdateScheduledMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/message/schedule/cancel", middlewares.ValidateJWT(apiConfig.HandleCancelScheduledMessage, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/message/timer", middlewares.ValidateJWT(apiConfig.HandleSetMessageTimer, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/message/timer", middlewares.ValidateJWT(apiConfig.HandleGetMessageTimer, apiConfig.JwtSecret, apiConfig.DB))
+
+	// api endpoints for attachments
+	router.HandleFunc("POST /api/v1/attachments/upload", middlewares.ValidateJWT(apiConfig.HandleUploadAttachment, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/attachments", middlewares.ValidateJWT(apiConfig.HandleGetAttachment, apiConfig.JwtSecret, apiConfig.DB))
 
 	// api endpoints for group
 	router.HandleFunc("POST /api/v1/group/create", middlewares.ValidateJWT(apiConfig.HandleCreateGroup, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/group/update", middlewares.ValidateJWT(apiConfig.HandleUpdateGroupName, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/group/info", middlewares.ValidateJWT(apiConfig.HandleGetGroupInfo, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/update/settings", middlewares.ValidateJWT(apiConfig.HandleUpdateGroupSettings, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/update/avatar", middlewares.ValidateJWT(apiConfig.HandleUpdateGroupAvatar, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/group/remove/avatar", middlewares.ValidateJWT(apiConfig.HandleRemoveGroupAvatar, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/group/avatar", middlewares.ValidateJWT(apiConfig.HandleGetGroupAvatar, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("DELETE /api/v1/group/remove", middlewares.ValidateJWT(apiConfig.HandleRemoveGroup, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("GET /api/v1/group/members", middlewares.ValidateJWT(apiConfig.HandleGetAllMembersOfGroup, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/group/add/user", middlewares.ValidateJWT(apiConfig.HandleAddUserToGroup, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/group/member/remove", middlewares.ValidateJWT(apiConfig.HandleRemoveUserFromGroup, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/leave", middlewares.ValidateJWT(apiConfig.HandleLeaveGroup, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/owner/transfer", middlewares.ValidateJWT(apiConfig.HandleTransferGroupOwnership, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/group/make/user/admin", middlewares.ValidateJWT(apiConfig.HandleMakeUserAdmin, apiConfig.JwtSecret, apiConfig.DB))
 	router.HandleFunc("PUT /api/v1/group/remove/user/admin", middlewares.ValidateJWT(apiConfig.HandleRemoveUserFromAdmin, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/member/role", middlewares.ValidateJWT(apiConfig.HandleChangeGroupMemberRole, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/group/list/admins", middlewares.ValidateJWT(apiConfig.HandleGetAllAdminsForGroup, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("POST /api/v1/group/invite/create", middlewares.ValidateJWT(apiConfig.HandleCreateGroupInvite, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/group/invites", middlewares.ValidateJWT(apiConfig.HandleGetGroupInvites, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("DELETE /api/v1/group/invite/revoke", middlewares.ValidateJWT(apiConfig.HandleRevokeGroupInvite, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/join", middlewares.ValidateJWT(apiConfig.HandleJoinGroup, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/group/join/requests", middlewares.ValidateJWT(apiConfig.HandleGetGroupJoinRequests, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/join/requests/approve", middlewares.ValidateJWT(apiConfig.HandleApproveGroupJoinRequest, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/group/join/requests/decline", middlewares.ValidateJWT(apiConfig.HandleDeclineGroupJoinRequest, apiConfig.JwtSecret, apiConfig.DB))
+
+	// api endpoints for channel
+	router.HandleFunc("POST /api/v1/channel/create", middlewares.ValidateJWT(apiConfig.HandleCreateChannel, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("GET /api/v1/channel/info", middlewares.ValidateJWT(apiConfig.HandleGetChannelInfo, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/channel/subscribe", middlewares.ValidateJWT(apiConfig.HandleSubscribeChannel, apiConfig.JwtSecret, apiConfig.DB))
+	router.HandleFunc("PUT /api/v1/channel/unsubscribe", middlewares.ValidateJWT(apiConfig.HandleUnsubscribeChannel, apiConfig.JwtSecret, apiConfig.DB))
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: router,
+		Handler: middlewares.RecordMetrics(router),
 		// ReadTimeout:  time.Second * 5,
 		// WriteTimeout: time.Second * 10,
 		// IdleTimeout:  time.Second * 120,
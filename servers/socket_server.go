@@ -3,6 +3,7 @@ package servers
 import (
 	"bufio"
 	"crypto/tls"
+	"errors"
 	"io"
 	"log"
 	"net"
@@ -11,9 +12,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	eventhandlers "github.com/harshvardha/TerTerChat/event_handlers"
 	"github.com/harshvardha/TerTerChat/internal/database"
 	"github.com/harshvardha/TerTerChat/internal/services"
+	"github.com/harshvardha/TerTerChat/middlewares"
 )
 
 const (
@@ -22,13 +25,37 @@ const (
 	pingInterval = 5 * time.Second
 	pingTimeout  = 5 * time.Second
 
+	// maximum length of the access token sent by the client on connecting, terminated by a newline
+	maxAccessTokenLength = 2048
+
 	// server certificate and key file paths
 	certificateFile = "certificates/server.crt"
 	keyFile         = "certificates/server.key"
 )
 
+/*
+readAccessToken reads the access token the client sends as its first line. It is read one byte at a time
+so that nothing after the newline is consumed before the connection reader takes over
+*/
+func readAccessToken(connection net.Conn) (string, error) {
+	token := make([]byte, 0, 512)
+	next := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(connection, next); err != nil {
+			return "", err
+		}
+		if next[0] == '\n' {
+			return strings.TrimSpace(string(token)), nil
+		}
+		if len(token) == maxAccessTokenLength {
+			return "", errors.New("access token is too long")
+		}
+		token = append(token, next[0])
+	}
+}
+
 // heartbeat mechanism to check whether the client connection is still alive or not
-func handleTLSConnections(connection net.Conn, phonenumber string, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, wg *sync.WaitGroup, quit <-chan os.Signal) {
+func handleTLSConnections(connection net.Conn, userID uuid.UUID, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, wg *sync.WaitGroup, quit <-chan os.Signal) {
 	defer wg.Done()
 	defer connection.Close()
 
@@ -71,10 +98,10 @@ func handleTLSConnections(connection net.Conn, phonenumber string, db *database.
 	stopChan := make(chan struct{}) // this will be used to know whether to stop the heartbeat mechanism for the connection or not
 
 	// reader go-routine
-	go readFromConnection(connection, phonenumber, db, notificationService, connectionEventChannel, stopChan, quit)
+	go readFromConnection(connection, userID, db, notificationService, connectionEventChannel, stopChan, quit)
 
 	// writer go-routine
-	go writeToConnection(connection, phonenumber, db, notificationService, connectionEventChannel, stopChan, quit)
+	go writeToConnection(connection, userID, db, notificationService, connectionEventChannel, stopChan, quit)
 
 	// blocking until signal recieved to stop heartbeat mechanism
 	<-stopChan
@@ -82,7 +109,7 @@ func handleTLSConnections(connection net.Conn, phonenumber string, db *database.
 }
 
 // reader go-routine to read pong messages if server sends ping or respond with pong messages if client sends ping
-func readFromConnection(connection net.Conn, phonenumber string, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, stopChan chan struct{}, quit <-chan os.Signal) {
+func readFromConnection(connection net.Conn, userID uuid.UUID, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, stopChan chan struct{}, quit <-chan os.Signal) {
 	defer func() {
 		log.Printf("[CONNECTION READER FOR %s]: Exiting", connection.RemoteAddr())
 		stopChan <- struct{}{}
@@ -119,8 +146,8 @@ func readFromConnection(connection net.Conn, phonenumber string, db *database.Qu
 				// emitting disconnect event to connection event handler
 				connectionEventChannel <- eventhandlers.ConnectionEvent{
 					Name:                "DISCONNECTED",
-					Phonenumber:         phonenumber,
-					ConnectionInstance:  nil,
+					UserID:              userID,
+					ConnectionInstance:  connection,
 					NotificationService: notificationService,
 					DB:                  db,
 					EmittedAt:           time.Now(),
@@ -139,7 +166,7 @@ func readFromConnection(connection net.Conn, phonenumber string, db *database.Qu
 	}
 }
 
-func writeToConnection(connection net.Conn, phonenumber string, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, stopChan chan struct{}, quit <-chan os.Signal) {
+func writeToConnection(connection net.Conn, userID uuid.UUID, db *database.Queries, notificationService *services.Notification, connectionEventChannel chan eventhandlers.ConnectionEvent, stopChan chan struct{}, quit <-chan os.Signal) {
 	ticker := time.NewTicker(pingInterval)
 	defer func() {
 		log.Printf("[CONNECTION WRITER FOR %s]: Exiting.", connection.RemoteAddr())
@@ -158,8 +185,8 @@ func writeToConnection(connection net.Conn, phonenumber string, db *database.Que
 				// emitting connection disconnected event
 				connectionEventChannel <- eventhandlers.ConnectionEvent{
 					Name:                "DISCONNECTED",
-					Phonenumber:         phonenumber,
-					ConnectionInstance:  nil,
+					UserID:              userID,
+					ConnectionInstance:  connection,
 					DB:                  db,
 					NotificationService: notificationService,
 					EmittedAt:           time.Now(),
@@ -181,7 +208,7 @@ func writeToConnection(connection net.Conn, phonenumber string, db *database.Que
 	}
 }
 
-func StartTCPServer(port string, notificationService *services.Notification, db *database.Queries, connectionEventChannel chan eventhandlers.ConnectionEvent, quit <-chan os.Signal, wg *sync.WaitGroup) {
+func StartTCPServer(port string, jwtSecret string, notificationService *services.Notification, db *database.Queries, connectionEventChannel chan eventhandlers.ConnectionEvent, quit <-chan os.Signal, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	// loading server certificate and private key
@@ -214,9 +241,6 @@ func StartTCPServer(port string, notificationService *services.Notification, db
 		listener.Close()
 	}()
 
-	// creating a reader to read from connections when user get connected to add to notfication service
-	buffer := make([]byte, 8)
-
 	// listening for connections
 	for {
 		conn, err := listener.Accept()
@@ -235,16 +259,24 @@ func StartTCPServer(port string, notificationService *services.Notification, db
 			log.Printf("[TCP_SERVER]: error setting read deadline: %v", err)
 		}
 
-		n, err := conn.Read(buffer)
+		// the user is identified by the access token sent on connecting so that nobody can receive the events of another user
+		accessToken, err := readAccessToken(conn)
+		if err != nil {
+			log.Printf("[TCP SERVER]: error reading access token from connection: %v", err)
+			conn.Close()
+			continue
+		}
+
+		userID, err := middlewares.UserIDFromAccessToken(accessToken, jwtSecret)
 		if err != nil {
-			log.Printf("[TCP SERVER]: error reading phonenumber from connection: %v", err)
+			log.Printf("[TCP SERVER]: invalid access token sent by %s: %v", conn.RemoteAddr(), err)
+			conn.Close()
 			continue
 		}
 
-		phonenumber := strings.TrimSpace(string(buffer[:n]))
 		connectionEventChannel <- eventhandlers.ConnectionEvent{
 			Name:                "CONNECTED",
-			Phonenumber:         phonenumber,
+			UserID:              userID,
 			ConnectionInstance:  conn,
 			NotificationService: notificationService,
 			DB:                  nil,
@@ -253,7 +285,7 @@ func StartTCPServer(port string, notificationService *services.Notification, db
 
 		// launching a go routine for handling each connection
 		wg.Add(1)
-		go handleTLSConnections(conn, phonenumber, db, notificationService, connectionEventChannel, wg, quit)
+		go handleTLSConnections(conn, userID, db, notificationService, connectionEventChannel, wg, quit)
 	}
 
 	log.Println("[TCP SERVER]: Socket server stopped.")
@@ -0,0 +1,21 @@
+-- name: BlockUser :exec
+insert into blocked_users(user_id, blocked_user_id, created_at)
+values($1, $2, NOW())
+on conflict(user_id, blocked_user_id) do nothing;
+
+-- name: UnblockUser :execrows
+delete from blocked_users where user_id = $1 and blocked_user_id = $2;
+
+-- name: GetBlockedUsers :many
+select users.id, users.username, blocked_users.created_at from blocked_users
+join users on blocked_users.blocked_user_id = users.id
+where blocked_users.user_id = $1 order by blocked_users.created_at desc;
+
+-- name: IsUserBlocked :one
+select exists(select 1 from blocked_users where user_id = $1 and blocked_user_id = $2);
+
+-- name: IsBlockedBetween :one
+select exists(
+    select 1 from blocked_users
+    where (user_id = @user_id and blocked_user_id = @other_user_id) or (user_id = @other_user_id and blocked_user_id = @user_id)
+);
\ No newline at end of file
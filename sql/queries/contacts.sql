@@ -0,0 +1,25 @@
+-- name: AddContact :exec
+insert into contacts(user_id, contact_id, created_at)
+values($1, $2, NOW())
+on conflict(user_id, contact_id) do nothing;
+
+-- name: RemoveContact :execrows
+delete from contacts where user_id = $1 and contact_id = $2;
+
+-- name: GetContacts :many
+select users.id, users.username, users.display_name, users.phonenumber from contacts
+join users on contacts.contact_id = users.id
+where contacts.user_id = $1 order by users.username;
+
+-- name: GetUnblockedContactsIDs :many
+select contacts.contact_id from contacts
+where contacts.user_id = $1 and not exists (
+    select 1 from blocked_users
+    where (blocked_users.user_id = $1 and blocked_users.blocked_user_id = contacts.contact_id)
+    or (blocked_users.user_id = contacts.contact_id and blocked_users.blocked_user_id = $1)
+);
+
+-- name: DiscoverContacts :many
+select id, username, display_name, phonenumber from users
+where phonenumber_hash = any(@hashes::text[]) and id != @user_id
+and id not in (select blocked_users.user_id from blocked_users where blocked_users.blocked_user_id = @user_id);
\ No newline at end of file
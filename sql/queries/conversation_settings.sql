@@ -0,0 +1,34 @@
+-- name: SetConversationMute :exec
+insert into conversation_settings(user_id, other_user_id, group_id, muted_until, updated_at)
+values($1, $2, $3, $4, NOW())
+on conflict(user_id, conversation_id) do update set muted_until = excluded.muted_until, updated_at = excluded.updated_at;
+
+-- name: SetConversationArchived :exec
+insert into conversation_settings(user_id, other_user_id, group_id, archived, updated_at)
+values($1, $2, $3, $4, NOW())
+on conflict(user_id, conversation_id) do update set archived = excluded.archived, updated_at = excluded.updated_at;
+
+-- name: SetConversationPinned :exec
+insert into conversation_settings(user_id, other_user_id, group_id, pinned_at, updated_at)
+values($1, $2, $3, $4, NOW())
+on conflict(user_id, conversation_id) do update set pinned_at = excluded.pinned_at, updated_at = excluded.updated_at;
+
+-- name: CountPinnedConversations :one
+select count(*) from conversation_settings where user_id = $1 and pinned_at is not null;
+
+-- name: GetConversationSettings :many
+select other_user_id, group_id, muted_until, archived, pinned_at from conversation_settings where user_id = $1;
+
+-- name: IsConversationMuted :one
+select exists(select 1 from conversation_settings where user_id = $1 and other_user_id = $2 and muted_until > NOW());
+
+-- name: GetMutedGroupMembers :many
+select user_id from conversation_settings where group_id = $1 and muted_until > NOW();
+
+-- name: UnarchiveConversation :exec
+update conversation_settings set archived = false, updated_at = NOW()
+where archived and ((user_id = @user_id::uuid and other_user_id = @other_user_id::uuid)
+or (user_id = @other_user_id::uuid and other_user_id = @user_id::uuid));
+
+-- name: UnarchiveGroupConversation :exec
+update conversation_settings set archived = false, updated_at = NOW() where group_id = $1 and archived;
\ No newline at end of file
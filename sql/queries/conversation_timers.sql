@@ -0,0 +1,13 @@
+-- name: GetConversationTimer :one
+select duration, starts_on from conversation_timers
+where user_id = least(@user_id::uuid, @other_user_id::uuid) and other_user_id = greatest(@user_id::uuid, @other_user_id::uuid);
+
+-- name: SetConversationTimer :exec
+insert into conversation_timers(user_id, other_user_id, duration, starts_on, updated_by, updated_at)
+values(least(@user_id::uuid, @other_user_id::uuid), greatest(@user_id::uuid, @other_user_id::uuid), @duration, @starts_on, @user_id::uuid, NOW())
+on conflict(user_id, other_user_id) do update set duration = excluded.duration, starts_on = excluded.starts_on,
+updated_by = excluded.updated_by, updated_at = excluded.updated_at;
+
+-- name: RemoveConversationTimer :exec
+delete from conversation_timers
+where user_id = least(@user_id::uuid, @other_user_id::uuid) and other_user_id = greatest(@user_id::uuid, @other_user_id::uuid);
\ No newline at end of file
@@ -0,0 +1,31 @@
+-- name: CreateGroupInvite :one
+insert into group_invites(id, group_id, code, created_by, expires_at, max_uses, uses, requires_approval, created_at, updated_at)
+values(gen_random_uuid(), $1, $2, $3, $4, $5, 0, $6, NOW(), NOW())
+returning *;
+
+-- name: GetGroupInvites :many
+select * from group_invites where group_id = $1 order by created_at desc;
+
+-- name: GetGroupInviteByCode :one
+select * from group_invites where code = $1;
+
+-- name: UseGroupInvite :execrows
+update group_invites set uses = uses + 1, updated_at = NOW()
+where id = $1 and (expires_at is null or expires_at > NOW()) and (max_uses is null or uses < max_uses);
+
+-- name: RevokeGroupInvite :execrows
+delete from group_invites where id = $1 and group_id = $2;
+
+-- name: CreateGroupJoinRequest :execrows
+insert into group_join_requests(group_id, user_id, invite_id, created_at)
+values($1, $2, $3, NOW())
+on conflict(group_id, user_id) do nothing;
+
+-- name: GetGroupJoinRequests :many
+select group_join_requests.user_id, users.username, users.display_name, group_join_requests.created_at
+from group_join_requests join users on group_join_requests.user_id = users.id
+where group_join_requests.group_id = $1
+order by group_join_requests.created_at;
+
+-- name: RemoveGroupJoinRequest :execrows
+delete from group_join_requests where group_id = $1 and user_id = $2;
\ No newline at end of file
@@ -3,40 +3,81 @@ insert into groups(id, name, created_at, updated_at)
 values(gen_random_uuid(), $1, NOW(), NOW())
 returning *;
 
+-- name: CreateChannel :one
+insert into groups(id, name, description, type, handle, only_admins_can_post, created_at, updated_at)
+values(gen_random_uuid(), $1, $2, 'channel', $3, true, NOW(), NOW())
+returning *;
+
+-- name: GetGroupByHandle :one
+select * from groups where handle = $1;
+
 -- name: UpdateGroup :one
-update groups set name = $1 where id = $2
-returning name, updated_at;
+update groups set name = $1, description = $2, updated_at = NOW() where id = $3
+returning name, description, updated_at;
+
+-- name: GetGroup :one
+select * from groups where id = $1;
+
+-- name: UpdateGroupAvatar :exec
+update groups set avatar_key = $1, updated_at = NOW() where id = $2;
+
+-- name: UpdateGroupSettings :one
+update groups set member_limit = $1, only_admins_can_post = $2, only_admins_can_edit_info = $3, updated_at = NOW()
+where id = $4
+returning member_limit, only_admins_can_post, only_admins_can_edit_info, updated_at;
 
 -- name: AddUserToGroup :exec
-insert into users_groups(user_id, group_id, created_at)
-values($1, $2, NOW());
+insert into users_groups(user_id, group_id, role, created_at)
+values($1, $2, $3, NOW());
 
 -- name: RemoveUserFromGroup :exec
 delete from users_groups where user_id = $1 and group_id = $2;
 
--- name: MakeUserAdmin :exec
-insert into group_admins(user_id, group_id, created_at)
-values($1, $2, NOW());
+-- name: GetGroupMemberRole :one
+select role from users_groups where user_id = $1 and group_id = $2;
 
--- name: RemoveUserFromAdmin :exec
-delete from group_admins where user_id = $1 and group_id = $2;
+-- name: UpdateGroupMemberRole :exec
+update users_groups set role = $1 where user_id = $2 and group_id = $3;
 
 -- name: GetGroupMembers :many
-select users.id, users.username from users
+select users.id, users.username, users_groups.role from users
 join users_groups on users.id = users_groups.user_id
 where users_groups.group_id = $1 and users.id != $2;
 
--- name: GetGroupMembersPhonenumbers :many
-select users.phonenumber from users_groups join users on users_groups.user_id = users.id where users_groups.group_id = $1;
+-- name: GetGroupMembersIDs :many
+select user_id from users_groups where group_id = $1;
 
 -- name: GetGroupAdmins :many
-select groups.name, users.username from groups join group_admins on groups.id = group_admins.group_id join users on users.id = group_admins.user_id where groups.id = $1;
+select users.id, users.username, users_groups.role from users_groups join users on users.id = users_groups.user_id
+where users_groups.group_id = $1 and users_groups.role in ('owner', 'admin');
 
 -- name: DeleteGroup :exec
 delete from groups where id = $1;
 
--- name: IsUserAdmin :one
-select 1 from group_admins where user_id = $1 and group_id = $2;
-
 -- name: GroupMembersCount :one
-select count(*) from users_groups where group_id = $1;
\ No newline at end of file
+select count(*) from users_groups where group_id = $1;
+
+-- name: GetUserGroupsForExport :many
+select groups.id, groups.name, users_groups.created_at as joined_at from groups
+join users_groups on groups.id = users_groups.group_id where users_groups.user_id = $1;
+
+-- name: LockGroup :one
+select id from groups where id = $1 for update;
+
+-- name: GetOwnedGroupsIDs :many
+select group_id from users_groups where user_id = $1 and role = 'owner';
+
+-- name: PromoteGroupSuccessor :one
+update users_groups set role = 'owner'
+where group_id = $1 and user_id = (
+    select members.user_id from users_groups as members where members.group_id = $1
+    order by case members.role when 'admin' then 1 when 'moderator' then 2 else 3 end, members.created_at
+    limit 1
+) and not exists (select 1 from users_groups as owners where owners.group_id = $1 and owners.role = 'owner')
+returning user_id;
+
+-- name: DeleteGroupIfEmpty :execrows
+delete from groups where id = $1 and not exists (select 1 from users_groups where group_id = $1);
+
+-- name: UpdateGroupMessageTimer :exec
+update groups set message_timer = $1, updated_at = NOW() where id = $2;
\ No newline at end of file
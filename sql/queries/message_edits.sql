@@ -0,0 +1,15 @@
+-- name: GetMessageForEdit :one
+select description, kind, created_at > NOW() - make_interval(secs => @edit_window::integer) as editable
+from messages where id = @id and sender_id = @sender_id
+and deleted_at is null and (expires_at is null or expires_at > NOW())
+for update;
+
+-- name: CreateMessageEdit :exec
+insert into message_edits(id, message_id, description, edited_at)
+values(gen_random_uuid(), $1, $2, NOW());
+
+-- name: GetMessageEdits :many
+select description, edited_at from message_edits where message_id = $1 order by edited_at;
+
+-- name: DeleteMessageEdits :exec
+delete from message_edits where message_id = $1;
\ No newline at end of file
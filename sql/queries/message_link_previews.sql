@@ -0,0 +1,8 @@
+-- name: CreateMessageLinkPreview :execrows
+insert into message_link_previews(message_id, url, title, description, image_url, created_at)
+select id, $2, $3, $4, $5, NOW() from messages where id = $1 and deleted_at is null
+on conflict(message_id) do nothing;
+
+-- name: GetLinkPreviewsByMessageIDs :many
+select message_id, url, title, description, image_url, created_at from message_link_previews
+where message_id = any(@message_ids::uuid[]);
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- name: CreateMessageMention :exec
+insert into message_mentions(message_id, user_id, group_id, created_at)
+values($1, $2, $3, NOW())
+on conflict(message_id, user_id) do nothing;
+
+-- name: GetMentionsForUser :many
+select messages.id, messages.description, messages.sender_id, users.username as sender_username,
+groups.id as group_id, groups.name as group_name, message_mentions.created_at
+from message_mentions
+join messages on message_mentions.message_id = messages.id
+join groups on message_mentions.group_id = groups.id
+join users on messages.sender_id = users.id
+join users_groups on users_groups.group_id = message_mentions.group_id and users_groups.user_id = message_mentions.user_id
+where message_mentions.user_id = $1 and message_mentions.created_at < $2
+and messages.deleted_at is null and (messages.expires_at is null or messages.expires_at > NOW())
+and not exists (
+    select 1 from group_message_receivers
+    where group_message_receivers.message_id = messages.id
+    and group_message_receivers.member_id = message_mentions.user_id
+    and group_message_receivers.is_allowed_to_see = false
+)
+order by message_mentions.created_at desc limit 20;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- name: CreateMessageRequest :exec
+insert into message_requests(sender_id, receiver_id, status, created_at, updated_at)
+values($1, $2, 'pending', NOW(), NOW())
+on conflict(sender_id, receiver_id) do nothing;
+
+-- name: GetMessageRequestStatus :one
+select status from message_requests where sender_id = $1 and receiver_id = $2;
+
+-- name: UpdateMessageRequestStatus :execrows
+update message_requests set status = $1, updated_at = NOW()
+where sender_id = $2 and receiver_id = $3 and status = 'pending';
+
+-- name: GetPendingMessageRequests :many
+select message_requests.sender_id, users.username, users.display_name, message_requests.created_at,
+(select count(*) from messages where messages.sender_id = message_requests.sender_id and messages.reciever_id = message_requests.receiver_id) as total_messages
+from message_requests join users on message_requests.sender_id = users.id
+where message_requests.receiver_id = $1 and message_requests.status = 'pending'
+order by message_requests.created_at desc;
\ No newline at end of file
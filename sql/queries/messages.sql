@@ -1,17 +1,18 @@
 -- name: CreateMessage :one
 insert into messages(
     id, description, sender_id, reciever_id,
-    group_id, sent, created_at, updated_at
+    group_id, sent, expires_at, expires_after, forwarded, forward_count,
+    kind, content, created_at, updated_at
 )
 values(
     gen_random_uuid(),
-    $1, $2, $3, $4, $5, NOW(), NOW()
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), NOW()
 )
 returning *;
 
 -- name: UpdateMessage :one
-update messages set description = $1, updated_at = NOW() where id = $2 and sender_id = $3 and group_id = $4
-returning description, sender_id, reciever_id, group_id, sent, recieved, read, is_receiver_allowed_to_see, created_at, updated_at;
+update messages set description = $1, edit_count = edit_count + 1, updated_at = NOW() where id = $2 and sender_id = $3
+returning description, sender_id, reciever_id, group_id, sent, recieved, read, is_receiver_allowed_to_see, edit_count, created_at, updated_at;
 
 -- name: GetMessageSenderReceiverAndGroupID :one
 select sender_id, reciever_id, group_id from messages where id = $1;
@@ -23,10 +24,19 @@ update messages set is_sender_allowed_to_see = false where sender_id = $1 and re
 update messages set is_receiver_allowed_to_see = false where sender_id = $1 and reciever_id = $2;
 
 -- name: GetAllMessages :many
-select * from messages where sender_id = $1 and reciever_id = $2 and created_at < $3 order by created_at limit 10;
+select * from (
+    select * from messages
+    where ((sender_id = @user_id::uuid and reciever_id = @other_user_id::uuid) or (sender_id = @other_user_id::uuid and reciever_id = @user_id::uuid))
+    and created_at < @before and (expires_at is null or expires_at > NOW())
+    order by created_at desc limit 10
+) as page order by created_at;
 
 -- name: GetAllGroupMessages :many
-select * from messages where group_id = $1 and created_at < $2 order by created_at limit 10;
+select * from (
+    select * from messages where group_id = $1 and created_at < $2
+    and (expires_at is null or expires_at > NOW())
+    order by created_at desc limit 10
+) as page order by created_at;
 
 -- name: GetLatestMessagesByRecieverID :many
 select users.username as sender, messages.description as messages, count(*) as total_new_messages
@@ -43,8 +53,10 @@ update messages set received = true and updated_at = NOW() where id = $1
 returning updated_at;
 
 -- name: MarkMessageRead :one
-update messages set read = true and updated_at = NOW() where id = $1
-returning updated_at;
+update messages set read = true, updated_at = NOW(),
+expires_at = coalesce(expires_at, NOW() + make_interval(secs => expires_after))
+where id = $1
+returning updated_at, expires_at;
 
 -- name: MarkGroupMessageRead :exec
 insert into group_message_read(message_id, group_member_id, group_id, read_at)
@@ -86,4 +98,52 @@ update group_message_receivers set is_allowed_to_see = false where group_id = $1
 update group_message_receivers set is_allowed_to_see = true where group_id = $1 and member_id = $2;
 
 -- name: IsGroupMemberAllowedToSeeMessage :one
-select is_allowed_to_see from group_message_receivers where message_id = $1 and group_id = $2 and member_id = $3;
\ No newline at end of file
+select is_allowed_to_see from group_message_receivers where message_id = $1 and group_id = $2 and member_id = $3;
+
+-- name: GetMessagesHiddenFromGroupMember :many
+select message_id from group_message_receivers
+where group_id = @group_id and member_id = @member_id and message_id = any(@message_ids::uuid[]) and is_allowed_to_see = false;
+
+-- name: AnonymiseSentMessages :exec
+update messages set sender_id = @deleted_user_id where sender_id = @user_id;
+
+-- name: AnonymiseReceivedMessages :exec
+update messages set reciever_id = @deleted_user_id where reciever_id = @user_id;
+
+-- name: GetUserConversations :many
+select reciever_id as other_user_id, group_id from messages where sender_id = @user_id::uuid
+union
+select sender_id as other_user_id, group_id from messages where reciever_id = @user_id::uuid;
+
+-- name: GetUserMessagesForExport :many
+select id, description, sender_id, reciever_id, group_id, created_at, updated_at from messages
+where sender_id = $1 or reciever_id = $1 order by created_at;
+
+-- name: HasSentMessageTo :one
+select exists(select 1 from messages where sender_id = $1 and reciever_id = $2);
+
+-- name: DeleteExpiredMessages :many
+delete from messages where id in (
+    select id from messages where expires_at <= NOW()
+    order by expires_at limit $1
+    for update skip locked
+)
+returning id, sender_id, reciever_id, group_id;
+
+-- name: GetMessageForDelete :one
+select sender_id, reciever_id, group_id, kind, deleted_at,
+created_at > NOW() - make_interval(secs => @delete_window::integer) as deletable_for_everyone
+from messages where id = @id;
+
+-- name: HideMessageForSender :execrows
+update messages set is_sender_allowed_to_see = false where id = $1 and sender_id = $2;
+
+-- name: HideMessageForReceiver :execrows
+update messages set is_receiver_allowed_to_see = false where id = $1 and reciever_id = $2;
+
+-- name: DeleteMessageForEveryone :execrows
+update messages set description = '', content = '{}', deleted_at = NOW(), updated_at = NOW() where id = $1 and deleted_at is null;
+
+-- name: GetMessageForForward :one
+select description, sender_id, reciever_id, group_id, is_sender_allowed_to_see, is_receiver_allowed_to_see, forward_count, kind, content
+from messages where id = $1 and deleted_at is null and (expires_at is null or expires_at > NOW());
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- name: CreatePasswordResetToken :exec
+insert into password_reset_tokens(token, user_id, created_at, expires_at)
+values($1, $2, NOW(), $3);
+
+-- name: ConsumePasswordResetToken :one
+delete from password_reset_tokens where token = $1 and expires_at > NOW()
+returning user_id;
+
+-- name: RemovePasswordResetTokens :exec
+delete from password_reset_tokens where user_id = $1;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- name: CreatePhonenumberChangeRequest :exec
+insert into phonenumber_change_requests(user_id, new_phonenumber, created_at, expires_at)
+values($1, $2, NOW(), $3)
+on conflict(user_id) do update set new_phonenumber = excluded.new_phonenumber, created_at = excluded.created_at, expires_at = excluded.expires_at;
+
+-- name: GetPhonenumberChangeRequest :one
+select new_phonenumber from phonenumber_change_requests where user_id = $1 and expires_at > NOW();
+
+-- name: RemovePhonenumberChangeRequest :exec
+delete from phonenumber_change_requests where user_id = $1;
\ No newline at end of file
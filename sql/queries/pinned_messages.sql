@@ -0,0 +1,34 @@
+-- name: PinMessage :execrows
+insert into pinned_messages(message_id, pinned_by, created_at)
+values($1, $2, NOW())
+on conflict(message_id) do nothing;
+
+-- name: UnpinMessage :execrows
+delete from pinned_messages where message_id = $1;
+
+-- name: CountGroupPinnedMessages :one
+select count(*) from pinned_messages join messages on pinned_messages.message_id = messages.id
+where messages.group_id = $1 and messages.deleted_at is null and (messages.expires_at is null or messages.expires_at > NOW());
+
+-- name: CountConversationPinnedMessages :one
+select count(*) from pinned_messages join messages on pinned_messages.message_id = messages.id
+where ((messages.sender_id = @user_id::uuid and messages.reciever_id = @other_user_id::uuid)
+or (messages.sender_id = @other_user_id::uuid and messages.reciever_id = @user_id::uuid))
+and messages.deleted_at is null and (messages.expires_at is null or messages.expires_at > NOW());
+
+-- name: GetGroupPinnedMessages :many
+select messages.id, messages.description, messages.sender_id, messages.created_at, pinned_messages.pinned_by, pinned_messages.created_at as pinned_at
+from pinned_messages join messages on pinned_messages.message_id = messages.id
+where messages.group_id = $1 and messages.deleted_at is null and (messages.expires_at is null or messages.expires_at > NOW())
+order by pinned_messages.created_at desc;
+
+-- name: GetConversationPinnedMessages :many
+select messages.id, messages.description, messages.sender_id, messages.created_at, pinned_messages.pinned_by, pinned_messages.created_at as pinned_at
+from pinned_messages join messages on pinned_messages.message_id = messages.id
+where ((messages.sender_id = @user_id::uuid and messages.reciever_id = @other_user_id::uuid)
+or (messages.sender_id = @other_user_id::uuid and messages.reciever_id = @user_id::uuid))
+and messages.deleted_at is null and (messages.expires_at is null or messages.expires_at > NOW())
+order by pinned_messages.created_at desc;
+
+-- name: LockConversation :exec
+select pg_advisory_xact_lock(hashtextextended(least(@user_id::uuid, @other_user_id::uuid)::text || greatest(@user_id::uuid, @other_user_id::uuid)::text, 0));
\ No newline at end of file
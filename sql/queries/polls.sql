@@ -0,0 +1,38 @@
+-- name: CreatePoll :one
+insert into polls(id, message_id, group_id, created_by, question, multiple_choice, anonymous, closes_at, created_at)
+values(gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, NOW())
+returning *;
+
+-- name: CreatePollOption :exec
+insert into poll_options(id, poll_id, position, text)
+values(gen_random_uuid(), $1, $2, $3);
+
+-- name: GetPollForUpdate :one
+select polls.id, polls.message_id, polls.group_id, polls.created_by, polls.question, polls.multiple_choice, polls.anonymous, polls.closes_at, polls.closed_at, polls.created_at, messages.deleted_at from polls join messages on polls.message_id = messages.id
+where polls.id = $1 for update of polls;
+
+-- name: GetPollsByMessageIDs :many
+select * from polls where message_id = any(@message_ids::uuid[]);
+
+-- name: GetPollResults :many
+select poll_options.id, poll_options.text, count(poll_votes.user_id) as votes
+from poll_options left join poll_votes on poll_options.id = poll_votes.option_id
+where poll_options.poll_id = $1
+group by poll_options.id, poll_options.position, poll_options.text
+order by poll_options.position;
+
+-- name: GetPollVoters :many
+select option_id, user_id from poll_votes where poll_id = $1 order by created_at;
+
+-- name: GetUserPollVotes :many
+select option_id from poll_votes where poll_id = $1 and user_id = $2;
+
+-- name: CreatePollVote :execrows
+insert into poll_votes(poll_id, option_id, user_id, created_at)
+select poll_id, id, @user_id::uuid, NOW() from poll_options where poll_id = @poll_id and id = @option_id::uuid;
+
+-- name: DeletePollVotes :execrows
+delete from poll_votes where poll_id = $1 and user_id = $2;
+
+-- name: ClosePoll :execrows
+update polls set closed_at = NOW() where id = $1 and closed_at is null;
\ No newline at end of file
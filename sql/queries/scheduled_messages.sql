@@ -0,0 +1,26 @@
+-- name: CreateScheduledMessage :one
+insert into scheduled_messages(id, sender_id, receiver_id, group_id, description, send_at, status, created_at, updated_at)
+values(gen_random_uuid(), $1, $2, $3, $4, $5, 'pending', NOW(), NOW())
+returning *;
+
+-- name: GetScheduledMessages :many
+select * from scheduled_messages where sender_id = $1 and status <> 'sent' order by send_at;
+
+-- name: UpdateScheduledMessage :one
+update scheduled_messages set description = $1, send_at = $2, updated_at = NOW()
+where id = $3 and sender_id = $4 and status = 'pending'
+returning *;
+
+-- name: CancelScheduledMessage :execrows
+delete from scheduled_messages where id = $1 and sender_id = $2 and status <> 'sent';
+
+-- name: ClaimDueScheduledMessage :one
+select * from scheduled_messages where status = 'pending' and send_at <= NOW()
+order by send_at limit 1
+for update skip locked;
+
+-- name: MarkScheduledMessageSent :exec
+update scheduled_messages set status = 'sent', message_id = $1, updated_at = NOW() where id = $2;
+
+-- name: MarkScheduledMessageFailed :exec
+update scheduled_messages set status = 'failed', updated_at = NOW() where id = $1;
\ No newline at end of file
@@ -1,12 +1,12 @@
 -- name: CreateUser :one
-insert into users(id, phonenumber, username, password, created_at, updated_at)
+insert into users(id, phonenumber, phonenumber_hash, username, password, created_at, updated_at)
 values(
-    gen_random_uuid(), $1, $2, $3, NOW(), NOW()
+    gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW()
 )
 returning id, phonenumber, username, created_at, updated_at;
 
 -- name: UpdatePhonenumber :exec
-update users set phonenumber = $1 where id = $2;
+update users set phonenumber = $1, phonenumber_hash = $2 where id = $3;
 
 -- name: UpdatePassword :exec
 update users set password = $1 where id = $2;
@@ -28,7 +28,56 @@ select phonenumber, username, created_at, updated_at from users where id = $1;
 select 1 from users where phonenumber = $1;
 
 -- name: SetLastAvailable :exec
-update users set last_available = NOW() where phonenumber = $1;
+update users set last_available = NOW() where id = $1;
 
 -- name: RemoveUser :exec
-delete from users where id = $1;
\ No newline at end of file
+delete from users where id = $1;
+
+-- name: GetUserPasswordByID :one
+select password from users where id = $1;
+
+-- name: ScheduleUserDeletion :exec
+update users set deletion_scheduled_at = $1, updated_at = NOW() where id = $2;
+
+-- name: CancelUserDeletion :execrows
+update users set deletion_scheduled_at = null, updated_at = NOW() where id = $1 and deletion_scheduled_at is not null;
+
+-- name: GetUsersDueForDeletion :many
+select id from users where deletion_scheduled_at <= NOW();
+
+-- name: GetUserProfileForExport :one
+select id, phonenumber, username, last_available, deletion_scheduled_at, created_at, updated_at from users where id = $1;
+
+-- name: GetUserProfile :one
+select id, username, display_name, status, avatar_key, last_available, last_seen_privacy,
+read_receipts_privacy, profile_photo_privacy, created_at from users where id = $1;
+
+-- name: UpdateUserProfile :one
+update users set display_name = $1, status = $2, updated_at = NOW() where id = $3
+returning display_name, status, updated_at;
+
+-- name: UpdateUserAvatar :exec
+update users set avatar_key = $1, updated_at = NOW() where id = $2;
+
+-- name: GetUserPrivacySettings :one
+select last_seen_privacy, read_receipts_privacy, profile_photo_privacy from users where id = $1;
+
+-- name: UpdateUserPrivacySettings :one
+update users set last_seen_privacy = $1, read_receipts_privacy = $2, profile_photo_privacy = $3, updated_at = NOW()
+where id = $4
+returning last_seen_privacy, read_receipts_privacy, profile_photo_privacy;
+
+-- name: IsUserContact :one
+select exists(select 1 from contacts where user_id = @user_id and contact_id = @contact_id);
+
+-- name: GetUsersWithoutPhonenumberHash :many
+select id, phonenumber from users where phonenumber_hash is null and not deleted;
+
+-- name: SetPhonenumberHash :exec
+update users set phonenumber_hash = $1 where id = $2;
+
+-- name: CreateDeletedUser :one
+insert into users(id, phonenumber, username, password, deleted, created_at, updated_at)
+select new_user.id, 'deleted:' || new_user.id::text, 'deleted user', '', true, NOW(), NOW()
+from (select gen_random_uuid() as id) as new_user
+returning id;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+create table password_reset_tokens(
+    token text not null unique,
+    user_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+drop table password_reset_tokens;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+create table phonenumber_change_requests(
+    user_id uuid not null unique references users(id) on delete cascade,
+    new_phonenumber varchar(13) not null,
+    created_at timestamp not null,
+    expires_at timestamp not null
+);
+
+-- +goose Down
+drop table phonenumber_change_requests;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+alter table users add column deletion_scheduled_at timestamp;
+
+-- reserved account to which the messages of deleted users are reassigned
+insert into users(id, phonenumber, username, password, created_at, updated_at)
+values('ffffffff-ffff-ffff-ffff-ffffffffffff', '+000000000000', 'deleted user', '', NOW(), NOW());
+
+-- +goose Down
+delete from users where id = 'ffffffff-ffff-ffff-ffff-ffffffffffff';
+alter table users drop column deletion_scheduled_at;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- +goose Up
+alter table users add column display_name varchar(50),
+add column status varchar(140),
+add column avatar_key text,
+add column last_seen_privacy varchar(10) not null default 'everyone' check (last_seen_privacy in ('everyone', 'contacts', 'nobody')),
+add column read_receipts_privacy varchar(10) not null default 'everyone' check (read_receipts_privacy in ('everyone', 'contacts', 'nobody')),
+add column profile_photo_privacy varchar(10) not null default 'everyone' check (profile_photo_privacy in ('everyone', 'contacts', 'nobody'));
+
+-- +goose Down
+alter table users drop column display_name,
+drop column status,
+drop column avatar_key,
+drop column last_seen_privacy,
+drop column read_receipts_privacy,
+drop column profile_photo_privacy;
\ No newline at end of file
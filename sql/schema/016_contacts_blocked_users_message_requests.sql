@@ -0,0 +1,28 @@
+-- +goose Up
+create table contacts(
+    user_id uuid not null references users(id) on delete cascade,
+    contact_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    unique(user_id, contact_id)
+);
+
+create table blocked_users(
+    user_id uuid not null references users(id) on delete cascade,
+    blocked_user_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    unique(user_id, blocked_user_id)
+);
+
+create table message_requests(
+    sender_id uuid not null references users(id) on delete cascade,
+    receiver_id uuid not null references users(id) on delete cascade,
+    status varchar(10) not null default 'pending' check (status in ('pending', 'accepted', 'declined')),
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    unique(sender_id, receiver_id)
+);
+
+-- +goose Down
+drop table message_requests;
+drop table blocked_users;
+drop table contacts;
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- +goose Up
+alter table users_groups add column role varchar(10) not null default 'member' check (role in ('owner', 'admin', 'moderator', 'member'));
+
+-- admins were not always members of their group
+insert into users_groups(user_id, group_id, created_at, role)
+select user_id, group_id, created_at, 'admin' from group_admins
+on conflict(user_id, group_id) do update set role = 'admin';
+
+-- oldest admin of every group becomes its owner
+update users_groups set role = 'owner' from (
+    select distinct on (group_id) group_id, user_id from group_admins order by group_id, created_at
+) as owners
+where users_groups.group_id = owners.group_id and users_groups.user_id = owners.user_id;
+
+drop table group_admins;
+
+-- +goose Down
+create table group_admins(
+    group_id uuid not null references groups(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    unique(group_id, user_id)
+);
+
+insert into group_admins(group_id, user_id, created_at)
+select group_id, user_id, created_at from users_groups where role in ('owner', 'admin');
+
+alter table users_groups drop column role;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- +goose Up
+create table group_invites(
+    id uuid primary key,
+    group_id uuid not null references groups(id) on delete cascade,
+    code varchar(32) unique not null,
+    created_by uuid not null references users(id) on delete cascade,
+    expires_at timestamp,
+    max_uses integer check (max_uses > 0),
+    uses integer not null default 0,
+    requires_approval boolean not null default false,
+    created_at timestamp not null,
+    updated_at timestamp not null
+);
+
+create table group_join_requests(
+    group_id uuid not null references groups(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    invite_id uuid references group_invites(id) on delete set null,
+    created_at timestamp not null,
+    unique(group_id, user_id)
+);
+
+-- +goose Down
+drop table group_join_requests;
+drop table group_invites;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+-- groups without admins before roles were introduced have no owner
+update users_groups set role = 'owner' from (
+    select distinct on (group_id) group_id, user_id from users_groups
+    where group_id not in (select group_id from users_groups where role = 'owner')
+    order by group_id, case role when 'admin' then 1 when 'moderator' then 2 else 3 end, created_at
+) as successors
+where users_groups.group_id = successors.group_id and users_groups.user_id = successors.user_id;
+
+delete from groups where not exists (select 1 from users_groups where users_groups.group_id = groups.id);
+
+-- +goose Down
+-- promoted owners and removed groups can not be restored
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+alter table groups add column description varchar(500);
+alter table groups add column avatar_key text;
+alter table groups add column member_limit integer not null default 256 check (member_limit between 2 and 1024);
+alter table groups add column only_admins_can_post boolean not null default false;
+alter table groups add column only_admins_can_edit_info boolean not null default true;
+
+-- +goose Down
+alter table groups drop column only_admins_can_edit_info;
+alter table groups drop column only_admins_can_post;
+alter table groups drop column member_limit;
+alter table groups drop column avatar_key;
+alter table groups drop column description;
\ No newline at end of file
@@ -0,0 +1,9 @@
+-- +goose Up
+create table pinned_messages(
+    message_id uuid not null primary key references messages(id) on delete cascade,
+    pinned_by uuid not null references users(id) on delete cascade,
+    created_at timestamp not null
+);
+
+-- +goose Down
+drop table pinned_messages;
\ No newline at end of file
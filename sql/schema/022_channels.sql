@@ -0,0 +1,7 @@
+-- +goose Up
+alter table groups add column type varchar(10) not null default 'group' check (type in ('group', 'channel'));
+alter table groups add column handle varchar(32) unique;
+
+-- +goose Down
+alter table groups drop column handle;
+alter table groups drop column type;
\ No newline at end of file
@@ -0,0 +1,19 @@
+-- +goose Up
+create table scheduled_messages(
+    id uuid not null primary key,
+    sender_id uuid not null references users(id) on delete cascade,
+    receiver_id uuid references users(id) on delete cascade,
+    group_id uuid references groups(id) on delete cascade,
+    description text not null,
+    send_at timestamp not null,
+    status varchar(10) not null default 'pending' check (status in ('pending', 'sent', 'failed')),
+    message_id uuid references messages(id) on delete set null,
+    created_at timestamp not null,
+    updated_at timestamp not null,
+    check ((receiver_id is null) <> (group_id is null))
+);
+
+create index scheduled_messages_due_idx on scheduled_messages(send_at) where status = 'pending';
+
+-- +goose Down
+drop table scheduled_messages;
\ No newline at end of file
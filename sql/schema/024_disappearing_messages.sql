@@ -0,0 +1,24 @@
+-- +goose Up
+alter table messages add column expires_at timestamp;
+alter table messages add column expires_after integer;
+create index messages_expires_at_idx on messages(expires_at) where expires_at is not null;
+
+alter table groups add column message_timer integer check (message_timer > 0);
+
+create table conversation_timers(
+    user_id uuid not null references users(id) on delete cascade,
+    other_user_id uuid not null references users(id) on delete cascade,
+    duration integer not null check (duration > 0),
+    starts_on varchar(4) not null default 'sent' check (starts_on in ('sent', 'read')),
+    updated_by uuid not null references users(id) on delete cascade,
+    updated_at timestamp not null,
+    check (user_id < other_user_id),
+    unique(user_id, other_user_id)
+);
+
+-- +goose Down
+drop table conversation_timers;
+alter table groups drop column message_timer;
+drop index messages_expires_at_idx;
+alter table messages drop column expires_after;
+alter table messages drop column expires_at;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- +goose Up
+create table message_edits(
+    id uuid not null primary key,
+    message_id uuid not null references messages(id) on delete cascade,
+    description text not null,
+    edited_at timestamp not null
+);
+
+create index message_edits_message_id_idx on message_edits(message_id, edited_at);
+
+alter table messages add column edit_count integer not null default 0;
+
+-- +goose Down
+alter table messages drop column edit_count;
+drop table message_edits;
\ No newline at end of file
@@ -0,0 +1,5 @@
+-- +goose Up
+alter table messages add column deleted_at timestamp;
+
+-- +goose Down
+alter table messages drop column deleted_at;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- +goose Up
+alter table messages add column forwarded boolean not null default false;
+alter table messages add column forward_count integer not null default 0;
+
+-- +goose Down
+alter table messages drop column forward_count;
+alter table messages drop column forwarded;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- +goose Up
+create table message_mentions(
+    message_id uuid not null references messages(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    group_id uuid not null references groups(id) on delete cascade,
+    created_at timestamp not null,
+    primary key(message_id, user_id)
+);
+
+create index message_mentions_user_id_idx on message_mentions(user_id, created_at);
+
+-- +goose Down
+drop table message_mentions;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- +goose Up
+create table conversation_settings(
+    user_id uuid not null references users(id) on delete cascade,
+    other_user_id uuid references users(id) on delete cascade,
+    group_id uuid references groups(id) on delete cascade,
+    conversation_id uuid not null generated always as (coalesce(group_id, other_user_id)) stored,
+    muted_until timestamp,
+    archived boolean not null default false,
+    pinned_at timestamp,
+    updated_at timestamp not null,
+    check ((other_user_id is null) <> (group_id is null)),
+    primary key(user_id, conversation_id)
+);
+
+create index conversation_settings_group_id_idx on conversation_settings(group_id) where group_id is not null;
+
+-- +goose Down
+drop table conversation_settings;
\ No newline at end of file
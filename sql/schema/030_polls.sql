@@ -0,0 +1,36 @@
+-- +goose Up
+create table polls(
+    id uuid not null primary key,
+    message_id uuid not null unique references messages(id) on delete cascade,
+    group_id uuid not null references groups(id) on delete cascade,
+    created_by uuid not null references users(id) on delete cascade,
+    question varchar(300) not null,
+    multiple_choice boolean not null default false,
+    anonymous boolean not null default false,
+    closes_at timestamp,
+    closed_at timestamp,
+    created_at timestamp not null
+);
+
+create table poll_options(
+    id uuid not null primary key,
+    poll_id uuid not null references polls(id) on delete cascade,
+    position integer not null,
+    text varchar(100) not null,
+    unique(poll_id, position)
+);
+
+create table poll_votes(
+    poll_id uuid not null references polls(id) on delete cascade,
+    option_id uuid not null references poll_options(id) on delete cascade,
+    user_id uuid not null references users(id) on delete cascade,
+    created_at timestamp not null,
+    primary key(option_id, user_id)
+);
+
+create index poll_votes_poll_id_idx on poll_votes(poll_id, user_id);
+
+-- +goose Down
+drop table poll_votes;
+drop table poll_options;
+drop table polls;
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- +goose Up
+create table message_link_previews(
+    message_id uuid primary key references messages(id) on delete cascade,
+    url text not null,
+    title text not null,
+    description text not null,
+    image_url text not null,
+    created_at timestamp not null
+);
+
+-- +goose Down
+drop table message_link_previews;
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- +goose Up
+alter table messages add column kind varchar(20) not null default 'text'
+check (kind in ('text', 'system', 'attachment', 'location', 'contact', 'poll'));
+alter table messages add column content jsonb not null default '{}';
+
+update messages set kind = 'poll' where id in (select message_id from polls);
+
+-- +goose Down
+alter table messages drop column content;
+alter table messages drop column kind;
\ No newline at end of file
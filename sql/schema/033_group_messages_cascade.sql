@@ -0,0 +1,8 @@
+-- +goose Up
+-- dissolving a group removes its history along with it
+alter table messages drop constraint messages_group_id_fkey;
+alter table messages add constraint messages_group_id_fkey foreign key (group_id) references groups(id) on delete cascade;
+
+-- +goose Down
+alter table messages drop constraint messages_group_id_fkey;
+alter table messages add constraint messages_group_id_fkey foreign key (group_id) references groups(id);
\ No newline at end of file
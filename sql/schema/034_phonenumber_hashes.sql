@@ -0,0 +1,9 @@
+-- +goose Up
+-- keyed hash of the sha256 of the phonenumber uploaded by contact discovery, it is filled by the
+-- server because the key never leaves it
+alter table users add column phonenumber_hash text;
+create index users_phonenumber_hash_idx on users(phonenumber_hash);
+
+-- +goose Down
+drop index users_phonenumber_hash_idx;
+alter table users drop column phonenumber_hash;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- +goose Up
+-- every deleted account gets its own placeholder so that its one-to-one conversations are not merged with
+-- the ones of other deleted accounts, the placeholder keeps the phonenumber column unique with 'deleted:<id>'
+alter table users alter column phonenumber type varchar(50);
+alter table users add column deleted boolean not null default false;
+update users set deleted = true where id = 'ffffffff-ffff-ffff-ffff-ffffffffffff';
+
+-- +goose Down
+update messages set sender_id = 'ffffffff-ffff-ffff-ffff-ffffffffffff'
+where sender_id in (select id from users where deleted and id != 'ffffffff-ffff-ffff-ffff-ffffffffffff');
+update messages set reciever_id = 'ffffffff-ffff-ffff-ffff-ffffffffffff'
+where reciever_id in (select id from users where deleted and id != 'ffffffff-ffff-ffff-ffff-ffffffffffff');
+delete from users where deleted and id != 'ffffffff-ffff-ffff-ffff-ffffffffffff';
+alter table users drop column deleted;
+alter table users alter column phonenumber type varchar(13);
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- +goose Up
+-- groups left without members before leaving a group dissolved it are removed along with their history
+delete from groups where not exists (select 1 from users_groups where users_groups.group_id = groups.id);
+
+-- +goose Down
+-- removed groups can not be restored
\ No newline at end of file
@@ -34,3 +34,8 @@ func PhonenumberValidator(fl validator.FieldLevel) bool {
 	phonenumberRegex := regexp.MustCompile(`^(?:(?:\+91|0)?[ -]?)?(?:(?:\d{2,4}[ -]?\d{6,8})|(?:\d{10}))$`)
 	return phonenumberRegex.MatchString(phonenumber)
 }
+
+func ChannelHandleValidator(fl validator.FieldLevel) bool {
+	handle := fl.Field().String()
+	return regexp.MustCompile(`^[a-z][a-z0-9_]{4,31}$`).MatchString(handle)
+}